@@ -0,0 +1,59 @@
+// Package codec provides cache.Codec implementations beyond the JSON and
+// gob codecs of package cache. The protobuf codec lives in the protobuf
+// subpackage so only its importers depend on the protobuf runtime.
+package codec
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Marshaler is implemented by values that encode themselves, such as
+// messages generated by gogoproto.
+type Marshaler interface {
+	Marshal() ([]byte, error)
+	Unmarshal(data []byte) error
+}
+
+// Methods encodes values through their own Marshal and Unmarshal methods.
+// Values must implement Marshaler; for cache.Typed this means T is a
+// pointer to such a type.
+type Methods struct{}
+
+func (Methods) Marshal(v any) ([]byte, error) {
+	m, ok := marshaler(v, false)
+	if !ok {
+		return nil, fmt.Errorf("codec: %T does not implement Marshaler", v)
+	}
+	return m.Marshal()
+}
+
+func (Methods) Unmarshal(data []byte, v any) error {
+	m, ok := marshaler(v, true)
+	if !ok {
+		return fmt.Errorf("codec: %T does not implement Marshaler", v)
+	}
+	return m.Unmarshal(data)
+}
+
+// marshaler returns the Marshaler behind v, which may also be a pointer to
+// a Marshaler pointer as cache.Typed passes when decoding. A nil pointer is
+// allocated when alloc is set.
+func marshaler(v any, alloc bool) (Marshaler, bool) {
+	if m, ok := v.(Marshaler); ok {
+		return m, true
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Pointer {
+		return nil, false
+	}
+	elem := rv.Elem()
+	if elem.IsNil() {
+		if !alloc {
+			return nil, false
+		}
+		elem.Set(reflect.New(elem.Type().Elem()))
+	}
+	m, ok := elem.Interface().(Marshaler)
+	return m, ok
+}
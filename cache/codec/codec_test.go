@@ -0,0 +1,141 @@
+package codec
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/carlosealves2/go-infrakit/cache"
+	"github.com/carlosealves2/go-infrakit/cache/memory"
+)
+
+type profile struct {
+	Name    string            `msgpack:"name"`
+	Age     int               `msgpack:"age"`
+	Score   float64           `msgpack:"score"`
+	Tags    []string          `msgpack:"tags"`
+	Attrs   map[string]string `msgpack:"attrs"`
+	Raw     []byte            `msgpack:"raw"`
+	Created time.Time         `msgpack:"created"`
+	Parent  *profile          `msgpack:"parent"`
+	secret  string
+}
+
+// selfEncoded mimics a message generated by gogoproto.
+type selfEncoded struct {
+	Name string
+}
+
+func (p *selfEncoded) Marshal() ([]byte, error) { return []byte(p.Name), nil }
+func (p *selfEncoded) Unmarshal(b []byte) error {
+	p.Name = string(b)
+	return nil
+}
+
+func TestTypedCodecs(t *testing.T) {
+	ctx := context.Background()
+	m := memory.New(cache.Options{})
+	defer m.Close()
+	want := profile{
+		Name:    "ana",
+		Age:     -42,
+		Score:   1.5,
+		Tags:    []string{"a", "b"},
+		Attrs:   map[string]string{"k": "v"},
+		Raw:     []byte{0, 1, 2},
+		Created: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		Parent:  &profile{Name: "root", Age: 70000},
+	}
+	tc := cache.NewTyped[profile](m, Msgpack{})
+	if err := tc.SetWithTTL(ctx, "msgpack", want, time.Minute); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	got, err := tc.Get(ctx, "msgpack")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+
+	mc := cache.NewTyped[*selfEncoded](m, Methods{})
+	if err := mc.Set(ctx, "methods", &selfEncoded{Name: "ana"}); err != nil {
+		t.Fatalf("set self-encoded: %v", err)
+	}
+	if got, err := mc.Get(ctx, "methods"); err != nil || got.Name != "ana" {
+		t.Fatalf("get self-encoded: %v %+v", err, got)
+	}
+	if err := cache.NewTyped[string](m, Methods{}).Set(ctx, "k", "v"); err == nil {
+		t.Fatalf("expected error encoding a non-Marshaler")
+	}
+}
+
+func TestMsgpackCodec(t *testing.T) {
+	codec := Msgpack{}
+	in := map[string]any{
+		"nil":   nil,
+		"true":  true,
+		"small": int64(-5),
+		"big":   uint64(1 << 40),
+		"float": 2.5,
+		"list":  []any{"x", int64(-300)},
+		"long":  string(make([]byte, 300)),
+	}
+	data, err := codec.Marshal(in)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var out map[string]any
+	if err := codec.Unmarshal(data, &out); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Fatalf("got %#v, want %#v", out, in)
+	}
+
+	// Unknown fields are skipped and overflowing integers rejected.
+	data, _ = codec.Marshal(map[string]any{"name": "ana", "extra": []any{map[string]any{"a": 1}}, "age": 1})
+	var p profile
+	if err := codec.Unmarshal(data, &p); err != nil || p.Name != "ana" || p.Age != 1 {
+		t.Fatalf("unmarshal struct: %v %+v", err, p)
+	}
+	data, _ = codec.Marshal(300)
+	var small int8
+	if err := codec.Unmarshal(data, &small); err == nil {
+		t.Fatalf("expected overflow error")
+	}
+	if err := codec.Unmarshal(data[:0], &small); err == nil {
+		t.Fatalf("expected error on truncated data")
+	}
+
+	// Container lengths beyond the data are rejected before allocating.
+	for _, data := range [][]byte{
+		{0xdd, 0xff, 0xff, 0xff, 0xff},
+		{0xdf, 0xff, 0xff, 0xff, 0xff},
+		{0x9f, 0x01},
+		{0x81, 0x01},
+	} {
+		var s []string
+		var m map[string]string
+		var g any
+		for _, v := range []any{&s, &m, &g} {
+			if err := codec.Unmarshal(data, v); err == nil {
+				t.Fatalf("%x: expected error decoding into %T", data, v)
+			}
+		}
+	}
+}
+
+func FuzzMsgpackCodec(f *testing.F) {
+	codec := Msgpack{}
+	seed, _ := codec.Marshal(profile{Name: "ana", Tags: []string{"a"}, Attrs: map[string]string{"k": "v"}, Parent: &profile{Age: 3}})
+	f.Add(seed)
+	f.Add([]byte{0xdd, 0xff, 0xff, 0xff, 0xff})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var p profile
+		_ = codec.Unmarshal(data, &p)
+		var g any
+		_ = codec.Unmarshal(data, &g)
+	})
+}
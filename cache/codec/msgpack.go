@@ -0,0 +1,575 @@
+package codec
+
+import (
+	"encoding"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// Msgpack encodes values as MessagePack using reflection. Structs are
+// encoded as maps keyed by field name, or by the name in a `msgpack` struct
+// tag ("-" skips the field). Types implementing encoding.BinaryMarshaler,
+// such as time.Time, are stored as binary values.
+type Msgpack struct{}
+
+func (Msgpack) Marshal(v any) ([]byte, error) {
+	var e msgpackEncoder
+	if err := e.encode(reflect.ValueOf(v)); err != nil {
+		return nil, err
+	}
+	return e.buf, nil
+}
+
+func (Msgpack) Unmarshal(data []byte, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("msgpack: decode into non-pointer %T", v)
+	}
+	d := msgpackDecoder{data: data}
+	if err := d.decode(rv.Elem()); err != nil {
+		return err
+	}
+	if d.pos != len(d.data) {
+		return errors.New("msgpack: trailing data")
+	}
+	return nil
+}
+
+var (
+	binaryMarshalerType   = reflect.TypeFor[encoding.BinaryMarshaler]()
+	binaryUnmarshalerType = reflect.TypeFor[encoding.BinaryUnmarshaler]()
+)
+
+// msgpackField is an encoded struct field.
+type msgpackField struct {
+	name  string
+	index int
+}
+
+var msgpackFieldCache sync.Map // reflect.Type -> []msgpackField
+
+func msgpackFields(t reflect.Type) []msgpackField {
+	if f, ok := msgpackFieldCache.Load(t); ok {
+		return f.([]msgpackField)
+	}
+	var fields []msgpackField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name := f.Name
+		if tag, ok := f.Tag.Lookup("msgpack"); ok {
+			tag, _, _ = strings.Cut(tag, ",")
+			if tag == "-" {
+				continue
+			}
+			if tag != "" {
+				name = tag
+			}
+		}
+		fields = append(fields, msgpackField{name: name, index: i})
+	}
+	msgpackFieldCache.Store(t, fields)
+	return fields
+}
+
+type msgpackEncoder struct {
+	buf []byte
+}
+
+func (e *msgpackEncoder) encode(v reflect.Value) error {
+	if !v.IsValid() {
+		e.buf = append(e.buf, 0xc0)
+		return nil
+	}
+	if v.Type().Implements(binaryMarshalerType) {
+		if v.Kind() == reflect.Pointer && v.IsNil() {
+			e.buf = append(e.buf, 0xc0)
+			return nil
+		}
+		b, err := v.Interface().(encoding.BinaryMarshaler).MarshalBinary()
+		if err != nil {
+			return err
+		}
+		e.encodeBin(b)
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			e.buf = append(e.buf, 0xc0)
+			return nil
+		}
+		return e.encode(v.Elem())
+	case reflect.Bool:
+		if v.Bool() {
+			e.buf = append(e.buf, 0xc3)
+		} else {
+			e.buf = append(e.buf, 0xc2)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		e.encodeInt(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		e.encodeUint(v.Uint())
+	case reflect.Float32:
+		e.buf = append(e.buf, 0xca)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, math.Float32bits(float32(v.Float())))
+	case reflect.Float64:
+		e.buf = append(e.buf, 0xcb)
+		e.buf = binary.BigEndian.AppendUint64(e.buf, math.Float64bits(v.Float()))
+	case reflect.String:
+		e.encodeStr(v.String())
+	case reflect.Slice:
+		if v.IsNil() {
+			e.buf = append(e.buf, 0xc0)
+			return nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			e.encodeBin(v.Bytes())
+			return nil
+		}
+		fallthrough
+	case reflect.Array:
+		e.encodeLen(v.Len(), 0x90, 0x0f, 0xdc)
+		for i := 0; i < v.Len(); i++ {
+			if err := e.encode(v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		if v.IsNil() {
+			e.buf = append(e.buf, 0xc0)
+			return nil
+		}
+		e.encodeLen(v.Len(), 0x80, 0x0f, 0xde)
+		iter := v.MapRange()
+		for iter.Next() {
+			if err := e.encode(iter.Key()); err != nil {
+				return err
+			}
+			if err := e.encode(iter.Value()); err != nil {
+				return err
+			}
+		}
+	case reflect.Struct:
+		fields := msgpackFields(v.Type())
+		e.encodeLen(len(fields), 0x80, 0x0f, 0xde)
+		for _, f := range fields {
+			e.encodeStr(f.name)
+			if err := e.encode(v.Field(f.index)); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("msgpack: unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+func (e *msgpackEncoder) encodeInt(n int64) {
+	switch {
+	case n >= 0:
+		e.encodeUint(uint64(n))
+	case n >= -32:
+		e.buf = append(e.buf, byte(n))
+	case n >= math.MinInt8:
+		e.buf = append(e.buf, 0xd0, byte(n))
+	case n >= math.MinInt16:
+		e.buf = binary.BigEndian.AppendUint16(append(e.buf, 0xd1), uint16(n))
+	case n >= math.MinInt32:
+		e.buf = binary.BigEndian.AppendUint32(append(e.buf, 0xd2), uint32(n))
+	default:
+		e.buf = binary.BigEndian.AppendUint64(append(e.buf, 0xd3), uint64(n))
+	}
+}
+
+func (e *msgpackEncoder) encodeUint(n uint64) {
+	switch {
+	case n <= 0x7f:
+		e.buf = append(e.buf, byte(n))
+	case n <= math.MaxUint8:
+		e.buf = append(e.buf, 0xcc, byte(n))
+	case n <= math.MaxUint16:
+		e.buf = binary.BigEndian.AppendUint16(append(e.buf, 0xcd), uint16(n))
+	case n <= math.MaxUint32:
+		e.buf = binary.BigEndian.AppendUint32(append(e.buf, 0xce), uint32(n))
+	default:
+		e.buf = binary.BigEndian.AppendUint64(append(e.buf, 0xcf), n)
+	}
+}
+
+func (e *msgpackEncoder) encodeStr(s string) {
+	switch n := len(s); {
+	case n <= 31:
+		e.buf = append(e.buf, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		e.buf = append(e.buf, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		e.buf = binary.BigEndian.AppendUint16(append(e.buf, 0xda), uint16(n))
+	default:
+		e.buf = binary.BigEndian.AppendUint32(append(e.buf, 0xdb), uint32(n))
+	}
+	e.buf = append(e.buf, s...)
+}
+
+func (e *msgpackEncoder) encodeBin(b []byte) {
+	switch n := len(b); {
+	case n <= math.MaxUint8:
+		e.buf = append(e.buf, 0xc4, byte(n))
+	case n <= math.MaxUint16:
+		e.buf = binary.BigEndian.AppendUint16(append(e.buf, 0xc5), uint16(n))
+	default:
+		e.buf = binary.BigEndian.AppendUint32(append(e.buf, 0xc6), uint32(n))
+	}
+	e.buf = append(e.buf, b...)
+}
+
+// encodeLen writes an array or map header: the fix format when n fits in
+// fixMax, otherwise the 16-bit format code followed by the 32-bit one.
+func (e *msgpackEncoder) encodeLen(n int, fix byte, fixMax int, code16 byte) {
+	switch {
+	case n <= fixMax:
+		e.buf = append(e.buf, fix|byte(n))
+	case n <= math.MaxUint16:
+		e.buf = binary.BigEndian.AppendUint16(append(e.buf, code16), uint16(n))
+	default:
+		e.buf = binary.BigEndian.AppendUint32(append(e.buf, code16+1), uint32(n))
+	}
+}
+
+var errMsgpackShort = errors.New("msgpack: unexpected end of data")
+
+type msgpackDecoder struct {
+	data []byte
+	pos  int
+}
+
+func (d *msgpackDecoder) next(n int) ([]byte, error) {
+	if n < 0 || len(d.data)-d.pos < n {
+		return nil, errMsgpackShort
+	}
+	b := d.data[d.pos : d.pos+n]
+	d.pos += n
+	return b, nil
+}
+
+func (d *msgpackDecoder) uintN(n int) (uint64, error) {
+	b, err := d.next(n)
+	if err != nil {
+		return 0, err
+	}
+	var u uint64
+	for _, c := range b {
+		u = u<<8 | uint64(c)
+	}
+	return u, nil
+}
+
+// msgpackValue is a decoded scalar or the header of a container.
+type msgpackValue struct {
+	kind  reflect.Kind // Invalid for nil, Slice for bin, Array and Map for containers
+	b     bool
+	i     int64
+	u     uint64
+	f     float64
+	bytes []byte
+	n     int
+}
+
+func (d *msgpackDecoder) read() (msgpackValue, error) {
+	b, err := d.next(1)
+	if err != nil {
+		return msgpackValue{}, err
+	}
+	c := b[0]
+	switch {
+	case c <= 0x7f:
+		return msgpackValue{kind: reflect.Uint64, u: uint64(c)}, nil
+	case c >= 0xe0:
+		return msgpackValue{kind: reflect.Int64, i: int64(int8(c))}, nil
+	case c&0xf0 == 0x80:
+		return d.container(reflect.Map, int(c&0x0f))
+	case c&0xf0 == 0x90:
+		return d.container(reflect.Array, int(c&0x0f))
+	case c&0xe0 == 0xa0:
+		s, err := d.next(int(c & 0x1f))
+		return msgpackValue{kind: reflect.String, bytes: s}, err
+	}
+	var u uint64
+	switch c {
+	case 0xc0:
+		return msgpackValue{kind: reflect.Invalid}, nil
+	case 0xc2, 0xc3:
+		return msgpackValue{kind: reflect.Bool, b: c == 0xc3}, nil
+	case 0xc4, 0xc5, 0xc6, 0xd9, 0xda, 0xdb:
+		kind, size := reflect.Slice, 1<<(c-0xc4)
+		if c >= 0xd9 {
+			kind, size = reflect.String, 1<<(c-0xd9)
+		}
+		if u, err = d.uintN(size); err != nil {
+			return msgpackValue{}, err
+		}
+		s, err := d.next(int(u))
+		return msgpackValue{kind: kind, bytes: s}, err
+	case 0xca:
+		u, err = d.uintN(4)
+		return msgpackValue{kind: reflect.Float64, f: float64(math.Float32frombits(uint32(u)))}, err
+	case 0xcb:
+		u, err = d.uintN(8)
+		return msgpackValue{kind: reflect.Float64, f: math.Float64frombits(u)}, err
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		u, err = d.uintN(1 << (c - 0xcc))
+		return msgpackValue{kind: reflect.Uint64, u: u}, err
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (c - 0xd0)
+		u, err = d.uintN(size)
+		shift := 64 - 8*size
+		return msgpackValue{kind: reflect.Int64, i: int64(u<<shift) >> shift}, err
+	case 0xdc, 0xdd, 0xde, 0xdf:
+		if u, err = d.uintN(2 << ((c - 0xdc) % 2)); err != nil {
+			return msgpackValue{}, err
+		}
+		kind := reflect.Array
+		if c >= 0xde {
+			kind = reflect.Map
+		}
+		return d.container(kind, int(u))
+	}
+	return msgpackValue{}, fmt.Errorf("msgpack: unsupported format 0x%02x", c)
+}
+
+// container returns the header of a container of n elements. Every element
+// takes at least a byte, so a length the remaining data cannot hold is
+// rejected before anything is allocated for it.
+func (d *msgpackDecoder) container(kind reflect.Kind, n int) (msgpackValue, error) {
+	elems := n
+	if kind == reflect.Map {
+		elems *= 2
+	}
+	if n < 0 || elems > len(d.data)-d.pos {
+		return msgpackValue{}, errMsgpackShort
+	}
+	return msgpackValue{kind: kind, n: n}, nil
+}
+
+func (d *msgpackDecoder) decode(v reflect.Value) error {
+	val, err := d.read()
+	if err != nil {
+		return err
+	}
+	return d.decodeValue(val, v)
+}
+
+func (d *msgpackDecoder) decodeValue(val msgpackValue, v reflect.Value) error {
+	if val.kind == reflect.Invalid {
+		v.SetZero()
+		return nil
+	}
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return d.decodeValue(val, v.Elem())
+	}
+	if val.kind == reflect.Slice && reflect.PointerTo(v.Type()).Implements(binaryUnmarshalerType) {
+		return v.Addr().Interface().(encoding.BinaryUnmarshaler).UnmarshalBinary(val.bytes)
+	}
+	if v.Kind() == reflect.Interface && v.NumMethod() == 0 {
+		g, err := d.generic(val)
+		if err != nil {
+			return err
+		}
+		if g == nil {
+			v.SetZero()
+		} else {
+			v.Set(reflect.ValueOf(g))
+		}
+		return nil
+	}
+	mismatch := func() error {
+		return fmt.Errorf("msgpack: cannot decode %s into %s", val.kind, v.Type())
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		if val.kind != reflect.Bool {
+			return mismatch()
+		}
+		v.SetBool(val.b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n := val.i
+		switch {
+		case val.kind == reflect.Uint64 && val.u <= math.MaxInt64:
+			n = int64(val.u)
+		case val.kind != reflect.Int64:
+			return mismatch()
+		}
+		if v.OverflowInt(n) {
+			return fmt.Errorf("msgpack: %d overflows %s", n, v.Type())
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if val.kind != reflect.Uint64 {
+			return mismatch()
+		}
+		if v.OverflowUint(val.u) {
+			return fmt.Errorf("msgpack: %d overflows %s", val.u, v.Type())
+		}
+		v.SetUint(val.u)
+	case reflect.Float32, reflect.Float64:
+		switch val.kind {
+		case reflect.Float64:
+			v.SetFloat(val.f)
+		case reflect.Int64:
+			v.SetFloat(float64(val.i))
+		case reflect.Uint64:
+			v.SetFloat(float64(val.u))
+		default:
+			return mismatch()
+		}
+	case reflect.String:
+		if val.kind != reflect.String && val.kind != reflect.Slice {
+			return mismatch()
+		}
+		v.SetString(string(val.bytes))
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 && (val.kind == reflect.Slice || val.kind == reflect.String) {
+			v.SetBytes(append([]byte{}, val.bytes...))
+			return nil
+		}
+		if val.kind != reflect.Array {
+			return mismatch()
+		}
+		s := reflect.MakeSlice(v.Type(), val.n, val.n)
+		for i := 0; i < val.n; i++ {
+			if err := d.decode(s.Index(i)); err != nil {
+				return err
+			}
+		}
+		v.Set(s)
+	case reflect.Array:
+		if val.kind != reflect.Array || val.n != v.Len() {
+			return mismatch()
+		}
+		for i := 0; i < val.n; i++ {
+			if err := d.decode(v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		if val.kind != reflect.Map {
+			return mismatch()
+		}
+		m := reflect.MakeMapWithSize(v.Type(), val.n)
+		for i := 0; i < val.n; i++ {
+			k := reflect.New(v.Type().Key()).Elem()
+			if err := d.decode(k); err != nil {
+				return err
+			}
+			e := reflect.New(v.Type().Elem()).Elem()
+			if err := d.decode(e); err != nil {
+				return err
+			}
+			m.SetMapIndex(k, e)
+		}
+		v.Set(m)
+	case reflect.Struct:
+		if val.kind != reflect.Map {
+			return mismatch()
+		}
+		fields := msgpackFields(v.Type())
+		for i := 0; i < val.n; i++ {
+			var name string
+			if err := d.decode(reflect.ValueOf(&name).Elem()); err != nil {
+				return err
+			}
+			idx := -1
+			for _, f := range fields {
+				if f.name == name {
+					idx = f.index
+					break
+				}
+			}
+			if idx < 0 {
+				if err := d.skip(); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := d.decode(v.Field(idx)); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("msgpack: unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// generic decodes val into the types encoding/json uses for interface
+// values, keeping integers as int64 or uint64 and binary as []byte.
+func (d *msgpackDecoder) generic(val msgpackValue) (any, error) {
+	switch val.kind {
+	case reflect.Invalid:
+		return nil, nil
+	case reflect.Bool:
+		return val.b, nil
+	case reflect.Int64:
+		return val.i, nil
+	case reflect.Uint64:
+		return val.u, nil
+	case reflect.Float64:
+		return val.f, nil
+	case reflect.String:
+		return string(val.bytes), nil
+	case reflect.Slice:
+		return append([]byte{}, val.bytes...), nil
+	case reflect.Array:
+		out := make([]any, val.n)
+		for i := range out {
+			if err := d.decode(reflect.ValueOf(&out[i]).Elem()); err != nil {
+				return nil, err
+			}
+		}
+		return out, nil
+	default:
+		out := make(map[string]any, val.n)
+		for i := 0; i < val.n; i++ {
+			var k string
+			if err := d.decode(reflect.ValueOf(&k).Elem()); err != nil {
+				return nil, err
+			}
+			var e any
+			if err := d.decode(reflect.ValueOf(&e).Elem()); err != nil {
+				return nil, err
+			}
+			out[k] = e
+		}
+		return out, nil
+	}
+}
+
+// skip consumes one value, including the elements of containers.
+func (d *msgpackDecoder) skip() error {
+	val, err := d.read()
+	if err != nil {
+		return err
+	}
+	n := val.n
+	if val.kind == reflect.Map {
+		n *= 2
+	} else if val.kind != reflect.Array {
+		n = 0
+	}
+	for i := 0; i < n; i++ {
+		if err := d.skip(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+// Package protobuf provides a cache.Codec for messages generated by
+// google.golang.org/protobuf.
+package protobuf
+
+import (
+	"fmt"
+	"reflect"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// Codec encodes messages with proto.Marshal and proto.Unmarshal. Values
+// must implement proto.Message; for cache.Typed this means T is a message
+// pointer such as *pb.User.
+type Codec struct{}
+
+func (Codec) Marshal(v any) ([]byte, error) {
+	m, ok := message(v, false)
+	if !ok {
+		return nil, fmt.Errorf("protobuf: %T is not a proto.Message", v)
+	}
+	return proto.Marshal(m)
+}
+
+func (Codec) Unmarshal(data []byte, v any) error {
+	m, ok := message(v, true)
+	if !ok {
+		return fmt.Errorf("protobuf: %T is not a proto.Message", v)
+	}
+	return proto.Unmarshal(data, m)
+}
+
+// message returns the proto.Message behind v, which may also be a pointer
+// to a message pointer as cache.Typed passes when decoding. A nil message
+// pointer is allocated when alloc is set.
+func message(v any, alloc bool) (proto.Message, bool) {
+	if m, ok := v.(proto.Message); ok {
+		return m, true
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Pointer {
+		return nil, false
+	}
+	elem := rv.Elem()
+	if elem.IsNil() {
+		if !alloc {
+			return nil, false
+		}
+		elem.Set(reflect.New(elem.Type().Elem()))
+	}
+	m, ok := elem.Interface().(proto.Message)
+	return m, ok
+}
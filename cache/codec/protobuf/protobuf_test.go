@@ -0,0 +1,32 @@
+package protobuf
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/carlosealves2/go-infrakit/cache"
+	"github.com/carlosealves2/go-infrakit/cache/memory"
+)
+
+func TestCodec(t *testing.T) {
+	ctx := context.Background()
+	m := memory.New(cache.Options{})
+	defer m.Close()
+	tc := cache.NewTyped[*wrapperspb.StringValue](m, Codec{})
+	if err := tc.Set(ctx, "msg", wrapperspb.String("ana")); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	got, err := tc.Get(ctx, "msg")
+	if err != nil || got.GetValue() != "ana" {
+		t.Fatalf("get: %v %v", err, got)
+	}
+	if err := cache.NewTyped[string](m, Codec{}).Set(ctx, "k", "v"); err == nil {
+		t.Fatalf("expected error encoding a non-message")
+	}
+	m.SetBytes(ctx, "broken", []byte("\x01\xff"))
+	if _, err := tc.Get(ctx, "broken"); err == nil {
+		t.Fatalf("expected error decoding invalid wire data")
+	}
+}
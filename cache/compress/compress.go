@@ -0,0 +1,294 @@
+// Package compress transparently compresses large values stored in any
+// cache.Cache.
+//
+// Values at or above a size threshold are compressed and stored behind a
+// small header whose last byte names the format. Values without the header
+// are returned as they are, so entries written before compression was
+// enabled, or with another format, keep decoding while a rollout is in
+// progress.
+package compress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/carlosealves2/go-infrakit/cache"
+)
+
+// Format identifies the compression of a stored value. Formats below 16 are
+// reserved for this package.
+type Format byte
+
+const (
+	// None marks small values that would otherwise be mistaken for a
+	// compressed entry.
+	None   Format = 0
+	Gzip   Format = 1
+	Snappy Format = 3
+)
+
+func (f Format) String() string {
+	switch f {
+	case None:
+		return "none"
+	case Gzip:
+		return "gzip"
+	case Snappy:
+		return "snappy"
+	}
+	return fmt.Sprintf("format(%d)", byte(f))
+}
+
+// DefaultMaxSize bounds decompressed gzip values unless the compressor sets
+// its own limit.
+const DefaultMaxSize = 64 << 20
+
+// ErrTooLarge is returned when a value decompresses to more than the limit
+// of its compressor, as a corrupt or hostile entry may.
+var ErrTooLarge = errors.New("compress: decompressed value too large")
+
+// Compressor implements one compression format. Gzip and Snappy are built
+// in; other formats, such as zstd from github.com/klauspost/compress, are
+// plugged in by a Compressor reporting a Format of 16 or above.
+type Compressor interface {
+	Format() Format
+	Compress(src []byte) ([]byte, error)
+	Decompress(src []byte) ([]byte, error)
+}
+
+// GzipCompressor compresses with compress/gzip.
+type GzipCompressor struct {
+	// Level is a compress/gzip level; zero means gzip.DefaultCompression.
+	Level int
+	// MaxSize bounds decompressed values and defaults to DefaultMaxSize.
+	MaxSize int64
+}
+
+func (GzipCompressor) Format() Format { return Gzip }
+
+func (g GzipCompressor) Compress(src []byte) ([]byte, error) {
+	level := g.Level
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+	var buf bytes.Buffer
+	w, err := gzip.NewWriterLevel(&buf, level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(src); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (g GzipCompressor) Decompress(src []byte) ([]byte, error) {
+	limit := g.MaxSize
+	if limit <= 0 {
+		limit = DefaultMaxSize
+	}
+	r, err := gzip.NewReader(bytes.NewReader(src))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	out, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(out)) > limit {
+		return nil, ErrTooLarge
+	}
+	return out, nil
+}
+
+// SnappyCompressor compresses with the Snappy block format. It is much
+// faster than gzip at a lower ratio.
+type SnappyCompressor struct{}
+
+func (SnappyCompressor) Format() Format                        { return Snappy }
+func (SnappyCompressor) Compress(src []byte) ([]byte, error)   { return snappyEncode(src), nil }
+func (SnappyCompressor) Decompress(src []byte) ([]byte, error) { return snappyDecode(src) }
+
+// Options configures a compressing cache.
+type Options struct {
+	// Compressor is used for new writes. It defaults to gzip.
+	Compressor Compressor
+	// Threshold is the minimum value size compressed. It defaults to 1KiB.
+	Threshold int
+	// Decompressors are extra formats accepted on reads, for example the
+	// previous Compressor while switching formats. Gzip and Snappy are
+	// always accepted; a GzipCompressor given here or as Compressor sets
+	// the size limit of gzip reads.
+	Decompressors []Compressor
+
+	Meter metric.Meter
+}
+
+// Cache compresses values written to the wrapped cache.
+type Cache struct {
+	next      cache.Cache
+	comp      Compressor
+	threshold int
+	formats   map[Format]Compressor
+	ratio     metric.Float64Histogram
+}
+
+// New wraps next with compression.
+func New(next cache.Cache, opts Options) *Cache {
+	if opts.Compressor == nil {
+		opts.Compressor = GzipCompressor{}
+	}
+	if opts.Threshold <= 0 {
+		opts.Threshold = 1024
+	}
+	c := &Cache{
+		next:      next,
+		comp:      opts.Compressor,
+		threshold: opts.Threshold,
+		formats: map[Format]Compressor{
+			Gzip:   GzipCompressor{},
+			Snappy: SnappyCompressor{},
+		},
+	}
+	for _, d := range opts.Decompressors {
+		c.formats[d.Format()] = d
+	}
+	c.formats[c.comp.Format()] = c.comp
+	if opts.Meter != (metric.Meter{}) {
+		c.ratio, _ = opts.Meter.Float64Histogram("cache_compression_ratio")
+	}
+	return c
+}
+
+// header layout: magic (2 bytes) followed by the Format byte.
+var magic = [2]byte{0xC1, 'Z'}
+
+const headerLen = 3
+
+func hasHeader(b []byte) bool {
+	return len(b) >= headerLen && b[0] == magic[0] && b[1] == magic[1]
+}
+
+func withHeader(f Format, data []byte) []byte {
+	buf := make([]byte, headerLen+len(data))
+	copy(buf, magic[:])
+	buf[2] = byte(f)
+	copy(buf[headerLen:], data)
+	return buf
+}
+
+// encode compresses value when it is large enough and compression pays
+// off. Uncompressed values only get a header when they start like one.
+func (c *Cache) encode(ctx context.Context, value []byte) ([]byte, error) {
+	if len(value) >= c.threshold {
+		data, err := c.comp.Compress(value)
+		if err != nil {
+			return nil, fmt.Errorf("compress: %s: %w", c.comp.Format(), err)
+		}
+		if c.ratio != (metric.Float64Histogram{}) {
+			c.ratio.Record(ctx, float64(len(data))/float64(len(value)), metric.WithAttributes(
+				attribute.String("provider", "compress"),
+				attribute.String("format", c.comp.Format().String()),
+			))
+		}
+		if len(data)+headerLen < len(value) {
+			return withHeader(c.comp.Format(), data), nil
+		}
+	}
+	if hasHeader(value) {
+		return withHeader(None, value), nil
+	}
+	return value, nil
+}
+
+func (c *Cache) decode(raw []byte) ([]byte, error) {
+	if !hasHeader(raw) {
+		return raw, nil
+	}
+	f := Format(raw[2])
+	if f == None {
+		return raw[headerLen:], nil
+	}
+	comp, ok := c.formats[f]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown compression %s", cache.ErrDecode, f)
+	}
+	value, err := comp.Decompress(raw[headerLen:])
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", cache.ErrDecode, f, err)
+	}
+	return value, nil
+}
+
+func (c *Cache) Set(ctx context.Context, key, value string) error {
+	return c.SetBytes(ctx, key, []byte(value))
+}
+
+func (c *Cache) SetBytes(ctx context.Context, key string, value []byte) error {
+	data, err := c.encode(ctx, value)
+	if err != nil {
+		return err
+	}
+	return c.next.SetBytes(ctx, key, data)
+}
+
+func (c *Cache) SetWithTTL(ctx context.Context, key, value string, ttl time.Duration) error {
+	data, err := c.encode(ctx, []byte(value))
+	if err != nil {
+		return err
+	}
+	return c.next.SetWithTTL(ctx, key, string(data), ttl)
+}
+
+func (c *Cache) Get(ctx context.Context, key string) (string, error) {
+	b, err := c.GetBytes(ctx, key)
+	return string(b), err
+}
+
+// GetBytes returns the decompressed value for key. Values that fail to
+// decompress return an error wrapping cache.ErrDecode.
+func (c *Cache) GetBytes(ctx context.Context, key string) ([]byte, error) {
+	raw, err := c.next.GetBytes(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return c.decode(raw)
+}
+
+func (c *Cache) Del(ctx context.Context, keys ...string) error {
+	return c.next.Del(ctx, keys...)
+}
+
+func (c *Cache) Exists(ctx context.Context, key string) (bool, error) {
+	return c.next.Exists(ctx, key)
+}
+
+func (c *Cache) TTL(ctx context.Context, key string) (time.Duration, error) {
+	return c.next.TTL(ctx, key)
+}
+
+func (c *Cache) Expire(ctx context.Context, key string, ttl time.Duration) error {
+	return c.next.Expire(ctx, key, ttl)
+}
+
+func (c *Cache) Persist(ctx context.Context, key string) error {
+	return c.next.Persist(ctx, key)
+}
+
+func (c *Cache) Touch(ctx context.Context, key string) error {
+	return c.next.Touch(ctx, key)
+}
+
+var _ cache.Cache = (*Cache)(nil)
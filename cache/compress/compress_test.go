@@ -0,0 +1,154 @@
+package compress
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/carlosealves2/go-infrakit/cache"
+	"github.com/carlosealves2/go-infrakit/cache/memory"
+	"github.com/carlosealves2/go-infrakit/cache/redis"
+	"github.com/carlosealves2/go-infrakit/internal/redistest"
+)
+
+func newDrivers(t *testing.T) map[string]cache.Cache {
+	r, err := redis.New(cache.Options{Addr: redistest.Serve(t, &redistest.Server{})})
+	if err != nil {
+		t.Fatalf("new redis: %v", err)
+	}
+	m := memory.New(cache.Options{})
+	t.Cleanup(func() { m.Close() })
+	return map[string]cache.Cache{"memory": m, "redis": r}
+}
+
+func TestCompressRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	large := strings.Repeat(`{"id":1,"name":"payload"},`, 2000)
+	for name, next := range newDrivers(t) {
+		for _, comp := range []Compressor{GzipCompressor{}, SnappyCompressor{}} {
+			t.Run(name+"/"+comp.Format().String(), func(t *testing.T) {
+				c := New(next, Options{Compressor: comp})
+				if err := c.SetWithTTL(ctx, "large", large, time.Minute); err != nil {
+					t.Fatalf("set: %v", err)
+				}
+				raw, _ := next.GetBytes(ctx, "large")
+				if !hasHeader(raw) || Format(raw[2]) != comp.Format() || len(raw) >= len(large)/4 {
+					t.Fatalf("expected a compressed entry, got %d bytes", len(raw))
+				}
+				if v, err := c.Get(ctx, "large"); err != nil || v != large {
+					t.Fatalf("get: %v", err)
+				}
+				if ttl, _ := c.TTL(ctx, "large"); ttl <= 0 {
+					t.Fatalf("expected ttl to be kept, got %v", ttl)
+				}
+
+				c.Set(ctx, "small", "tiny")
+				if raw, _ := next.Get(ctx, "small"); raw != "tiny" {
+					t.Fatalf("small values must be stored raw, got %q", raw)
+				}
+			})
+		}
+	}
+}
+
+func TestCompressMixedEntries(t *testing.T) {
+	ctx := context.Background()
+	next := memory.New(cache.Options{})
+	defer next.Close()
+	large := bytes.Repeat([]byte("abcdefgh"), 1000)
+
+	// Entries written before compression, or by a snappy writer, still decode.
+	next.SetBytes(ctx, "old", large)
+	New(next, Options{Compressor: SnappyCompressor{}}).SetBytes(ctx, "snappy", large)
+	c := New(next, Options{})
+	for _, key := range []string{"old", "snappy"} {
+		if v, err := c.GetBytes(ctx, key); err != nil || !bytes.Equal(v, large) {
+			t.Fatalf("get %s: %v", key, err)
+		}
+	}
+
+	// A raw value that looks like a header round-trips.
+	tricky := append(magic[:], byte(Gzip), 'x')
+	c.SetBytes(ctx, "tricky", tricky)
+	if v, err := c.GetBytes(ctx, "tricky"); err != nil || !bytes.Equal(v, tricky) {
+		t.Fatalf("get tricky: %v %q", err, v)
+	}
+
+	// Incompressible values are stored as they are.
+	random := make([]byte, 4096)
+	rand.New(rand.NewSource(1)).Read(random)
+	c.SetBytes(ctx, "random", random)
+	if raw, _ := next.GetBytes(ctx, "random"); !bytes.Equal(raw, random) {
+		t.Fatalf("incompressible value should be stored raw")
+	}
+
+	next.SetBytes(ctx, "unknown", withHeader(Format(16), []byte("data")))
+	if _, err := c.GetBytes(ctx, "unknown"); !errors.Is(err, cache.ErrDecode) {
+		t.Fatalf("expected ErrDecode for unknown format, got %v", err)
+	}
+	next.SetBytes(ctx, "corrupt", withHeader(Gzip, []byte("data")))
+	if _, err := c.GetBytes(ctx, "corrupt"); !errors.Is(err, cache.ErrDecode) {
+		t.Fatalf("expected ErrDecode for corrupt data, got %v", err)
+	}
+}
+
+func TestGzipMaxSize(t *testing.T) {
+	ctx := context.Background()
+	next := memory.New(cache.Options{})
+	defer next.Close()
+	zeros := make([]byte, 2<<20)
+	New(next, Options{}).SetBytes(ctx, "bomb", zeros)
+	raw, _ := next.GetBytes(ctx, "bomb")
+	if len(raw) > 16<<10 {
+		t.Fatalf("expected zeros to compress well, got %d bytes", len(raw))
+	}
+	if v, err := New(next, Options{}).GetBytes(ctx, "bomb"); err != nil || len(v) != len(zeros) {
+		t.Fatalf("get within the default limit: %v %d", err, len(v))
+	}
+	limited := New(next, Options{Compressor: GzipCompressor{MaxSize: 1 << 20}})
+	if _, err := limited.GetBytes(ctx, "bomb"); !errors.Is(err, cache.ErrDecode) || !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("expected ErrTooLarge, got %v", err)
+	}
+	if v, err := (GzipCompressor{MaxSize: int64(len(zeros))}).Decompress(raw[headerLen:]); err != nil || len(v) != len(zeros) {
+		t.Fatalf("a value of exactly MaxSize must decode: %v %d", err, len(v))
+	}
+}
+
+func TestSnappy(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	random := make([]byte, 70000)
+	rng.Read(random)
+	words := make([]byte, 0, 200000)
+	for len(words) < cap(words)-16 {
+		words = append(words, []string{"cache ", "redis ", "memory ", "value "}[rng.Intn(4)]...)
+	}
+	inputs := [][]byte{
+		nil,
+		[]byte("a"),
+		bytes.Repeat([]byte("a"), 100000),
+		random,
+		words,
+		append(append(random[:3000:3000], []byte("0123456789abcdef")...), random[:3000]...),
+	}
+	for i, in := range inputs {
+		out, err := snappyDecode(snappyEncode(in))
+		if err != nil || !bytes.Equal(out, in) {
+			t.Fatalf("input %d: round trip failed: %v", i, err)
+		}
+	}
+
+	// Hand-built block with a literal, a 1-byte and a 4-byte offset copy.
+	block := []byte{12, 1<<2 | tagLiteral, 'a', 'b', 0<<5 | 0<<2 | tagCopy1, 2, 5<<2 | tagCopy4, 4, 0, 0, 0}
+	if out, err := snappyDecode(block); err != nil || string(out) != "abababababab" {
+		t.Fatalf("decode: %v %q", err, out)
+	}
+	for _, bad := range [][]byte{{}, {5, 0}, {4, tagCopy2, 1, 0}, {0xff, 0xff, 0xff, 0xff, 0x0f}} {
+		if _, err := snappyDecode(bad); err == nil {
+			t.Fatalf("expected error for %v", bad)
+		}
+	}
+}
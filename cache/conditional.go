@@ -0,0 +1,30 @@
+package cache
+
+import "time"
+
+// SetMode restricts a write to absent or present keys.
+type SetMode int
+
+const (
+	// SetAlways writes regardless of whether the key exists.
+	SetAlways SetMode = iota
+	// SetIfAbsent writes only when the key does not exist (Redis NX).
+	SetIfAbsent
+	// SetIfPresent writes only when the key exists (Redis XX).
+	SetIfPresent
+)
+
+// SetOptions configures a conditional write.
+type SetOptions struct {
+	// TTL of the written value; zero stores it without expiration.
+	TTL time.Duration
+	// Mode restricts the write to absent or present keys.
+	Mode SetMode
+	// KeepTTL keeps the current TTL of an existing key, like Redis KEEPTTL:
+	// a missing key is stored without expiration. TTL is ignored.
+	KeepTTL bool
+	// IfEquals turns the write into a compare-and-swap: it only happens
+	// while the key holds exactly this value. A nil slice disables the
+	// guard; an empty slice matches an empty value.
+	IfEquals []byte
+}
@@ -0,0 +1,27 @@
+package cache
+
+import "time"
+
+// IncrOptions holds the settings collected from IncrOption values.
+type IncrOptions struct {
+	// InitialTTL is applied only when the increment creates the key.
+	InitialTTL time.Duration
+}
+
+// IncrOption configures an increment.
+type IncrOption func(*IncrOptions)
+
+// WithInitialTTL sets a TTL on the counter only when the increment creates
+// it, so fixed windows such as rate limits are not extended by later hits.
+func WithInitialTTL(ttl time.Duration) IncrOption {
+	return func(o *IncrOptions) { o.InitialTTL = ttl }
+}
+
+// ApplyIncrOptions resolves opts for drivers implementing CounterCache.
+func ApplyIncrOptions(opts []IncrOption) IncrOptions {
+	var o IncrOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
@@ -0,0 +1,266 @@
+// Package encrypt encrypts values stored in any cache.Cache with envelope
+// encryption.
+//
+// Every value is sealed with a fresh data key using AES-GCM, and the data
+// key is sealed with a key-encryption key obtained from a KeyProvider. The
+// ID of that key travels with the ciphertext, so keys can be rotated while
+// entries written under older keys stay readable for as long as the
+// provider still knows them. The cache key is bound to the ciphertext as
+// additional data, which stops an entry from being replayed under another
+// key.
+package encrypt
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/carlosealves2/go-infrakit/cache"
+)
+
+// ErrUnknownKey is returned by a KeyProvider that has no key with the
+// requested ID.
+var ErrUnknownKey = errors.New("encrypt: unknown key")
+
+// KeyProvider supplies key-encryption keys. Keys must be 16, 24 or 32 bytes
+// long for AES-128, AES-192 or AES-256. Providers are called on every
+// operation; implementations backed by a KMS should cache keys locally.
+type KeyProvider interface {
+	// CurrentKey returns the key used for new writes.
+	CurrentKey(ctx context.Context) (id string, key []byte, err error)
+	// Key returns the key with the given ID, or ErrUnknownKey.
+	Key(ctx context.Context, id string) ([]byte, error)
+}
+
+// StaticKeys is a KeyProvider over a fixed set of keys. To rotate, add the
+// new key, point Current at it and keep the old one until entries written
+// under it have expired.
+type StaticKeys struct {
+	Current string
+	Keys    map[string][]byte
+}
+
+func (s StaticKeys) CurrentKey(ctx context.Context) (string, []byte, error) {
+	key, err := s.Key(ctx, s.Current)
+	return s.Current, key, err
+}
+
+func (s StaticKeys) Key(_ context.Context, id string) ([]byte, error) {
+	key, ok := s.Keys[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKey, id)
+	}
+	return key, nil
+}
+
+// Options configures an encrypting cache.
+type Options struct {
+	Keys KeyProvider
+	// AllowPlaintext returns values stored without encryption as they are
+	// instead of failing, for migrating a cache that held plaintext.
+	AllowPlaintext bool
+}
+
+// Cache encrypts values written to the wrapped cache.
+type Cache struct {
+	next cache.Cache
+	opts Options
+}
+
+// New wraps next with envelope encryption. It fails without a KeyProvider
+// or when the current key cannot be fetched or has an invalid length.
+func New(next cache.Cache, opts Options) (*Cache, error) {
+	if opts.Keys == nil {
+		return nil, errors.New("encrypt: no key provider")
+	}
+	id, key, err := opts.Keys.CurrentKey(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("encrypt: current key: %w", err)
+	}
+	if _, err := aes.NewCipher(key); err != nil {
+		return nil, fmt.Errorf("encrypt: key %q: %w", id, err)
+	}
+	return &Cache{next: next, opts: opts}, nil
+}
+
+// envelope layout: magic (2 bytes), version, key ID length (1 byte), key
+// ID, the data key sealed under the key-encryption key (nonce followed by
+// ciphertext), then the value sealed under the data key (nonce followed by
+// ciphertext).
+var magic = [2]byte{0xC1, 'E'}
+
+const (
+	version    = 1
+	dataKeyLen = 32
+	nonceLen   = 12
+	tagLen     = 16
+	wrappedLen = nonceLen + dataKeyLen + tagLen
+)
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal appends the nonce and ciphertext of plaintext to dst.
+func seal(dst []byte, aead cipher.AEAD, plaintext, ad []byte) ([]byte, error) {
+	nonce := make([]byte, nonceLen)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(append(dst, nonce...), nonce, plaintext, ad), nil
+}
+
+func (c *Cache) encrypt(ctx context.Context, key string, value []byte) ([]byte, error) {
+	id, kek, err := c.opts.Keys.CurrentKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(id) > 255 {
+		return nil, fmt.Errorf("encrypt: key id longer than 255 bytes")
+	}
+	wrap, err := newGCM(kek)
+	if err != nil {
+		return nil, fmt.Errorf("encrypt: key %q: %w", id, err)
+	}
+	dataKey := make([]byte, dataKeyLen)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, err
+	}
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, 0, 4+len(id)+wrappedLen+nonceLen+len(value)+tagLen)
+	buf = append(buf, magic[0], magic[1], version, byte(len(id)))
+	buf = append(buf, id...)
+	// The header is authenticated with the data key so the key ID cannot
+	// be swapped.
+	header := buf
+	if buf, err = seal(buf, wrap, dataKey, header); err != nil {
+		return nil, err
+	}
+	return seal(buf, aead, value, additionalData(header, key))
+}
+
+func additionalData(header []byte, key string) []byte {
+	ad := make([]byte, 0, len(header)+8+len(key))
+	ad = append(ad, header...)
+	ad = binary.BigEndian.AppendUint64(ad, uint64(len(key)))
+	return append(ad, key...)
+}
+
+func (c *Cache) decrypt(ctx context.Context, key string, raw []byte) ([]byte, error) {
+	if len(raw) < 4 || raw[0] != magic[0] || raw[1] != magic[1] {
+		if c.opts.AllowPlaintext {
+			return raw, nil
+		}
+		return nil, fmt.Errorf("%w: value is not encrypted", cache.ErrDecode)
+	}
+	if raw[2] != version {
+		return nil, fmt.Errorf("%w: unknown envelope version %d", cache.ErrDecode, raw[2])
+	}
+	idEnd := 4 + int(raw[3])
+	if len(raw) < idEnd+wrappedLen+nonceLen+tagLen {
+		return nil, fmt.Errorf("%w: truncated envelope", cache.ErrDecode)
+	}
+	id := string(raw[4:idEnd])
+	header := raw[:idEnd]
+	kek, err := c.opts.Keys.Key(ctx, id)
+	if err != nil {
+		if errors.Is(err, ErrUnknownKey) {
+			return nil, fmt.Errorf("%w: %w", cache.ErrDecode, err)
+		}
+		return nil, err
+	}
+	wrap, err := newGCM(kek)
+	if err != nil {
+		return nil, fmt.Errorf("encrypt: key %q: %w", id, err)
+	}
+	wrapped := raw[idEnd : idEnd+wrappedLen]
+	dataKey, err := wrap.Open(nil, wrapped[:nonceLen], wrapped[nonceLen:], header)
+	if err != nil {
+		return nil, fmt.Errorf("%w: data key: %w", cache.ErrDecode, err)
+	}
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	sealed := raw[idEnd+wrappedLen:]
+	value, err := aead.Open(nil, sealed[:nonceLen], sealed[nonceLen:], additionalData(header, key))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", cache.ErrDecode, err)
+	}
+	return value, nil
+}
+
+func (c *Cache) Set(ctx context.Context, key, value string) error {
+	return c.SetBytes(ctx, key, []byte(value))
+}
+
+func (c *Cache) SetBytes(ctx context.Context, key string, value []byte) error {
+	data, err := c.encrypt(ctx, key, value)
+	if err != nil {
+		return err
+	}
+	return c.next.SetBytes(ctx, key, data)
+}
+
+func (c *Cache) SetWithTTL(ctx context.Context, key, value string, ttl time.Duration) error {
+	data, err := c.encrypt(ctx, key, []byte(value))
+	if err != nil {
+		return err
+	}
+	return c.next.SetWithTTL(ctx, key, string(data), ttl)
+}
+
+func (c *Cache) Get(ctx context.Context, key string) (string, error) {
+	b, err := c.GetBytes(ctx, key)
+	return string(b), err
+}
+
+// GetBytes returns the decrypted value for key. Values that cannot be
+// decrypted, including those sealed under a key the provider no longer
+// knows, return an error wrapping cache.ErrDecode.
+func (c *Cache) GetBytes(ctx context.Context, key string) ([]byte, error) {
+	raw, err := c.next.GetBytes(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return c.decrypt(ctx, key, raw)
+}
+
+func (c *Cache) Del(ctx context.Context, keys ...string) error {
+	return c.next.Del(ctx, keys...)
+}
+
+func (c *Cache) Exists(ctx context.Context, key string) (bool, error) {
+	return c.next.Exists(ctx, key)
+}
+
+func (c *Cache) TTL(ctx context.Context, key string) (time.Duration, error) {
+	return c.next.TTL(ctx, key)
+}
+
+func (c *Cache) Expire(ctx context.Context, key string, ttl time.Duration) error {
+	return c.next.Expire(ctx, key, ttl)
+}
+
+func (c *Cache) Persist(ctx context.Context, key string) error {
+	return c.next.Persist(ctx, key)
+}
+
+func (c *Cache) Touch(ctx context.Context, key string) error {
+	return c.next.Touch(ctx, key)
+}
+
+var _ cache.Cache = (*Cache)(nil)
@@ -0,0 +1,131 @@
+package encrypt
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/carlosealves2/go-infrakit/cache"
+	"github.com/carlosealves2/go-infrakit/cache/memory"
+	"github.com/carlosealves2/go-infrakit/cache/redis"
+	"github.com/carlosealves2/go-infrakit/internal/redistest"
+)
+
+func newDrivers(t *testing.T) map[string]cache.Cache {
+	r, err := redis.New(cache.Options{Addr: redistest.Serve(t, &redistest.Server{})})
+	if err != nil {
+		t.Fatalf("new redis: %v", err)
+	}
+	m := memory.New(cache.Options{})
+	t.Cleanup(func() { m.Close() })
+	return map[string]cache.Cache{"memory": m, "redis": r}
+}
+
+func mustNew(t *testing.T, next cache.Cache, opts Options) *Cache {
+	t.Helper()
+	c, err := New(next, opts)
+	if err != nil {
+		t.Fatalf("new encrypt: %v", err)
+	}
+	return c
+}
+
+func testKeys(current string) StaticKeys {
+	return StaticKeys{Current: current, Keys: map[string][]byte{
+		"k1": bytes.Repeat([]byte{1}, 32),
+		"k2": bytes.Repeat([]byte{2}, 16),
+	}}
+}
+
+func TestEncryptRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	for name, next := range newDrivers(t) {
+		t.Run(name, func(t *testing.T) {
+			c := mustNew(t, next, Options{Keys: testKeys("k1")})
+			c.Set(ctx, "set", "alice@example.com")
+			c.SetBytes(ctx, "bytes", []byte{0, 1, 2})
+			if err := c.SetWithTTL(ctx, "ttl", "secret", time.Minute); err != nil {
+				t.Fatalf("set with ttl: %v", err)
+			}
+			for key, want := range map[string]string{"set": "alice@example.com", "bytes": "\x00\x01\x02", "ttl": "secret"} {
+				raw, _ := next.Get(ctx, key)
+				if raw == want || bytes.Contains([]byte(raw), []byte(want)) {
+					t.Fatalf("%s stored in plaintext", key)
+				}
+				if v, err := c.Get(ctx, key); err != nil || v != want {
+					t.Fatalf("get %s: %v %q", key, err, v)
+				}
+			}
+			if ttl, _ := c.TTL(ctx, "ttl"); ttl <= 0 {
+				t.Fatalf("expected ttl to be kept, got %v", ttl)
+			}
+		})
+	}
+}
+
+func TestEncryptKeyRotation(t *testing.T) {
+	ctx := context.Background()
+	next := memory.New(cache.Options{})
+	defer next.Close()
+	mustNew(t, next, Options{Keys: testKeys("k1")}).Set(ctx, "old", "v1")
+
+	rotated := mustNew(t, next, Options{Keys: testKeys("k2")})
+	rotated.Set(ctx, "new", "v2")
+	for key, want := range map[string]string{"old": "v1", "new": "v2"} {
+		if v, err := rotated.Get(ctx, key); err != nil || v != want {
+			t.Fatalf("get %s after rotation: %v %q", key, err, v)
+		}
+	}
+
+	retired := mustNew(t, next, Options{Keys: StaticKeys{Current: "k2", Keys: map[string][]byte{"k2": testKeys("").Keys["k2"]}}})
+	if _, err := retired.Get(ctx, "old"); !errors.Is(err, cache.ErrDecode) || !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("expected ErrDecode for retired key, got %v", err)
+	}
+}
+
+func TestEncryptTampering(t *testing.T) {
+	ctx := context.Background()
+	next := memory.New(cache.Options{})
+	defer next.Close()
+	c := mustNew(t, next, Options{Keys: testKeys("k1")})
+	c.Set(ctx, "a", "value")
+
+	// Replaying an entry under another key fails authentication.
+	raw, _ := next.GetBytes(ctx, "a")
+	next.SetBytes(ctx, "b", raw)
+	if _, err := c.Get(ctx, "b"); !errors.Is(err, cache.ErrDecode) {
+		t.Fatalf("expected ErrDecode for replayed entry, got %v", err)
+	}
+
+	flipped := append([]byte(nil), raw...)
+	flipped[len(flipped)-1] ^= 1
+	next.SetBytes(ctx, "a", flipped)
+	if _, err := c.Get(ctx, "a"); !errors.Is(err, cache.ErrDecode) {
+		t.Fatalf("expected ErrDecode for tampered entry, got %v", err)
+	}
+
+	next.Set(ctx, "plain", "legacy")
+	if _, err := c.Get(ctx, "plain"); !errors.Is(err, cache.ErrDecode) {
+		t.Fatalf("expected ErrDecode for plaintext, got %v", err)
+	}
+	lenient := mustNew(t, next, Options{Keys: testKeys("k1"), AllowPlaintext: true})
+	if v, err := lenient.Get(ctx, "plain"); err != nil || v != "legacy" {
+		t.Fatalf("expected plaintext to be allowed: %v %q", err, v)
+	}
+}
+
+func TestEncryptNewValidates(t *testing.T) {
+	next := memory.New(cache.Options{})
+	defer next.Close()
+	for name, opts := range map[string]Options{
+		"no provider": {},
+		"unknown key": {Keys: testKeys("k3")},
+		"short key":   {Keys: StaticKeys{Current: "k", Keys: map[string][]byte{"k": []byte("short")}}},
+	} {
+		if _, err := New(next, opts); err == nil {
+			t.Fatalf("%s: expected an error", name)
+		}
+	}
+}
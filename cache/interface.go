@@ -5,8 +5,16 @@ import (
 	"time"
 )
 
+// NoExpiration is returned by TTL for keys that never expire.
+const NoExpiration time.Duration = -1
+
 // Cache is the unified cache interface for memory and Redis providers.
 // It is intentionally string-focused with optional byte helpers.
+//
+// The TTL operations return ErrNotFound for missing keys. TTL reports
+// NoExpiration for keys without a deadline, Expire with a non-positive ttl
+// deletes the key, Persist removes the deadline and Touch records an access
+// for eviction purposes without reading the value.
 type Cache interface {
 	Set(ctx context.Context, key, value string) error
 	Get(ctx context.Context, key string) (string, error)
@@ -15,4 +23,164 @@ type Cache interface {
 	SetWithTTL(ctx context.Context, key, value string, ttl time.Duration) error
 	SetBytes(ctx context.Context, key string, value []byte) error
 	GetBytes(ctx context.Context, key string) ([]byte, error)
+	TTL(ctx context.Context, key string) (time.Duration, error)
+	Expire(ctx context.Context, key string, ttl time.Duration) error
+	Persist(ctx context.Context, key string) error
+	Touch(ctx context.Context, key string) error
+}
+
+// BatchCache is implemented by drivers that read and write several keys in
+// one round trip. MGet leaves missing keys out of the result instead of
+// failing the call.
+type BatchCache interface {
+	MGet(ctx context.Context, keys ...string) (map[string][]byte, error)
+	MSet(ctx context.Context, items map[string][]byte) error
+	MSetWithTTL(ctx context.Context, items map[string][]byte, ttl time.Duration) error
+}
+
+// CounterCache is implemented by drivers with atomic increments. Counters
+// are stored as decimal strings, so they can be read back with Get. A
+// missing key counts from zero.
+type CounterCache interface {
+	Incr(ctx context.Context, key string, opts ...IncrOption) (int64, error)
+	Decr(ctx context.Context, key string, opts ...IncrOption) (int64, error)
+	IncrBy(ctx context.Context, key string, delta int64, opts ...IncrOption) (int64, error)
+	IncrByFloat(ctx context.Context, key string, delta float64, opts ...IncrOption) (float64, error)
+}
+
+// ConditionalCache is implemented by drivers supporting conditional writes
+// such as set-if-absent and compare-and-swap. Writes whose condition does
+// not hold return ErrConditionNotMet.
+type ConditionalCache interface {
+	SetWithOptions(ctx context.Context, key string, value []byte, opts SetOptions) error
+	// DelIfEquals deletes key only while it holds expected.
+	DelIfEquals(ctx context.Context, key string, expected []byte) error
+}
+
+// FencedCache is implemented by drivers that can take a key and draw a
+// fencing token for it in one atomic step, so a writer that stalls between
+// the two can never hold an older key with a newer token.
+type FencedCache interface {
+	// SetIfAbsentFenced increments the counter at fence and stores value,
+	// followed by ":" and the new count, under key for ttl. Both happen
+	// only when key is absent; otherwise it returns ErrConditionNotMet and
+	// the counter is left alone. On Redis Cluster key and fence must share
+	// a hash tag.
+	SetIfAbsentFenced(ctx context.Context, key, fence string, value []byte, ttl time.Duration) (int64, error)
+}
+
+// KeyIterator walks the keys produced by ScanCache.Scan. Keys are reported
+// without the namespace prefix.
+type KeyIterator interface {
+	// Next advances to the next key and reports whether there is one.
+	Next(ctx context.Context) bool
+	Key() string
+	// Err returns the error that stopped the iteration, if any.
+	Err() error
+}
+
+// ScanCache is implemented by drivers that can enumerate their keys without
+// blocking the backend. Patterns use Redis glob syntax and apply to keys
+// within the namespace. Keys written or deleted during a scan may or may
+// not be reported; keys present for the whole scan are reported at least
+// once.
+type ScanCache interface {
+	Scan(ctx context.Context, pattern string) KeyIterator
+	// FlushNamespace deletes every key in the namespace and returns how
+	// many were removed. Without a namespace it flushes the whole keyspace.
+	FlushNamespace(ctx context.Context) (int64, error)
+}
+
+// TagCache is implemented by drivers that can invalidate groups of keys by
+// tag. Tags belong to the write that set them: rewriting a key without
+// tags detaches it, although on Redis invalidating a former tag may still
+// remove the rewritten key.
+type TagCache interface {
+	// SetWithTags stores value under key with ttl, or without expiration
+	// when ttl is zero, and attaches tags to it.
+	SetWithTags(ctx context.Context, key string, value []byte, ttl time.Duration, tags ...string) error
+	// InvalidateTags deletes every key carrying any of tags.
+	InvalidateTags(ctx context.Context, tags ...string) error
+}
+
+// NegativeCache is implemented by drivers that can remember that a key has
+// no value, sparing the source another lookup. The marker is distinct from
+// an empty value: Get and GetBytes return ErrNotFound for it like for a
+// missing key, IsAbsent tells the two apart, and MGet and Exists report the
+// key as missing. Other operations see a key that holds no number and
+// equals no value. Unconditional writes replace the marker and Del removes
+// it.
+type NegativeCache interface {
+	// SetAbsent marks key as absent for ttl, or without expiration when
+	// ttl is zero.
+	SetAbsent(ctx context.Context, key string, ttl time.Duration) error
+	// IsAbsent reports whether key holds an absent marker.
+	IsAbsent(ctx context.Context, key string) (bool, error)
+}
+
+// HashCache is implemented by drivers that store maps of fields under a
+// key, so one field can change without rewriting the others. A missing key
+// reads as an empty hash and is created by the first write; removing its
+// last field deletes it. Hash operations on a key holding another kind of
+// value return ErrWrongType.
+type HashCache interface {
+	HSet(ctx context.Context, key string, fields map[string][]byte) error
+	// HGet returns ErrNotFound when the key or the field is missing.
+	HGet(ctx context.Context, key, field string) ([]byte, error)
+	HGetAll(ctx context.Context, key string) (map[string][]byte, error)
+	HDel(ctx context.Context, key string, fields ...string) error
+	// HIncrBy adds delta to an integer field, starting from zero, and
+	// returns the new value. It returns ErrNotNumber when the field holds
+	// something else.
+	HIncrBy(ctx context.Context, key, field string, delta int64) (int64, error)
+}
+
+// ScoredMember is a sorted set member with its score.
+type ScoredMember struct {
+	Member string
+	Score  float64
+}
+
+// SortedSetCache is implemented by drivers that keep members ordered by
+// score, ties broken by member. Score bounds are inclusive; pass
+// math.Inf(-1) or math.Inf(1) to leave a side open. Ranks start at zero
+// with the lowest score, and negative ranks count from the highest. Like
+// hashes, a missing key reads as an empty set, removing the last member
+// deletes it and other kinds of value return ErrWrongType.
+type SortedSetCache interface {
+	// ZAdd inserts members or updates their scores.
+	ZAdd(ctx context.Context, key string, members ...ScoredMember) error
+	ZRem(ctx context.Context, key string, members ...string) error
+	// ZScore and ZRank return ErrNotFound when the member is missing.
+	ZScore(ctx context.Context, key, member string) (float64, error)
+	ZRank(ctx context.Context, key, member string) (int64, error)
+	ZCard(ctx context.Context, key string) (int64, error)
+	// ZRange returns the members ranked start through stop.
+	ZRange(ctx context.Context, key string, start, stop int64) ([]ScoredMember, error)
+	ZRangeByScore(ctx context.Context, key string, min, max float64) ([]ScoredMember, error)
+	// ZRemRangeByScore removes the members scored within the bounds and
+	// returns how many were removed.
+	ZRemRangeByScore(ctx context.Context, key string, min, max float64) (int64, error)
+}
+
+// ListCache is implemented by drivers that keep ordered lists of values,
+// usable as queues or bounded recent-item lists. Indexes start at zero from
+// the left and negative indexes count from the right end, so -1 is the last
+// value. Like hashes, a missing key reads as an empty list, popping the
+// last value deletes it and other kinds of value return ErrWrongType.
+type ListCache interface {
+	// LPush and RPush add values one by one to the left or right end.
+	LPush(ctx context.Context, key string, values ...[]byte) error
+	RPush(ctx context.Context, key string, values ...[]byte) error
+	// LPop and RPop return ErrNotFound when the list is empty.
+	LPop(ctx context.Context, key string) ([]byte, error)
+	RPop(ctx context.Context, key string) ([]byte, error)
+	// BLPop and BRPop wait for a value while the list is empty. They
+	// return ErrTimeout once ctx is done.
+	BLPop(ctx context.Context, key string) ([]byte, error)
+	BRPop(ctx context.Context, key string) ([]byte, error)
+	LRange(ctx context.Context, key string, start, stop int64) ([][]byte, error)
+	// LTrim keeps only the values from start through stop.
+	LTrim(ctx context.Context, key string, start, stop int64) error
+	LLen(ctx context.Context, key string) (int64, error)
 }
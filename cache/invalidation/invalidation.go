@@ -0,0 +1,302 @@
+// Package invalidation keeps in-process memory caches consistent across
+// instances.
+//
+// A Bus publishes key and namespace invalidations over a Transport, such as
+// the Redis driver's pub/sub Transport, and applies the invalidations it
+// receives to the memory caches attached to it. Each instance numbers its
+// messages, so a receiver that sees a gap in the sequence knows it missed
+// something and flushes its attached caches. The same happens whenever the
+// subscription is re-established after a disconnect.
+package invalidation
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/carlosealves2/go-infrakit/cache"
+	"github.com/carlosealves2/go-infrakit/cache/memory"
+	"github.com/carlosealves2/go-infrakit/observability/logger"
+)
+
+// Transport broadcasts payloads to every subscribed instance, including
+// the publisher.
+type Transport interface {
+	Publish(ctx context.Context, payload []byte) error
+	// Subscribe delivers payloads until ctx is done or the subscription
+	// breaks. It calls ready each time the subscription is established,
+	// including after reconnecting internally. Callbacks are called
+	// sequentially.
+	Subscribe(ctx context.Context, ready func(), deliver func(payload []byte)) error
+}
+
+// Options configures a Bus.
+type Options struct {
+	Transport Transport
+	// RetryInterval is the wait between subscription attempts after
+	// Subscribe fails. It defaults to one second.
+	RetryInterval time.Duration
+
+	Logger logger.Logger
+}
+
+// message is the wire format of an invalidation. A message without keys
+// invalidates the whole namespace.
+type message struct {
+	Origin    string   `json:"o"`
+	Seq       uint64   `json:"s"`
+	Namespace string   `json:"n"`
+	Keys      []string `json:"k,omitempty"`
+}
+
+// Bus publishes and applies invalidations.
+type Bus struct {
+	opts   Options
+	origin string
+	seq    atomic.Uint64
+
+	mu      sync.Mutex
+	caches  map[string][]*memory.Cache
+	lastSeq map[string]uint64
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// New creates a Bus and starts its subscription. Attach caches before they
+// serve traffic so no invalidation is missed.
+func New(opts Options) *Bus {
+	if opts.RetryInterval <= 0 {
+		opts.RetryInterval = time.Second
+	}
+	id := make([]byte, 8)
+	_, _ = rand.Read(id)
+	ctx, cancel := context.WithCancel(context.Background())
+	b := &Bus{
+		opts:    opts,
+		origin:  hex.EncodeToString(id),
+		caches:  make(map[string][]*memory.Cache),
+		lastSeq: make(map[string]uint64),
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+	go b.run(ctx)
+	return b
+}
+
+// Attach registers c to receive invalidations for its namespace.
+func (b *Bus) Attach(c *memory.Cache) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	ns := c.Namespace()
+	b.caches[ns] = append(b.caches[ns], c)
+}
+
+// Close stops the subscription. Attached caches are left open.
+func (b *Bus) Close() error {
+	b.cancel()
+	<-b.done
+	return nil
+}
+
+// Invalidate evicts keys of namespace from the local caches and publishes
+// the invalidation to the other instances.
+func (b *Bus) Invalidate(ctx context.Context, namespace string, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	b.apply(ctx, namespace, keys)
+	return b.publish(ctx, namespace, keys)
+}
+
+// InvalidateNamespace flushes namespace locally and on the other instances.
+func (b *Bus) InvalidateNamespace(ctx context.Context, namespace string) error {
+	b.apply(ctx, namespace, nil)
+	return b.publish(ctx, namespace, nil)
+}
+
+func (b *Bus) publish(ctx context.Context, namespace string, keys []string) error {
+	payload, err := json.Marshal(message{
+		Origin:    b.origin,
+		Seq:       b.seq.Add(1),
+		Namespace: namespace,
+		Keys:      keys,
+	})
+	if err != nil {
+		return err
+	}
+	return b.opts.Transport.Publish(ctx, payload)
+}
+
+// apply evicts keys, or the whole namespace when keys is empty, from the
+// attached caches.
+func (b *Bus) apply(ctx context.Context, namespace string, keys []string) {
+	b.mu.Lock()
+	caches := b.caches[namespace]
+	b.mu.Unlock()
+	for _, c := range caches {
+		if len(keys) == 0 {
+			c.FlushNamespace(ctx)
+		} else {
+			c.Del(ctx, keys...)
+		}
+	}
+}
+
+// flushAll empties every attached cache after invalidations may have been
+// lost, and forgets the sequence numbers seen so far.
+func (b *Bus) flushAll(ctx context.Context, reason string) {
+	b.mu.Lock()
+	var caches []*memory.Cache
+	for _, cs := range b.caches {
+		caches = append(caches, cs...)
+	}
+	clear(b.lastSeq)
+	b.mu.Unlock()
+	for _, c := range caches {
+		c.FlushNamespace(ctx)
+	}
+	b.log(nil, "flush", reason)
+}
+
+func (b *Bus) run(ctx context.Context) {
+	defer close(b.done)
+	subscribed := false
+	ready := func() {
+		if subscribed {
+			b.flushAll(ctx, "resubscribe")
+		}
+		subscribed = true
+	}
+	deliver := func(payload []byte) { b.receive(ctx, payload) }
+	for {
+		err := b.opts.Transport.Subscribe(ctx, ready, deliver)
+		if ctx.Err() != nil {
+			return
+		}
+		b.log(err, "subscribe", "")
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(b.opts.RetryInterval):
+		}
+	}
+}
+
+func (b *Bus) receive(ctx context.Context, payload []byte) {
+	var m message
+	if err := json.Unmarshal(payload, &m); err != nil {
+		b.log(err, "receive", "")
+		return
+	}
+	if m.Origin == b.origin {
+		return
+	}
+	b.mu.Lock()
+	last, known := b.lastSeq[m.Origin]
+	if m.Seq > last {
+		b.lastSeq[m.Origin] = m.Seq
+	}
+	b.mu.Unlock()
+	if known && m.Seq > last+1 {
+		b.flushAll(ctx, "gap")
+		return
+	}
+	b.apply(ctx, m.Namespace, m.Keys)
+}
+
+func (b *Bus) log(err error, op, reason string) {
+	if b.opts.Logger == nil {
+		return
+	}
+	entry := b.opts.Logger.Info()
+	if err != nil {
+		entry = b.opts.Logger.Error().Err(err)
+	}
+	entry = entry.Str("mod", "cache").Str("provider", "invalidation").Str("op", op)
+	if reason != "" {
+		entry = entry.Str("reason", reason)
+	}
+	entry.Msg("")
+}
+
+// Cache publishes an invalidation after every successful write or delete
+// through it, so other instances drop their local copies.
+type Cache struct {
+	next cache.Cache
+	bus  *Bus
+	ns   string
+}
+
+// Wrap returns next publishing invalidations for namespace. next is
+// expected to update its own local tier, as the tiered driver does, so the
+// publishing instance does not evict what it just wrote.
+func (b *Bus) Wrap(next cache.Cache, namespace string) *Cache {
+	return &Cache{next: next, bus: b, ns: namespace}
+}
+
+// notify publishes an invalidation for keys once the write succeeded. A
+// failed publish is logged rather than returned because the write itself
+// went through; other instances catch up when their L1 entries expire.
+func (c *Cache) notify(ctx context.Context, err error, keys ...string) error {
+	if err != nil {
+		return err
+	}
+	if err := c.bus.publish(ctx, c.ns, keys); err != nil {
+		c.bus.log(err, "publish", "")
+	}
+	return nil
+}
+
+func (c *Cache) Set(ctx context.Context, key, value string) error {
+	return c.notify(ctx, c.next.Set(ctx, key, value), key)
+}
+
+func (c *Cache) SetBytes(ctx context.Context, key string, value []byte) error {
+	return c.notify(ctx, c.next.SetBytes(ctx, key, value), key)
+}
+
+func (c *Cache) SetWithTTL(ctx context.Context, key, value string, ttl time.Duration) error {
+	return c.notify(ctx, c.next.SetWithTTL(ctx, key, value, ttl), key)
+}
+
+func (c *Cache) Get(ctx context.Context, key string) (string, error) {
+	return c.next.Get(ctx, key)
+}
+
+func (c *Cache) GetBytes(ctx context.Context, key string) ([]byte, error) {
+	return c.next.GetBytes(ctx, key)
+}
+
+func (c *Cache) Del(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	return c.notify(ctx, c.next.Del(ctx, keys...), keys...)
+}
+
+func (c *Cache) Exists(ctx context.Context, key string) (bool, error) {
+	return c.next.Exists(ctx, key)
+}
+
+func (c *Cache) TTL(ctx context.Context, key string) (time.Duration, error) {
+	return c.next.TTL(ctx, key)
+}
+
+func (c *Cache) Expire(ctx context.Context, key string, ttl time.Duration) error {
+	return c.notify(ctx, c.next.Expire(ctx, key, ttl), key)
+}
+
+func (c *Cache) Persist(ctx context.Context, key string) error {
+	return c.next.Persist(ctx, key)
+}
+
+func (c *Cache) Touch(ctx context.Context, key string) error {
+	return c.next.Touch(ctx, key)
+}
+
+var _ cache.Cache = (*Cache)(nil)
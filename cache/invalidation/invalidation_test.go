@@ -0,0 +1,183 @@
+package invalidation
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/carlosealves2/go-infrakit/cache"
+	"github.com/carlosealves2/go-infrakit/cache/memory"
+	redisdrv "github.com/carlosealves2/go-infrakit/cache/redis"
+	"github.com/carlosealves2/go-infrakit/cache/tiered"
+	"github.com/carlosealves2/go-infrakit/internal/redistest"
+)
+
+func eventually(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("condition not met in time")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func cached(c *memory.Cache, key string) bool {
+	ok, _ := c.Exists(context.Background(), key)
+	return ok
+}
+
+func TestBusOverRedis(t *testing.T) {
+	ctx := context.Background()
+	l2, err := redisdrv.New(cache.Options{Addr: redistest.Serve(t, &redistest.Server{}), Namespace: "app"})
+	if err != nil {
+		t.Fatalf("new redis: %v", err)
+	}
+	opts := cache.Options{Namespace: "app", L1TTL: time.Minute}
+	pods := make([]*tiered.Cache, 2)
+	buses := make([]*Bus, 2)
+	for i := range pods {
+		pods[i] = tiered.NewWithTiers(memory.New(opts), l2, opts)
+		buses[i] = New(Options{Transport: l2.Transport("invalidations")})
+		buses[i].Attach(pods[i].L1())
+		defer buses[i].Close()
+		defer pods[i].Close()
+	}
+	a := buses[0].Wrap(pods[0], "app")
+
+	// Wait until the second pod is subscribed.
+	eventually(t, func() bool {
+		pods[1].L1().Set(ctx, "probe", "1")
+		buses[0].InvalidateNamespace(ctx, "app")
+		time.Sleep(5 * time.Millisecond)
+		return !cached(pods[1].L1(), "probe")
+	})
+
+	a.Set(ctx, "user:1", "v1")
+	if v, _ := pods[1].Get(ctx, "user:1"); v != "v1" {
+		t.Fatalf("expected pod 1 to read through, got %q", v)
+	}
+	if !cached(pods[1].L1(), "user:1") {
+		t.Fatalf("expected pod 1 to cache the value locally")
+	}
+	if err := a.Set(ctx, "user:1", "v2"); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	eventually(t, func() bool { return !cached(pods[1].L1(), "user:1") })
+	if v, _ := pods[1].Get(ctx, "user:1"); v != "v2" {
+		t.Fatalf("expected new value after invalidation, got %q", v)
+	}
+	if v, _ := pods[0].L1().Get(ctx, "user:1"); v != "v2" {
+		t.Fatalf("the writer must keep its own copy, got %q", v)
+	}
+
+	a.Del(ctx, "user:1")
+	eventually(t, func() bool { return !cached(pods[1].L1(), "user:1") })
+}
+
+// fakeTransport broadcasts in process and can drop messages or break the
+// subscription to simulate a flaky connection.
+type fakeTransport struct {
+	mu    sync.Mutex
+	subs  map[chan []byte]struct{}
+	drop  bool
+	broke chan struct{}
+}
+
+func newFakeTransport() *fakeTransport {
+	return &fakeTransport{subs: make(map[chan []byte]struct{}), broke: make(chan struct{})}
+}
+
+func (f *fakeTransport) Publish(ctx context.Context, payload []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.drop {
+		return nil
+	}
+	for ch := range f.subs {
+		ch <- payload
+	}
+	return nil
+}
+
+func (f *fakeTransport) Subscribe(ctx context.Context, ready func(), deliver func([]byte)) error {
+	ch := make(chan []byte, 16)
+	f.mu.Lock()
+	f.subs[ch] = struct{}{}
+	broke := f.broke
+	f.mu.Unlock()
+	defer func() {
+		f.mu.Lock()
+		delete(f.subs, ch)
+		f.mu.Unlock()
+	}()
+	ready()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-broke:
+			return errors.New("connection lost")
+		case p := <-ch:
+			deliver(p)
+		}
+	}
+}
+
+func (f *fakeTransport) subscribers() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.subs)
+}
+
+// breakAll ends every current subscription.
+func (f *fakeTransport) breakAll() {
+	f.mu.Lock()
+	close(f.broke)
+	f.broke = make(chan struct{})
+	f.mu.Unlock()
+}
+
+func TestBusGapDetection(t *testing.T) {
+	ctx := context.Background()
+	tr := newFakeTransport()
+	local := memory.New(cache.Options{Namespace: "app"})
+	defer local.Close()
+	other := memory.New(cache.Options{Namespace: "other"})
+	defer other.Close()
+	receiver := New(Options{Transport: tr, RetryInterval: time.Millisecond})
+	defer receiver.Close()
+	receiver.Attach(local)
+	receiver.Attach(other)
+	sender := New(Options{Transport: tr})
+	defer sender.Close()
+	eventually(t, func() bool { return tr.subscribers() == 2 })
+
+	local.Set(ctx, "a", "1")
+	local.Set(ctx, "b", "1")
+	other.Set(ctx, "a", "1")
+	sender.Invalidate(ctx, "app", "a")
+	eventually(t, func() bool { return !cached(local, "a") })
+	if !cached(local, "b") || !cached(other, "a") {
+		t.Fatalf("only the invalidated key of the namespace must be evicted")
+	}
+
+	// A lost message shows up as a gap and flushes every attached cache.
+	tr.mu.Lock()
+	tr.drop = true
+	tr.mu.Unlock()
+	sender.Invalidate(ctx, "app", "x")
+	tr.mu.Lock()
+	tr.drop = false
+	tr.mu.Unlock()
+	sender.Invalidate(ctx, "app", "y")
+	eventually(t, func() bool { return !cached(local, "b") && !cached(other, "a") })
+
+	// Re-subscribing after a disconnect flushes as well.
+	local.Set(ctx, "c", "1")
+	tr.breakAll()
+	eventually(t, func() bool { return !cached(local, "c") })
+}
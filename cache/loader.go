@@ -0,0 +1,171 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// LoadFunc produces the value for a missing key.
+type LoadFunc func(ctx context.Context) ([]byte, error)
+
+// Locker coalesces loads across processes. TryLock takes a lease on the
+// lock called name for ttl and reports false when another holder owns it;
+// unlock releases the lease only while it is still held by the caller.
+// Implementations store locks apart from cache keys; lock.Locker from
+// cache/lock implements Locker.
+type Locker interface {
+	TryLock(ctx context.Context, name string, ttl time.Duration) (unlock func(context.Context) error, ok bool, err error)
+}
+
+// Loader implements read-through caching on top of any Cache. Concurrent
+// misses for the same key within the process share one loader call.
+type Loader struct {
+	cache   Cache
+	group   group
+	locker  Locker
+	lockTTL time.Duration
+	poll    time.Duration
+	timeout time.Duration
+
+	negative    NegativeCache
+	negativeTTL time.Duration
+}
+
+// LoaderOption configures a Loader.
+type LoaderOption func(*Loader)
+
+// WithLocker coalesces loads across instances: only the holder of the lock
+// runs the loader while the others wait up to ttl for its result. The lock
+// for key is called "load:" followed by key.
+func WithLocker(l Locker, ttl time.Duration) LoaderOption {
+	return func(ld *Loader) {
+		ld.locker = l
+		ld.lockTTL = ttl
+	}
+}
+
+// WithLockPoll sets how often instances waiting on another holder's load
+// check the cache. It defaults to 50ms.
+func WithLockPoll(d time.Duration) LoaderOption {
+	return func(ld *Loader) { ld.poll = d }
+}
+
+// WithLoadTimeout bounds a shared load, which runs on behalf of every
+// caller waiting for the key and so is not canceled with any one of them.
+// It defaults to 30 seconds.
+func WithLoadTimeout(d time.Duration) LoaderOption {
+	return func(ld *Loader) { ld.timeout = d }
+}
+
+// WithNegativeTTL remembers for ttl that the loader found nothing: when it
+// returns ErrNotFound and the cache implements NegativeCache, the key is
+// marked absent and later reads return ErrNotFound without calling the
+// loader.
+func WithNegativeTTL(ttl time.Duration) LoaderOption {
+	return func(ld *Loader) { ld.negativeTTL = ttl }
+}
+
+// NewLoader creates a Loader backed by c.
+func NewLoader(c Cache, opts ...LoaderOption) *Loader {
+	l := &Loader{cache: c, poll: 50 * time.Millisecond, timeout: 30 * time.Second}
+	for _, opt := range opts {
+		opt(l)
+	}
+	if l.negativeTTL > 0 {
+		l.negative, _ = c.(NegativeCache)
+	}
+	return l
+}
+
+// GetOrLoad returns the cached value for key or, on ErrNotFound, calls
+// loader and stores its result with ttl (no expiration when ttl is zero).
+// Loader errors are returned and nothing is cached, except for ErrNotFound
+// with WithNegativeTTL. Keys marked absent return ErrNotFound. A failure to
+// store the loaded value is not reported since the value itself is valid.
+// Canceling ctx returns ErrTimeout to this caller only; the load goes on
+// for the others, up to the load timeout.
+func (l *Loader) GetOrLoad(ctx context.Context, key string, ttl time.Duration, loader LoadFunc) ([]byte, error) {
+	if v, ok, err := l.lookup(ctx, key); ok {
+		return v, err
+	}
+	return l.group.do(ctx, key, func() ([]byte, error) {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), l.timeout)
+		defer cancel()
+		return l.load(ctx, key, ttl, loader)
+	})
+}
+
+func (l *Loader) load(ctx context.Context, key string, ttl time.Duration, loader LoadFunc) ([]byte, error) {
+	// A previous flight may have stored the value after our miss.
+	if v, ok, err := l.lookup(ctx, key); ok && (err == nil || errors.Is(err, ErrNotFound)) {
+		return v, err
+	}
+	if l.locker != nil {
+		unlock, ok, err := l.locker.TryLock(ctx, "load:"+key, l.lockTTL)
+		switch {
+		case err != nil:
+			// The lock only prevents duplicate work; load without it.
+		case ok:
+			defer unlock(context.WithoutCancel(ctx))
+		default:
+			if v, ok, err := l.wait(ctx, key); ok && (err == nil || errors.Is(err, ErrNotFound)) {
+				return v, err
+			}
+		}
+	}
+	v, err := loader(ctx)
+	if err != nil {
+		if l.negative != nil && errors.Is(err, ErrNotFound) {
+			_ = l.negative.SetAbsent(ctx, key, l.negativeTTL)
+		}
+		return nil, err
+	}
+	l.store(ctx, key, v, ttl)
+	return v, nil
+}
+
+// wait polls the cache until the lock holder stores the value or the lock
+// lease runs out, reporting whether a poll answered the lookup.
+func (l *Loader) wait(ctx context.Context, key string) ([]byte, bool, error) {
+	ticker := time.NewTicker(l.poll)
+	defer ticker.Stop()
+	deadline := time.NewTimer(l.lockTTL)
+	defer deadline.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, false, ErrTimeout
+		case <-deadline.C:
+			return nil, false, ErrNotFound
+		case <-ticker.C:
+			if v, ok, err := l.lookup(ctx, key); ok {
+				return v, true, err
+			}
+		}
+	}
+}
+
+// lookup reads key and reports whether the read answered it: with a value,
+// a failure other than a plain miss or, with negative caching, an absent
+// marker, for which ErrNotFound is returned.
+func (l *Loader) lookup(ctx context.Context, key string) ([]byte, bool, error) {
+	v, err := l.cache.GetBytes(ctx, key)
+	if !errors.Is(err, ErrNotFound) {
+		return v, true, err
+	}
+	if l.negative != nil {
+		if absent, aerr := l.negative.IsAbsent(ctx, key); aerr == nil && absent {
+			return nil, true, err
+		}
+	}
+	return nil, false, err
+}
+
+func (l *Loader) store(ctx context.Context, key string, v []byte, ttl time.Duration) {
+	if ttl > 0 {
+		_ = l.cache.SetWithTTL(ctx, key, string(v), ttl)
+		return
+	}
+	_ = l.cache.SetBytes(ctx, key, v)
+}
@@ -0,0 +1,212 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/carlosealves2/go-infrakit/cache"
+	"github.com/carlosealves2/go-infrakit/cache/lock"
+	"github.com/carlosealves2/go-infrakit/cache/memory"
+)
+
+func TestGetOrLoadCoalesces(t *testing.T) {
+	ctx := context.Background()
+	m := memory.New(cache.Options{})
+	defer m.Close()
+	l := cache.NewLoader(m)
+	var calls atomic.Int32
+	loader := func(ctx context.Context) ([]byte, error) {
+		calls.Add(1)
+		time.Sleep(20 * time.Millisecond)
+		return []byte("value"), nil
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := l.GetOrLoad(ctx, "foo", time.Minute, loader)
+			if err != nil || string(v) != "value" {
+				t.Errorf("get or load: %v %s", err, v)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("expected one loader call, got %d", n)
+	}
+	if v, err := m.Get(ctx, "foo"); err != nil || v != "value" {
+		t.Fatalf("expected loaded value to be cached: %v %s", err, v)
+	}
+}
+
+func TestGetOrLoadError(t *testing.T) {
+	ctx := context.Background()
+	m := memory.New(cache.Options{})
+	l := cache.NewLoader(m)
+	boom := errors.New("boom")
+	_, err := l.GetOrLoad(ctx, "foo", 0, func(ctx context.Context) ([]byte, error) {
+		return nil, boom
+	})
+	if err != boom {
+		t.Fatalf("expected loader error, got %v", err)
+	}
+	if _, err := m.Get(ctx, "foo"); err != cache.ErrNotFound {
+		t.Fatalf("loader error must not be cached, got %v", err)
+	}
+}
+
+// heldLocker simulates another instance holding every lock.
+type heldLocker struct{}
+
+func (heldLocker) TryLock(ctx context.Context, key string, ttl time.Duration) (func(context.Context) error, bool, error) {
+	return nil, false, nil
+}
+
+func TestGetOrLoadWaitsForLockHolder(t *testing.T) {
+	ctx := context.Background()
+	m := memory.New(cache.Options{})
+	defer m.Close()
+	l := cache.NewLoader(m, cache.WithLocker(heldLocker{}, time.Second), cache.WithLockPoll(5*time.Millisecond))
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		m.Set(ctx, "foo", "remote")
+	}()
+	v, err := l.GetOrLoad(ctx, "foo", time.Minute, func(ctx context.Context) ([]byte, error) {
+		return []byte("local"), nil
+	})
+	if err != nil || string(v) != "remote" {
+		t.Fatalf("expected value loaded by the lock holder: %v %s", err, v)
+	}
+}
+
+func TestGetOrLoadLockKeyDoesNotCollide(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	m := memory.New(cache.Options{})
+	defer m.Close()
+	// A user key that looks like a lock key must not hold the load back.
+	m.Set(ctx, "foo:lock", "user value")
+	l := cache.NewLoader(m, cache.WithLocker(lock.New(m), time.Minute))
+	v, err := l.GetOrLoad(ctx, "foo", time.Minute, func(ctx context.Context) ([]byte, error) {
+		return []byte("loaded"), nil
+	})
+	if err != nil || string(v) != "loaded" {
+		t.Fatalf("get or load: %v %s", err, v)
+	}
+	if v, err := m.Get(ctx, "foo:lock"); err != nil || v != "user value" {
+		t.Fatalf("the user key must be left alone: %v %s", err, v)
+	}
+}
+
+func TestGetOrLoadNegative(t *testing.T) {
+	ctx := context.Background()
+	m := memory.New(cache.Options{})
+	defer m.Close()
+	l := cache.NewLoader(m, cache.WithNegativeTTL(20*time.Millisecond))
+	var calls atomic.Int32
+	loader := func(ctx context.Context) ([]byte, error) {
+		calls.Add(1)
+		return nil, cache.ErrNotFound
+	}
+	if _, err := l.GetOrLoad(ctx, "foo", 0, loader); err != cache.ErrNotFound {
+		t.Fatalf("expected loader miss, got %v", err)
+	}
+	if _, err := l.GetOrLoad(ctx, "foo", 0, loader); err != cache.ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("expected the marker to spare the loader, got %d calls", n)
+	}
+	time.Sleep(30 * time.Millisecond)
+	l.GetOrLoad(ctx, "foo", 0, loader)
+	if n := calls.Load(); n != 2 {
+		t.Fatalf("expected a reload once the marker expired, got %d calls", n)
+	}
+}
+
+func TestGetOrLoadOutlivesFirstCaller(t *testing.T) {
+	ctx := context.Background()
+	m := memory.New(cache.Options{})
+	defer m.Close()
+	l := cache.NewLoader(m)
+	started := make(chan struct{})
+	loader := func(ctx context.Context) ([]byte, error) {
+		close(started)
+		select {
+		case <-time.After(30 * time.Millisecond):
+			return []byte("value"), nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	first, cancel := context.WithCancel(ctx)
+	errc := make(chan error, 1)
+	go func() {
+		_, err := l.GetOrLoad(first, "foo", 0, loader)
+		errc <- err
+	}()
+	<-started
+	second := make(chan []byte, 1)
+	go func() {
+		v, err := l.GetOrLoad(ctx, "foo", 0, loader)
+		if err != nil {
+			t.Errorf("second caller: %v", err)
+		}
+		second <- v
+	}()
+	time.Sleep(5 * time.Millisecond)
+	cancel()
+	if err := <-errc; err != cache.ErrTimeout {
+		t.Fatalf("expected the first caller to time out, got %v", err)
+	}
+	if v := <-second; string(v) != "value" {
+		t.Fatalf("expected the second caller to get the value, got %q", v)
+	}
+}
+
+func TestGetOrLoadTimeout(t *testing.T) {
+	m := memory.New(cache.Options{})
+	defer m.Close()
+	l := cache.NewLoader(m, cache.WithLoadTimeout(10*time.Millisecond))
+	_, err := l.GetOrLoad(context.Background(), "foo", 0, func(ctx context.Context) ([]byte, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the load to be bounded, got %v", err)
+	}
+}
+
+func TestGetOrLoadPanic(t *testing.T) {
+	ctx := context.Background()
+	m := memory.New(cache.Options{})
+	defer m.Close()
+	l := cache.NewLoader(m)
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := l.GetOrLoad(ctx, "foo", 0, func(ctx context.Context) ([]byte, error) {
+				time.Sleep(10 * time.Millisecond)
+				panic("boom")
+			})
+			if err == nil || !strings.Contains(err.Error(), "boom") {
+				t.Errorf("expected the panic as an error, got %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	v, err := l.GetOrLoad(ctx, "foo", 0, func(ctx context.Context) ([]byte, error) {
+		return []byte("value"), nil
+	})
+	if err != nil || string(v) != "value" {
+		t.Fatalf("expected loads to resume after a panic: %v %s", err, v)
+	}
+}
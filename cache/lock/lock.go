@@ -0,0 +1,232 @@
+// Package lock provides leases for "only one instance runs this" work such
+// as cron jobs and migrations, built on the cache drivers.
+//
+// A lease is a key holding a random owner token and a fencing token.
+// Acquisition draws the fencing token in the same atomic step that takes
+// the key, so tokens grow in the order leases were granted and downstream
+// systems can use them to reject writes from stale holders. Refresh and
+// Release only act while the key still holds both tokens, so a holder whose
+// lease expired cannot extend or delete the lease of the next holder.
+package lock
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/carlosealves2/go-infrakit/cache"
+)
+
+var (
+	// ErrNotAcquired is returned when another holder owns the lock.
+	ErrNotAcquired = errors.New("lock: not acquired")
+	// ErrLeaseLost is returned when the lease expired or was taken over.
+	ErrLeaseLost = errors.New("lock: lease lost")
+	// ErrInvalidTTL is returned by Acquire for a non-positive ttl, which
+	// would make a lease that never expires.
+	ErrInvalidTTL = errors.New("lock: ttl must be positive")
+)
+
+// Backend is the driver capability leases are built on. Both memory.Cache
+// and redis.Cache implement it; the memory driver is only suitable for
+// locks within one process, such as in tests.
+type Backend interface {
+	cache.ConditionalCache
+	cache.FencedCache
+}
+
+// Locker hands out leases stored in a Backend.
+type Locker struct {
+	backend Backend
+	retry   time.Duration
+}
+
+// Option configures a Locker.
+type Option func(*Locker)
+
+// WithRetry makes Acquire wait for the lock, trying again every interval
+// until it is acquired or the context is done.
+func WithRetry(interval time.Duration) Option {
+	return func(l *Locker) { l.retry = interval }
+}
+
+// New creates a Locker over b.
+func New(b Backend, opts ...Option) *Locker {
+	l := &Locker{backend: b}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// Acquire takes the lock called name for ttl. The lease is renewed in the
+// background every ttl/3 for as long as ctx is alive; once ctx is done the
+// renewal stops and the lease expires unless it is released first.
+//
+// The lease is stored under "lock:{name}" and its fencing counter under
+// "lock:{name}:fence"; the braces keep both in one Redis Cluster slot.
+// When ctx is done before the lock is taken, Acquire returns ctx.Err().
+func (l *Locker) Acquire(ctx context.Context, name string, ttl time.Duration) (*Lease, error) {
+	return l.acquire(ctx, name, ttl, l.retry)
+}
+
+// TryLock implements cache.Locker: it takes the lock once, without waiting
+// for it as WithRetry would, and unlock releases the lease.
+func (l *Locker) TryLock(ctx context.Context, name string, ttl time.Duration) (func(context.Context) error, bool, error) {
+	lease, err := l.acquire(ctx, name, ttl, 0)
+	switch {
+	case err == ErrNotAcquired:
+		return nil, false, nil
+	case err != nil:
+		return nil, false, err
+	}
+	return lease.Release, true, nil
+}
+
+func (l *Locker) acquire(ctx context.Context, name string, ttl, retry time.Duration) (*Lease, error) {
+	if ttl <= 0 {
+		return nil, ErrInvalidTTL
+	}
+	owner, err := newOwner()
+	if err != nil {
+		return nil, err
+	}
+	key := "lock:{" + name + "}"
+	for {
+		token, err := l.backend.SetIfAbsentFenced(ctx, key, key+":fence", owner, ttl)
+		if err == nil {
+			lease := &Lease{
+				backend: l.backend,
+				key:     key,
+				value:   strconv.AppendInt(append(owner, ':'), token, 10),
+				token:   token,
+				ttl:     ttl,
+				stop:    make(chan struct{}),
+				done:    make(chan struct{}),
+			}
+			go lease.renew(ctx)
+			return lease, nil
+		}
+		if ctxErr := contextErr(ctx); ctxErr != nil {
+			return nil, ctxErr
+		}
+		if err != cache.ErrConditionNotMet {
+			return nil, err
+		}
+		if retry <= 0 {
+			return nil, ErrNotAcquired
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(retry):
+		}
+	}
+}
+
+// Lease is a held lock.
+type Lease struct {
+	backend Backend
+	key     string
+	value   []byte // owner and fencing token, as stored under key
+	token   int64
+	ttl     time.Duration
+
+	mu      sync.Mutex
+	err     error
+	stopped bool
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// Token returns the fencing token of the lease. Tokens grow with every
+// acquisition of the same lock.
+func (l *Lease) Token() int64 { return l.token }
+
+// Done is closed when automatic renewal stops: after Release, when the
+// holder's context is done, or when the lease was lost.
+func (l *Lease) Done() <-chan struct{} { return l.done }
+
+// Err returns ErrLeaseLost once renewal found the lease taken over.
+func (l *Lease) Err() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.err
+}
+
+// Refresh extends the lease to a full ttl from now.
+func (l *Lease) Refresh(ctx context.Context) error {
+	err := l.backend.SetWithOptions(ctx, l.key, l.value, cache.SetOptions{IfEquals: l.value, TTL: l.ttl})
+	if err == cache.ErrConditionNotMet {
+		return ErrLeaseLost
+	}
+	return err
+}
+
+// Release stops renewal and deletes the lease if it is still held.
+func (l *Lease) Release(ctx context.Context) error {
+	l.halt()
+	err := l.backend.DelIfEquals(ctx, l.key, l.value)
+	if err == cache.ErrConditionNotMet {
+		return ErrLeaseLost
+	}
+	return err
+}
+
+func (l *Lease) halt() {
+	l.mu.Lock()
+	if !l.stopped {
+		l.stopped = true
+		close(l.stop)
+	}
+	l.mu.Unlock()
+}
+
+func (l *Lease) renew(ctx context.Context) {
+	defer close(l.done)
+	ticker := time.NewTicker(max(l.ttl/3, time.Nanosecond))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := l.Refresh(ctx)
+			if err == ErrLeaseLost {
+				l.mu.Lock()
+				l.err = err
+				l.mu.Unlock()
+				return
+			}
+		}
+	}
+}
+
+// contextErr returns ctx.Err(), or context.DeadlineExceeded once the
+// deadline has passed: a driver can time out on the socket deadline derived
+// from ctx before ctx itself reports it.
+func contextErr(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok && !time.Now().Before(deadline) {
+		return context.DeadlineExceeded
+	}
+	return nil
+}
+
+func newOwner() ([]byte, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	return []byte(hex.EncodeToString(b)), nil
+}
+
+var _ cache.Locker = (*Locker)(nil)
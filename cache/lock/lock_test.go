@@ -0,0 +1,193 @@
+package lock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/carlosealves2/go-infrakit/cache"
+	"github.com/carlosealves2/go-infrakit/cache/memory"
+	"github.com/carlosealves2/go-infrakit/cache/redis"
+	"github.com/carlosealves2/go-infrakit/internal/redistest"
+)
+
+func newBackends(t *testing.T) map[string]Backend {
+	r, err := redis.New(cache.Options{Addr: redistest.Serve(t, &redistest.Server{})})
+	if err != nil {
+		t.Fatalf("new redis: %v", err)
+	}
+	m := memory.New(cache.Options{})
+	t.Cleanup(func() { m.Close() })
+	return map[string]Backend{"memory": m, "redis": r}
+}
+
+func TestAcquireRelease(t *testing.T) {
+	ctx := context.Background()
+	for name, b := range newBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			l := New(b)
+			first, err := l.Acquire(ctx, "job", time.Minute)
+			if err != nil {
+				t.Fatalf("acquire: %v", err)
+			}
+			if _, err := l.Acquire(ctx, "job", time.Minute); err != ErrNotAcquired {
+				t.Fatalf("expected ErrNotAcquired, got %v", err)
+			}
+			if err := first.Release(ctx); err != nil {
+				t.Fatalf("release: %v", err)
+			}
+			<-first.Done()
+			second, err := l.Acquire(ctx, "job", time.Minute)
+			if err != nil {
+				t.Fatalf("reacquire: %v", err)
+			}
+			defer second.Release(ctx)
+			if second.Token() <= first.Token() {
+				t.Fatalf("fencing token must grow: %d then %d", first.Token(), second.Token())
+			}
+			if err := first.Release(ctx); err != ErrLeaseLost {
+				t.Fatalf("stale release must not delete the new lease, got %v", err)
+			}
+			if err := first.Refresh(ctx); err != ErrLeaseLost {
+				t.Fatalf("stale refresh must fail, got %v", err)
+			}
+		})
+	}
+}
+
+func TestLeaseRenewal(t *testing.T) {
+	for name, b := range newBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			l := New(b)
+			lease, err := l.Acquire(ctx, "renewed", 30*time.Millisecond)
+			if err != nil {
+				t.Fatalf("acquire: %v", err)
+			}
+			time.Sleep(90 * time.Millisecond)
+			if _, err := l.Acquire(context.Background(), "renewed", time.Minute); err != ErrNotAcquired {
+				t.Fatalf("lease should be renewed while ctx is alive, got %v", err)
+			}
+			cancel()
+			<-lease.Done()
+			time.Sleep(40 * time.Millisecond)
+			next, err := l.Acquire(context.Background(), "renewed", time.Minute)
+			if err != nil {
+				t.Fatalf("lease should expire once ctx is done: %v", err)
+			}
+			next.Release(context.Background())
+		})
+	}
+}
+
+func TestAcquireWithRetry(t *testing.T) {
+	ctx := context.Background()
+	for name, b := range newBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			l := New(b, WithRetry(5*time.Millisecond))
+			held, err := l.Acquire(ctx, "queue", time.Minute)
+			if err != nil {
+				t.Fatalf("acquire: %v", err)
+			}
+			go func() {
+				time.Sleep(20 * time.Millisecond)
+				held.Release(ctx)
+			}()
+			waited, err := l.Acquire(ctx, "queue", time.Minute)
+			if err != nil {
+				t.Fatalf("acquire with retry: %v", err)
+			}
+			waited.Release(ctx)
+		})
+	}
+}
+
+func TestExpiredLeaseTakenOverWhilePaused(t *testing.T) {
+	for name, b := range newBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			l := New(b)
+			// A cancelled context stops renewal, as if the holder paused.
+			paused, stop := context.WithCancel(context.Background())
+			stale, err := l.Acquire(paused, "paused", 20*time.Millisecond)
+			if err != nil {
+				t.Fatalf("acquire: %v", err)
+			}
+			stop()
+			<-stale.Done()
+			time.Sleep(40 * time.Millisecond)
+			ctx := context.Background()
+			next, err := l.Acquire(ctx, "paused", time.Minute)
+			if err != nil {
+				t.Fatalf("reacquire after expiry: %v", err)
+			}
+			defer next.Release(ctx)
+			if next.Token() <= stale.Token() {
+				t.Fatalf("fencing token must grow: %d then %d", stale.Token(), next.Token())
+			}
+			if err := stale.Refresh(ctx); err != ErrLeaseLost {
+				t.Fatalf("stale refresh must fail, got %v", err)
+			}
+			if err := stale.Release(ctx); err != ErrLeaseLost {
+				t.Fatalf("stale release must fail, got %v", err)
+			}
+			if err := next.Refresh(ctx); err != nil {
+				t.Fatalf("new holder must keep the lease: %v", err)
+			}
+		})
+	}
+}
+
+func TestAcquireInvalidTTL(t *testing.T) {
+	for name, b := range newBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			for _, ttl := range []time.Duration{0, -time.Second} {
+				if _, err := New(b).Acquire(context.Background(), "ttl", ttl); err != ErrInvalidTTL {
+					t.Fatalf("ttl %v: expected ErrInvalidTTL, got %v", ttl, err)
+				}
+			}
+		})
+	}
+}
+
+func TestAcquireRetryCancelled(t *testing.T) {
+	for name, b := range newBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			l := New(b, WithRetry(5*time.Millisecond))
+			held, err := l.Acquire(context.Background(), "busy", time.Minute)
+			if err != nil {
+				t.Fatalf("acquire: %v", err)
+			}
+			defer held.Release(context.Background())
+			ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+			defer cancel()
+			if _, err := l.Acquire(ctx, "busy", time.Minute); err != context.DeadlineExceeded {
+				t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+			}
+		})
+	}
+}
+
+func TestTryLock(t *testing.T) {
+	ctx := context.Background()
+	for name, b := range newBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			// TryLock never waits, even with WithRetry.
+			l := New(b, WithRetry(time.Minute))
+			unlock, ok, err := l.TryLock(ctx, "load:k", time.Minute)
+			if err != nil || !ok {
+				t.Fatalf("lock: %v %v", err, ok)
+			}
+			if _, ok, err := l.TryLock(ctx, "load:k", time.Minute); err != nil || ok {
+				t.Fatalf("expected lock to be held: %v %v", err, ok)
+			}
+			if err := unlock(ctx); err != nil {
+				t.Fatalf("unlock: %v", err)
+			}
+			unlock, ok, err = l.TryLock(ctx, "load:k", time.Minute)
+			if err != nil || !ok {
+				t.Fatalf("relock: %v %v", err, ok)
+			}
+			unlock(ctx)
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/carlosealves2/go-infrakit/cache"
+)
+
+// formatKeys namespaces keys and maps each stored key back to the caller's
+// key. It also returns the length of the first key for observation.
+func (c *Cache) formatKeys(keys []string) ([]string, map[string]string, int) {
+	stored := make([]string, len(keys))
+	original := make(map[string]string, len(keys))
+	var firstLen int
+	for i, k := range keys {
+		f, n := c.formatKey(k)
+		if i == 0 {
+			firstLen = n
+		}
+		stored[i] = f
+		original[f] = k
+	}
+	return stored, original, firstLen
+}
+
+// MGet returns the values of the keys that exist, taking each shard lock
+// once for the whole batch.
+func (c *Cache) MGet(ctx context.Context, keys ...string) (map[string][]byte, error) {
+	out := make(map[string][]byte, len(keys))
+	if len(keys) == 0 {
+		return out, nil
+	}
+	stored, original, keyLen := c.formatKeys(keys)
+	start := time.Now()
+	if err := c.checkCtx(ctx); err != nil {
+		c.observe(ctx, "mget", keyLen, false, start, err)
+		return nil, err
+	}
+	for sh, group := range c.groupByShard(stored) {
+		sh.readLock()
+		for _, k := range group {
+			if v, err := sh.getLocked(k); err == nil {
+				out[original[k]] = v
+			}
+		}
+		sh.readUnlock()
+	}
+	c.observe(ctx, "mget", keyLen, len(out) > 0, start, nil, attribute.Int("hits", len(out)))
+	return out, nil
+}
+
+func (c *Cache) MSet(ctx context.Context, items map[string][]byte) error {
+	return c.mset(ctx, items, 0)
+}
+
+func (c *Cache) MSetWithTTL(ctx context.Context, items map[string][]byte, ttl time.Duration) error {
+	return c.mset(ctx, items, ttl)
+}
+
+// mset stores items taking each shard lock once for the whole batch.
+func (c *Cache) mset(ctx context.Context, items map[string][]byte, ttl time.Duration) error {
+	if len(items) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(items))
+	for k := range items {
+		keys = append(keys, k)
+	}
+	stored, original, keyLen := c.formatKeys(keys)
+	start := time.Now()
+	if err := c.checkCtx(ctx); err != nil {
+		c.observe(ctx, "mset", keyLen, false, start, err)
+		return err
+	}
+	var evicted []eviction
+	for sh, group := range c.groupByShard(stored) {
+		sh.mu.Lock()
+		for _, k := range group {
+			v := append([]byte(nil), items[original[k]]...)
+			evicted = append(evicted, sh.setLocked(k, v, ttl)...)
+		}
+		sh.mu.Unlock()
+	}
+	c.observe(ctx, "mset", keyLen, false, start, nil)
+	c.observeEvictions(ctx, evicted)
+	return nil
+}
+
+var _ cache.BatchCache = (*Cache)(nil)
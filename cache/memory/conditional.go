@@ -0,0 +1,135 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/carlosealves2/go-infrakit/cache"
+)
+
+// SetWithOptions performs a conditional write under the shard lock.
+func (c *Cache) SetWithOptions(ctx context.Context, key string, value []byte, opts cache.SetOptions) error {
+	key, keyLen := c.formatKey(key)
+	start := time.Now()
+	if err := c.checkCtx(ctx); err != nil {
+		c.observe(ctx, "set", keyLen, false, start, err)
+		return err
+	}
+	value = append([]byte(nil), value...)
+	sh := c.shardFor(key)
+	sh.mu.Lock()
+	e, exists := sh.lookupLocked(key)
+	var err error
+	var evicted []eviction
+	switch {
+	case opts.IfEquals != nil && exists && !e.isValue():
+		err = cache.ErrWrongType
+	case opts.Mode == cache.SetIfAbsent && exists,
+		opts.Mode == cache.SetIfPresent && !exists,
+		opts.IfEquals != nil && (!exists || e.absent || !bytes.Equal(e.value, opts.IfEquals)):
+		err = cache.ErrConditionNotMet
+	case opts.KeepTTL && exists:
+		evicted = sh.updateLocked(e, value)
+	case opts.KeepTTL:
+		evicted = sh.setLocked(key, value, 0)
+	default:
+		evicted = sh.setLocked(key, value, opts.TTL)
+	}
+	sh.mu.Unlock()
+	c.observe(ctx, "set", keyLen, false, start, err)
+	c.observeEvictions(ctx, evicted)
+	return err
+}
+
+// DelIfEquals deletes key under the shard lock when it holds expected.
+func (c *Cache) DelIfEquals(ctx context.Context, key string, expected []byte) error {
+	key, keyLen := c.formatKey(key)
+	start := time.Now()
+	if err := c.checkCtx(ctx); err != nil {
+		c.observe(ctx, "del", keyLen, false, start, err)
+		return err
+	}
+	sh := c.shardFor(key)
+	sh.mu.Lock()
+	var err error
+	e, ok := sh.lookupLocked(key)
+	switch {
+	case ok && !e.isValue():
+		err = cache.ErrWrongType
+	case ok && !e.absent && bytes.Equal(e.value, expected):
+		sh.deleteLocked(key)
+	default:
+		err = cache.ErrConditionNotMet
+	}
+	sh.mu.Unlock()
+	c.observe(ctx, "del", keyLen, false, start, err)
+	return err
+}
+
+// SetIfAbsentFenced holds the shard locks of key and fence together, taken
+// in shard order, so the write and the increment are one step.
+func (c *Cache) SetIfAbsentFenced(ctx context.Context, key, fence string, value []byte, ttl time.Duration) (int64, error) {
+	key, keyLen := c.formatKey(key)
+	fence, _ = c.formatKey(fence)
+	start := time.Now()
+	if err := c.checkCtx(ctx); err != nil {
+		c.observe(ctx, "set", keyLen, false, start, err)
+		return 0, err
+	}
+	i, j := c.shardIndex(key), c.shardIndex(fence)
+	sh, fsh := c.shards[i], c.shards[j]
+	switch {
+	case i < j:
+		sh.mu.Lock()
+		fsh.mu.Lock()
+	case i > j:
+		fsh.mu.Lock()
+		sh.mu.Lock()
+	default:
+		sh.mu.Lock()
+	}
+	var token int64
+	var err error
+	var evicted []eviction
+	f, counted := fsh.lookupLocked(fence)
+	if _, exists := sh.lookupLocked(key); exists {
+		err = cache.ErrConditionNotMet
+	} else if counted && !f.isValue() {
+		err = cache.ErrWrongType
+	} else if counted {
+		n, perr := strconv.ParseInt(string(f.value), 10, 64)
+		if f.absent || perr != nil || n == math.MaxInt64 {
+			err = cache.ErrNotNumber
+		}
+		token = n
+	}
+	if err == nil {
+		token++
+		next := strconv.AppendInt(nil, token, 10)
+		if counted {
+			evicted = fsh.updateLocked(f, next)
+		} else {
+			evicted = fsh.setLocked(fence, next, 0)
+		}
+		value = strconv.AppendInt(append(append([]byte(nil), value...), ':'), token, 10)
+		evicted = append(evicted, sh.setLocked(key, value, ttl)...)
+	}
+	sh.mu.Unlock()
+	if i != j {
+		fsh.mu.Unlock()
+	}
+	c.observe(ctx, "set", keyLen, false, start, err)
+	c.observeEvictions(ctx, evicted)
+	if err != nil {
+		return 0, err
+	}
+	return token, nil
+}
+
+var (
+	_ cache.ConditionalCache = (*Cache)(nil)
+	_ cache.FencedCache      = (*Cache)(nil)
+)
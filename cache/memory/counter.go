@@ -0,0 +1,102 @@
+package memory
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/carlosealves2/go-infrakit/cache"
+)
+
+func (c *Cache) Incr(ctx context.Context, key string, opts ...cache.IncrOption) (int64, error) {
+	return c.IncrBy(ctx, key, 1, opts...)
+}
+
+func (c *Cache) Decr(ctx context.Context, key string, opts ...cache.IncrOption) (int64, error) {
+	return c.IncrBy(ctx, key, -1, opts...)
+}
+
+// IncrBy adds delta to the integer stored at key under the shard lock.
+func (c *Cache) IncrBy(ctx context.Context, key string, delta int64, opts ...cache.IncrOption) (int64, error) {
+	var n int64
+	err := c.increment(ctx, key, opts, func(cur []byte) ([]byte, error) {
+		if cur != nil {
+			v, err := strconv.ParseInt(string(cur), 10, 64)
+			if err != nil {
+				return nil, cache.ErrNotNumber
+			}
+			n = v
+		}
+		if (delta > 0 && n > math.MaxInt64-delta) || (delta < 0 && n < math.MinInt64-delta) {
+			return nil, cache.ErrNotNumber
+		}
+		n += delta
+		return strconv.AppendInt(nil, n, 10), nil
+	})
+	return n, err
+}
+
+// IncrByFloat adds delta to the number stored at key under the shard lock.
+func (c *Cache) IncrByFloat(ctx context.Context, key string, delta float64, opts ...cache.IncrOption) (float64, error) {
+	var f float64
+	err := c.increment(ctx, key, opts, func(cur []byte) ([]byte, error) {
+		if cur != nil {
+			v, err := strconv.ParseFloat(string(cur), 64)
+			if err != nil {
+				return nil, cache.ErrNotNumber
+			}
+			f = v
+		}
+		f += delta
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil, cache.ErrNotNumber
+		}
+		return strconv.AppendFloat(nil, f, 'f', -1, 64), nil
+	})
+	return f, err
+}
+
+// increment applies next to the current value of key (nil when missing)
+// while holding the shard lock. Existing keys keep their deadline; new keys
+// get the initial TTL from opts.
+func (c *Cache) increment(ctx context.Context, key string, opts []cache.IncrOption, next func(cur []byte) ([]byte, error)) error {
+	key, keyLen := c.formatKey(key)
+	start := time.Now()
+	if err := c.checkCtx(ctx); err != nil {
+		c.observe(ctx, "incr", keyLen, false, start, err)
+		return err
+	}
+	o := cache.ApplyIncrOptions(opts)
+	sh := c.shardFor(key)
+	sh.mu.Lock()
+	var cur []byte
+	e, ok := sh.lookupLocked(key)
+	if ok {
+		cur = e.value
+	}
+	var v []byte
+	var err error
+	switch {
+	case ok && e.absent:
+		err = cache.ErrNotNumber
+	case ok && !e.isValue():
+		err = cache.ErrWrongType
+	default:
+		v, err = next(cur)
+	}
+	var evicted []eviction
+	if err == nil {
+		if ok {
+			evicted = sh.updateLocked(e, v)
+		} else {
+			evicted = sh.setLocked(key, v, o.InitialTTL)
+		}
+	}
+	sh.mu.Unlock()
+	c.observe(ctx, "incr", keyLen, false, start, err)
+	c.observeEvictions(ctx, evicted)
+	return err
+}
+
+var _ cache.CounterCache = (*Cache)(nil)
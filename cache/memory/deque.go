@@ -0,0 +1,72 @@
+package memory
+
+// deque is a growable ring buffer of values.
+type deque struct {
+	buf  [][]byte
+	head int
+	n    int
+}
+
+func (d *deque) len() int { return d.n }
+
+// at returns the value i positions from the front.
+func (d *deque) at(i int) []byte {
+	return d.buf[(d.head+i)%len(d.buf)]
+}
+
+func (d *deque) grow() {
+	if d.n < len(d.buf) {
+		return
+	}
+	buf := make([][]byte, max(2*len(d.buf), 8))
+	for i := range d.n {
+		buf[i] = d.at(i)
+	}
+	d.buf, d.head = buf, 0
+}
+
+func (d *deque) pushFront(v []byte) {
+	d.grow()
+	d.head = (d.head - 1 + len(d.buf)) % len(d.buf)
+	d.buf[d.head] = v
+	d.n++
+}
+
+func (d *deque) pushBack(v []byte) {
+	d.grow()
+	d.buf[(d.head+d.n)%len(d.buf)] = v
+	d.n++
+}
+
+func (d *deque) popFront() []byte {
+	v := d.buf[d.head]
+	d.buf[d.head] = nil
+	d.head = (d.head + 1) % len(d.buf)
+	d.n--
+	return v
+}
+
+func (d *deque) popBack() []byte {
+	i := (d.head + d.n - 1) % len(d.buf)
+	v := d.buf[i]
+	d.buf[i] = nil
+	d.n--
+	return v
+}
+
+// span resolves the inclusive range start..stop, where negative indexes
+// count from the back, to the half-open range [lo, hi).
+func (d *deque) span(start, stop int64) (lo, hi int) {
+	n := int64(d.n)
+	if start < 0 {
+		start = max(n+start, 0)
+	}
+	if stop < 0 {
+		stop = n + stop
+	}
+	stop = min(stop, n-1)
+	if start > stop {
+		return 0, 0
+	}
+	return int(start), int(stop + 1)
+}
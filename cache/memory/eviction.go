@@ -0,0 +1,248 @@
+package memory
+
+import (
+	"container/list"
+
+	"github.com/carlosealves2/go-infrakit/cache"
+)
+
+// policy tracks resident entries and chooses eviction victims.
+// Implementations are not safe for concurrent use; the cache lock guards them.
+type policy interface {
+	// added records a newly inserted entry.
+	added(e *entry)
+	// accessed records a read or overwrite of a resident entry.
+	accessed(e *entry)
+	// removed forgets an entry that was deleted or expired.
+	removed(e *entry)
+	// evict forgets and returns the next victim, or nil when empty.
+	evict() *entry
+	// restored takes back a victim returned by evict that the cache kept
+	// after all, with the standing it had before.
+	restored(e *entry)
+}
+
+// newPolicy returns the policy of the given kind for a shard holding at most
+// maxEntries entries (zero when only bytes are bounded). Unknown kinds fall
+// back to LRU; infrakit.NewCache rejects them before reaching the driver.
+func newPolicy(kind cache.EvictionPolicy, maxEntries int) policy {
+	switch kind {
+	case cache.EvictLFU:
+		return &lfu{buckets: make(map[int]*list.List)}
+	case cache.EvictARC:
+		return newARC(maxEntries)
+	default:
+		return &lru{ll: list.New()}
+	}
+}
+
+// lru evicts the least recently used entry.
+type lru struct {
+	ll *list.List
+}
+
+func (p *lru) added(e *entry)    { e.elem = p.ll.PushFront(e) }
+func (p *lru) accessed(e *entry) { p.ll.MoveToFront(e.elem) }
+func (p *lru) removed(e *entry)  { p.ll.Remove(e.elem); e.elem = nil }
+func (p *lru) restored(e *entry) { p.added(e) }
+
+func (p *lru) evict() *entry {
+	back := p.ll.Back()
+	if back == nil {
+		return nil
+	}
+	e := back.Value.(*entry)
+	p.removed(e)
+	return e
+}
+
+// lfu evicts the least frequently used entry, breaking ties by recency.
+// Entries live in per-frequency lists so every operation is O(1) apart from
+// locating the new minimum after the lowest bucket empties.
+type lfu struct {
+	buckets map[int]*list.List
+	min     int
+}
+
+func (p *lfu) push(e *entry) {
+	b, ok := p.buckets[e.freq]
+	if !ok {
+		b = list.New()
+		p.buckets[e.freq] = b
+	}
+	e.elem = b.PushFront(e)
+}
+
+func (p *lfu) unlink(e *entry) {
+	b := p.buckets[e.freq]
+	b.Remove(e.elem)
+	e.elem = nil
+	if b.Len() == 0 {
+		delete(p.buckets, e.freq)
+	}
+}
+
+func (p *lfu) added(e *entry) {
+	e.freq = 1
+	p.push(e)
+	p.min = 1
+}
+
+func (p *lfu) accessed(e *entry) {
+	p.unlink(e)
+	if _, ok := p.buckets[p.min]; !ok && p.min == e.freq {
+		p.min++
+	}
+	e.freq++
+	p.push(e)
+}
+
+func (p *lfu) removed(e *entry) { p.unlink(e) }
+
+func (p *lfu) restored(e *entry) {
+	p.push(e)
+	p.min = min(p.min, e.freq)
+}
+
+func (p *lfu) evict() *entry {
+	if len(p.buckets) == 0 {
+		return nil
+	}
+	b, ok := p.buckets[p.min]
+	if !ok {
+		p.min = 0
+		for f := range p.buckets {
+			if p.min == 0 || f < p.min {
+				p.min = f
+			}
+		}
+		b = p.buckets[p.min]
+	}
+	e := b.Back().Value.(*entry)
+	p.unlink(e)
+	return e
+}
+
+// arc implements Adaptive Replacement Cache. Resident entries are split
+// between t1 (seen once) and t2 (seen again); b1 and b2 remember the keys
+// recently evicted from each so a quick return shifts the target size p
+// towards the list that would have kept it.
+type arc struct {
+	capacity int
+	p        int
+	t1, t2   *list.List
+	b1, b2   *list.List
+	ghosts   map[string]*list.Element
+}
+
+func newARC(capacity int) *arc {
+	return &arc{
+		capacity: capacity,
+		t1:       list.New(),
+		t2:       list.New(),
+		b1:       list.New(),
+		b2:       list.New(),
+		ghosts:   make(map[string]*list.Element),
+	}
+}
+
+// size returns the adaptation bound. Without an entry limit the cache is
+// bounded by bytes only, so the resident count stands in for it.
+func (p *arc) size() int {
+	if p.capacity > 0 {
+		return p.capacity
+	}
+	return max(p.t1.Len()+p.t2.Len(), 1)
+}
+
+func (p *arc) added(e *entry) {
+	if g, ok := p.ghosts[e.key]; ok {
+		if g.Value.(ghost).frequent {
+			p.p = max(p.p-max(p.b1.Len()/max(p.b2.Len(), 1), 1), 0)
+			p.b2.Remove(g)
+		} else {
+			p.p = min(p.p+max(p.b2.Len()/max(p.b1.Len(), 1), 1), p.size())
+			p.b1.Remove(g)
+		}
+		delete(p.ghosts, e.key)
+		e.frequent = true
+		e.elem = p.t2.PushFront(e)
+	} else {
+		e.frequent = false
+		e.elem = p.t1.PushFront(e)
+	}
+	p.trimGhosts()
+}
+
+func (p *arc) accessed(e *entry) {
+	p.removed(e)
+	e.frequent = true
+	e.elem = p.t2.PushFront(e)
+}
+
+func (p *arc) removed(e *entry) {
+	if e.frequent {
+		p.t2.Remove(e.elem)
+	} else {
+		p.t1.Remove(e.elem)
+	}
+	e.elem = nil
+}
+
+func (p *arc) evict() *entry {
+	var from, to *list.List
+	if p.t1.Len() > 0 && (p.t1.Len() > p.p || p.t2.Len() == 0) {
+		from, to = p.t1, p.b1
+	} else {
+		from, to = p.t2, p.b2
+	}
+	back := from.Back()
+	if back == nil {
+		return nil
+	}
+	e := back.Value.(*entry)
+	p.removed(e)
+	p.ghosts[e.key] = to.PushFront(ghost{key: e.key, frequent: e.frequent})
+	p.trimGhosts()
+	return e
+}
+
+// restored drops the ghost evict left for e and puts it back on the list
+// it came from.
+func (p *arc) restored(e *entry) {
+	if g, ok := p.ghosts[e.key]; ok {
+		if g.Value.(ghost).frequent {
+			p.b2.Remove(g)
+		} else {
+			p.b1.Remove(g)
+		}
+		delete(p.ghosts, e.key)
+	}
+	if e.frequent {
+		e.elem = p.t2.PushFront(e)
+	} else {
+		e.elem = p.t1.PushFront(e)
+	}
+}
+
+func (p *arc) trimGhosts() {
+	c := p.size()
+	for p.b1.Len() > 0 && p.t1.Len()+p.b1.Len() > c {
+		p.dropGhost(p.b1)
+	}
+	for p.b2.Len() > 0 && p.t1.Len()+p.t2.Len()+p.b1.Len()+p.b2.Len() > 2*c {
+		p.dropGhost(p.b2)
+	}
+}
+
+func (p *arc) dropGhost(l *list.List) {
+	back := l.Back()
+	l.Remove(back)
+	delete(p.ghosts, back.Value.(ghost).key)
+}
+
+// ghost is the remembered key of an entry evicted by arc.
+type ghost struct {
+	key      string
+	frequent bool
+}
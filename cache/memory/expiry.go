@@ -0,0 +1,60 @@
+package memory
+
+import "container/heap"
+
+// expiryHeap is a min-heap of entries ordered by their deadline. Each entry
+// remembers its position so a rewrite can fix or remove its old deadline in
+// O(log n) instead of leaving a stale timer behind.
+type expiryHeap []*entry
+
+func (h expiryHeap) Len() int           { return len(h) }
+func (h expiryHeap) Less(i, j int) bool { return h[i].expireAt < h[j].expireAt }
+
+func (h expiryHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *expiryHeap) Push(x any) {
+	e := x.(*entry)
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *expiryHeap) Pop() any {
+	old := *h
+	n := len(old)
+	e := old[n-1]
+	old[n-1] = nil
+	e.index = -1
+	*h = old[:n-1]
+	return e
+}
+
+// schedule inserts e or moves it to its new deadline. Entries without a
+// deadline are removed from the heap.
+func (h *expiryHeap) schedule(e *entry) {
+	switch {
+	case e.expireAt == 0:
+		h.unschedule(e)
+	case e.index >= 0:
+		heap.Fix(h, e.index)
+	default:
+		heap.Push(h, e)
+	}
+}
+
+func (h *expiryHeap) unschedule(e *entry) {
+	if e.index >= 0 {
+		heap.Remove(h, e.index)
+	}
+}
+
+// peek returns the entry with the earliest deadline, if any.
+func (h expiryHeap) peek() *entry {
+	if len(h) == 0 {
+		return nil
+	}
+	return h[0]
+}
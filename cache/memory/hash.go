@@ -0,0 +1,188 @@
+package memory
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/carlosealves2/go-infrakit/cache"
+)
+
+var _ cache.HashCache = (*Cache)(nil)
+
+// hashLocked returns the live hash at key, or nil when it is missing. With
+// create, a missing hash is stored empty and without expiration; the entry
+// can still be nil when the shard limits leave no room for it.
+func (s *shard) hashLocked(key string, create bool) (*entry, []eviction, error) {
+	e, ok := s.lookupLocked(key)
+	switch {
+	case ok && e.hash == nil:
+		return nil, nil, cache.ErrWrongType
+	case ok || !create:
+		return e, nil, nil
+	}
+	evicted := s.setLocked(key, nil, 0)
+	if e = s.store[key]; e != nil {
+		e.hash = make(map[string][]byte)
+	}
+	return e, evicted, nil
+}
+
+// hset stores a field, keeping the element byte count current. It must run
+// inside resizeLocked.
+func (e *entry) hset(field string, value []byte) {
+	if old, ok := e.hash[field]; ok {
+		e.extra -= int64(len(field) + len(old))
+	}
+	e.hash[field] = value
+	e.extra += int64(len(field) + len(value))
+}
+
+func (c *Cache) HSet(ctx context.Context, key string, fields map[string][]byte) error {
+	if len(fields) == 0 {
+		return nil
+	}
+	key, keyLen := c.formatKey(key)
+	start := time.Now()
+	if err := c.checkCtx(ctx); err != nil {
+		c.observe(ctx, "hset", keyLen, false, start, err)
+		return err
+	}
+	sh := c.shardFor(key)
+	sh.mu.Lock()
+	e, evicted, err := sh.hashLocked(key, true)
+	if e != nil {
+		evicted = append(evicted, sh.resizeLocked(e, func() {
+			for f, v := range fields {
+				e.hset(f, append([]byte(nil), v...))
+			}
+		})...)
+	}
+	sh.mu.Unlock()
+	c.observe(ctx, "hset", keyLen, false, start, err, attribute.Int("fields", len(fields)))
+	c.observeEvictions(ctx, evicted)
+	return err
+}
+
+func (c *Cache) HGet(ctx context.Context, key, field string) ([]byte, error) {
+	key, keyLen := c.formatKey(key)
+	start := time.Now()
+	if err := c.checkCtx(ctx); err != nil {
+		c.observe(ctx, "hget", keyLen, false, start, err)
+		return nil, err
+	}
+	sh := c.shardFor(key)
+	sh.readLock()
+	var val []byte
+	found := false
+	e, _, err := sh.hashLocked(key, false)
+	if e != nil {
+		sh.accessedLocked(e)
+		var v []byte
+		if v, found = e.hash[field]; found {
+			val = append([]byte{}, v...)
+		}
+	}
+	sh.readUnlock()
+	if err == nil && !found {
+		err = cache.ErrNotFound
+	}
+	c.observe(ctx, "hget", keyLen, err == nil, start, err)
+	return val, err
+}
+
+func (c *Cache) HGetAll(ctx context.Context, key string) (map[string][]byte, error) {
+	key, keyLen := c.formatKey(key)
+	start := time.Now()
+	if err := c.checkCtx(ctx); err != nil {
+		c.observe(ctx, "hgetall", keyLen, false, start, err)
+		return nil, err
+	}
+	sh := c.shardFor(key)
+	sh.readLock()
+	e, _, err := sh.hashLocked(key, false)
+	var out map[string][]byte
+	if err == nil {
+		out = make(map[string][]byte)
+	}
+	if e != nil {
+		sh.accessedLocked(e)
+		for f, v := range e.hash {
+			out[f] = append([]byte(nil), v...)
+		}
+	}
+	sh.readUnlock()
+	c.observe(ctx, "hgetall", keyLen, len(out) > 0, start, err, attribute.Int("fields", len(out)))
+	return out, err
+}
+
+// HDel removes fields and deletes the hash once it is empty.
+func (c *Cache) HDel(ctx context.Context, key string, fields ...string) error {
+	if len(fields) == 0 {
+		return nil
+	}
+	key, keyLen := c.formatKey(key)
+	start := time.Now()
+	if err := c.checkCtx(ctx); err != nil {
+		c.observe(ctx, "hdel", keyLen, false, start, err)
+		return err
+	}
+	sh := c.shardFor(key)
+	sh.mu.Lock()
+	e, _, err := sh.hashLocked(key, false)
+	if e != nil {
+		sh.resizeLocked(e, func() {
+			for _, f := range fields {
+				if v, ok := e.hash[f]; ok {
+					e.extra -= int64(len(f) + len(v))
+					delete(e.hash, f)
+				}
+			}
+		})
+		if len(e.hash) == 0 {
+			sh.deleteLocked(key)
+		}
+	}
+	sh.mu.Unlock()
+	c.observe(ctx, "hdel", keyLen, false, start, err)
+	return err
+}
+
+func (c *Cache) HIncrBy(ctx context.Context, key, field string, delta int64) (int64, error) {
+	key, keyLen := c.formatKey(key)
+	start := time.Now()
+	if err := c.checkCtx(ctx); err != nil {
+		c.observe(ctx, "hincrby", keyLen, false, start, err)
+		return 0, err
+	}
+	sh := c.shardFor(key)
+	sh.mu.Lock()
+	var n int64
+	e, evicted, err := sh.hashLocked(key, true)
+	if e != nil {
+		if cur, ok := e.hash[field]; ok {
+			if n, err = strconv.ParseInt(string(cur), 10, 64); err != nil {
+				err = cache.ErrNotNumber
+			}
+		}
+		if err == nil && ((delta > 0 && n > math.MaxInt64-delta) || (delta < 0 && n < math.MinInt64-delta)) {
+			err = cache.ErrNotNumber
+		}
+		if err == nil {
+			n += delta
+			evicted = append(evicted, sh.resizeLocked(e, func() {
+				e.hset(field, strconv.AppendInt(nil, n, 10))
+			})...)
+		}
+	}
+	sh.mu.Unlock()
+	if err != nil {
+		n = 0
+	}
+	c.observe(ctx, "hincrby", keyLen, false, start, err)
+	c.observeEvictions(ctx, evicted)
+	return n, err
+}
@@ -0,0 +1,241 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/carlosealves2/go-infrakit/cache"
+)
+
+var _ cache.ListCache = (*Cache)(nil)
+
+// listLocked returns the live list at key like hashLocked does for hashes.
+func (s *shard) listLocked(key string, create bool) (*entry, []eviction, error) {
+	e, ok := s.lookupLocked(key)
+	switch {
+	case ok && e.list == nil:
+		return nil, nil, cache.ErrWrongType
+	case ok || !create:
+		return e, nil, nil
+	}
+	evicted := s.setLocked(key, nil, 0)
+	if e = s.store[key]; e != nil {
+		e.list = &deque{}
+	}
+	return e, evicted, nil
+}
+
+// popLocked removes a value from one end of the list at key, deleting the
+// list once it is empty. It returns ErrNotFound when there is no list.
+func (s *shard) popLocked(key string, front bool) ([]byte, []eviction, error) {
+	e, _, err := s.listLocked(key, false)
+	if e == nil {
+		if err == nil {
+			err = cache.ErrNotFound
+		}
+		return nil, nil, err
+	}
+	var v []byte
+	evicted := s.resizeLocked(e, func() {
+		if front {
+			v = e.list.popFront()
+		} else {
+			v = e.list.popBack()
+		}
+		e.extra -= int64(len(v))
+	})
+	if e.list.len() == 0 {
+		s.deleteLocked(key)
+	}
+	return v, evicted, nil
+}
+
+// waitPushLocked blocks until a list of the shard grows or wake is called.
+func (s *shard) waitPushLocked() {
+	if s.pushed == nil {
+		s.pushed = sync.NewCond(&s.mu)
+	}
+	s.pushed.Wait()
+}
+
+// wake releases every blocking pop waiting on the shard so it can check
+// its context and the cache state.
+func (s *shard) wake() {
+	s.mu.Lock()
+	if s.pushed != nil {
+		s.pushed.Broadcast()
+	}
+	s.mu.Unlock()
+}
+
+func (c *Cache) LPush(ctx context.Context, key string, values ...[]byte) error {
+	return c.push(ctx, "lpush", key, values, true)
+}
+
+func (c *Cache) RPush(ctx context.Context, key string, values ...[]byte) error {
+	return c.push(ctx, "rpush", key, values, false)
+}
+
+func (c *Cache) push(ctx context.Context, op, key string, values [][]byte, front bool) error {
+	if len(values) == 0 {
+		return nil
+	}
+	key, keyLen := c.formatKey(key)
+	start := time.Now()
+	if err := c.checkCtx(ctx); err != nil {
+		c.observe(ctx, op, keyLen, false, start, err)
+		return err
+	}
+	sh := c.shardFor(key)
+	sh.mu.Lock()
+	e, evicted, err := sh.listLocked(key, true)
+	if e != nil {
+		evicted = append(evicted, sh.resizeLocked(e, func() {
+			for _, v := range values {
+				v = append([]byte(nil), v...)
+				if front {
+					e.list.pushFront(v)
+				} else {
+					e.list.pushBack(v)
+				}
+				e.extra += int64(len(v))
+			}
+		})...)
+		if sh.pushed != nil {
+			sh.pushed.Broadcast()
+		}
+	}
+	sh.mu.Unlock()
+	c.observe(ctx, op, keyLen, false, start, err, attribute.Int("values", len(values)))
+	c.observeEvictions(ctx, evicted)
+	return err
+}
+
+func (c *Cache) LPop(ctx context.Context, key string) ([]byte, error) {
+	return c.pop(ctx, "lpop", key, true, false)
+}
+
+func (c *Cache) RPop(ctx context.Context, key string) ([]byte, error) {
+	return c.pop(ctx, "rpop", key, false, false)
+}
+
+// BLPop waits on a condition variable that pushes to the shard signal.
+func (c *Cache) BLPop(ctx context.Context, key string) ([]byte, error) {
+	return c.pop(ctx, "blpop", key, true, true)
+}
+
+func (c *Cache) BRPop(ctx context.Context, key string) ([]byte, error) {
+	return c.pop(ctx, "brpop", key, false, true)
+}
+
+// pop removes a value from one end of the list. A blocking pop waits while
+// the list is missing and is woken by pushes, by ctx being done and by
+// Close.
+func (c *Cache) pop(ctx context.Context, op, key string, front, block bool) ([]byte, error) {
+	key, keyLen := c.formatKey(key)
+	start := time.Now()
+	sh := c.shardFor(key)
+	if block {
+		stop := context.AfterFunc(ctx, sh.wake)
+		defer stop()
+	}
+	sh.mu.Lock()
+	var v []byte
+	var evicted []eviction
+	var err error
+	for {
+		if err = c.checkCtx(ctx); err != nil {
+			break
+		}
+		v, evicted, err = sh.popLocked(key, front)
+		if !block || err != cache.ErrNotFound {
+			break
+		}
+		sh.waitPushLocked()
+	}
+	sh.mu.Unlock()
+	c.observe(ctx, op, keyLen, err == nil, start, err)
+	c.observeEvictions(ctx, evicted)
+	return v, err
+}
+
+func (c *Cache) LRange(ctx context.Context, key string, start, stop int64) ([][]byte, error) {
+	key, keyLen := c.formatKey(key)
+	begin := time.Now()
+	if err := c.checkCtx(ctx); err != nil {
+		c.observe(ctx, "lrange", keyLen, false, begin, err)
+		return nil, err
+	}
+	sh := c.shardFor(key)
+	sh.readLock()
+	e, _, err := sh.listLocked(key, false)
+	var out [][]byte
+	if err == nil {
+		out = [][]byte{}
+	}
+	if e != nil {
+		sh.accessedLocked(e)
+		lo, hi := e.list.span(start, stop)
+		for i := lo; i < hi; i++ {
+			out = append(out, append([]byte{}, e.list.at(i)...))
+		}
+	}
+	sh.readUnlock()
+	c.observe(ctx, "lrange", keyLen, false, begin, err)
+	return out, err
+}
+
+// LTrim keeps the values from start through stop and deletes the list when
+// none are left.
+func (c *Cache) LTrim(ctx context.Context, key string, start, stop int64) error {
+	key, keyLen := c.formatKey(key)
+	begin := time.Now()
+	if err := c.checkCtx(ctx); err != nil {
+		c.observe(ctx, "ltrim", keyLen, false, begin, err)
+		return err
+	}
+	sh := c.shardFor(key)
+	sh.mu.Lock()
+	e, _, err := sh.listLocked(key, false)
+	if e != nil {
+		sh.resizeLocked(e, func() {
+			lo, hi := e.list.span(start, stop)
+			kept := &deque{}
+			e.extra = 0
+			for i := lo; i < hi; i++ {
+				v := e.list.at(i)
+				kept.pushBack(v)
+				e.extra += int64(len(v))
+			}
+			e.list = kept
+		})
+		if e.list.len() == 0 {
+			sh.deleteLocked(key)
+		}
+	}
+	sh.mu.Unlock()
+	c.observe(ctx, "ltrim", keyLen, false, begin, err)
+	return err
+}
+
+func (c *Cache) LLen(ctx context.Context, key string) (int64, error) {
+	key, keyLen := c.formatKey(key)
+	start := time.Now()
+	if err := c.checkCtx(ctx); err != nil {
+		c.observe(ctx, "llen", keyLen, false, start, err)
+		return 0, err
+	}
+	sh := c.shardFor(key)
+	sh.mu.RLock()
+	var n int
+	e, _, err := sh.listLocked(key, false)
+	if e != nil {
+		n = e.list.len()
+	}
+	sh.mu.RUnlock()
+	c.observe(ctx, "llen", keyLen, false, start, err)
+	return int64(n), err
+}
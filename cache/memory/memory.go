@@ -3,6 +3,7 @@ package memory
 import (
 	"context"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"go.opentelemetry.io/otel/attribute"
@@ -15,9 +16,29 @@ import (
 
 // Cache is an in-memory implementation of cache.Cache.
 // It is safe for concurrent use.
+//
+// Keys are spread by hash over Options.Shards independently locked shards so
+// operations on different keys rarely contend. Size limits are divided
+// evenly between shards, which makes eviction approximate when more than one
+// shard is used.
+//
+// Expired entries are hidden from reads as soon as their deadline passes and
+// are reclaimed by a single background reaper driven by per-shard min-heaps
+// of deadlines. The reaper is started on the first TTL write and stopped by
+// Close.
+//
+// When Options.MaxEntries or Options.MaxBytes is set, writes that exceed a
+// limit evict entries chosen by Options.Eviction. Evictions and expirations
+// are reported as the "evict" op with a reason attribute.
 type Cache struct {
-	mu      sync.RWMutex
-	store   map[string][]byte
+	shards    []*shard
+	lifecycle sync.Mutex
+	reaping   atomic.Bool
+	closed    atomic.Bool
+	wake      chan struct{}
+	done      chan struct{}
+	wg        sync.WaitGroup
+
 	ns      string
 	logger  logger.Logger
 	tracer  trace.Tracer
@@ -28,11 +49,26 @@ type Cache struct {
 // New creates a new in-memory cache configured by opts.
 func New(opts cache.Options) *Cache {
 	c := &Cache{
-		store:  make(map[string][]byte),
+		shards: make([]*shard, max(opts.Shards, 1)),
+		wake:   make(chan struct{}, 1),
+		done:   make(chan struct{}),
 		ns:     opts.Namespace,
 		logger: opts.Logger,
 		tracer: opts.Tracer,
 	}
+	n := len(c.shards)
+	for i := range c.shards {
+		s := &shard{
+			store:      make(map[string]*entry),
+			maxEntries: ceilDiv(opts.MaxEntries, n),
+			maxBytes:   ceilDiv(opts.MaxBytes, int64(n)),
+			onHead:     c.wakeReaper,
+		}
+		if s.maxEntries > 0 || s.maxBytes > 0 {
+			s.policy = newPolicy(opts.Eviction, s.maxEntries)
+		}
+		c.shards[i] = s
+	}
 	if opts.Meter != (metric.Meter{}) {
 		c.counter, _ = opts.Meter.Int64Counter("cache_ops_total")
 		c.latency, _ = opts.Meter.Float64Histogram("cache_latency_ms")
@@ -40,6 +76,42 @@ func New(opts cache.Options) *Cache {
 	return c
 }
 
+func ceilDiv[T int | int64](a, b T) T {
+	if a <= 0 {
+		return 0
+	}
+	return (a + b - 1) / b
+}
+
+// shardFor returns the shard owning key.
+func (c *Cache) shardFor(key string) *shard {
+	return c.shards[c.shardIndex(key)]
+}
+
+// shardIndex returns the index of the shard owning key using FNV-1a.
+func (c *Cache) shardIndex(key string) int {
+	if len(c.shards) == 1 {
+		return 0
+	}
+	h := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		h ^= uint32(key[i])
+		h *= 16777619
+	}
+	return int(h % uint32(len(c.shards)))
+}
+
+// groupByShard splits formatted keys by owning shard so multi-key
+// operations take each shard lock once.
+func (c *Cache) groupByShard(keys []string) map[*shard][]string {
+	groups := make(map[*shard][]string, min(len(keys), len(c.shards)))
+	for _, k := range keys {
+		s := c.shardFor(k)
+		groups[s] = append(groups[s], k)
+	}
+	return groups
+}
+
 func (c *Cache) formatKey(key string) (string, int) {
 	keyLen := len(key)
 	if c.ns != "" {
@@ -49,19 +121,110 @@ func (c *Cache) formatKey(key string) (string, int) {
 }
 
 func (c *Cache) checkCtx(ctx context.Context) error {
+	if c.closed.Load() {
+		return cache.ErrClosed
+	}
 	if err := ctx.Err(); err != nil {
 		return cache.ErrTimeout
 	}
 	return nil
 }
 
-func (c *Cache) observe(ctx context.Context, op string, keyLen int, hit bool, start time.Time, err error) {
+// Namespace returns the namespace keys are stored under.
+func (c *Cache) Namespace() string { return c.ns }
+
+// Close stops the expiration reaper and releases blocked list pops.
+// Operations on a closed cache return cache.ErrClosed. Close is idempotent.
+func (c *Cache) Close() error {
+	c.lifecycle.Lock()
+	if c.closed.Load() {
+		c.lifecycle.Unlock()
+		return nil
+	}
+	c.closed.Store(true)
+	close(c.done)
+	c.lifecycle.Unlock()
+	for _, sh := range c.shards {
+		sh.wake()
+	}
+	c.wg.Wait()
+	return nil
+}
+
+// wakeReaper starts the reaper on first use and nudges it to recompute its
+// next deadline.
+func (c *Cache) wakeReaper() {
+	if !c.reaping.Load() {
+		c.lifecycle.Lock()
+		if !c.closed.Load() && !c.reaping.Load() {
+			c.reaping.Store(true)
+			c.wg.Add(1)
+			go c.reap()
+		}
+		c.lifecycle.Unlock()
+	}
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
+
+// reap removes expired entries, sleeping until the earliest deadline across
+// shards or until a new earlier deadline is scheduled.
+func (c *Cache) reap() {
+	defer c.wg.Done()
+	timer := time.NewTimer(time.Hour)
+	timer.Stop()
+	defer timer.Stop()
+	var evicted []eviction
+	for {
+		var next int64
+		for _, s := range c.shards {
+			s.mu.Lock()
+			evicted = s.expireLocked(time.Now().UnixNano(), evicted[:0])
+			if d := s.nextDeadlineLocked(); d != 0 && (next == 0 || d < next) {
+				next = d
+			}
+			s.mu.Unlock()
+			c.observeEvictions(context.Background(), evicted)
+		}
+		if next != 0 {
+			timer.Reset(time.Until(time.Unix(0, next)))
+		}
+		select {
+		case <-c.done:
+			return
+		case <-c.wake:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// keyLen returns the length of a stored key without its namespace prefix.
+func (c *Cache) keyLen(key string) int {
+	if c.ns != "" {
+		return len(key) - len(c.ns) - 1
+	}
+	return len(key)
+}
+
+func (c *Cache) observeEvictions(ctx context.Context, evicted []eviction) {
+	for _, ev := range evicted {
+		c.observe(ctx, "evict", c.keyLen(ev.key), false, time.Now(), nil, attribute.String("reason", ev.reason))
+	}
+}
+
+func (c *Cache) observe(ctx context.Context, op string, keyLen int, hit bool, start time.Time, err error, attrs ...attribute.KeyValue) {
 	dur := time.Since(start)
 	if c.logger != nil {
 		entry := c.logger.Info()
 		if err != nil {
 			entry = c.logger.Error().Err(err)
 		}
+		for _, kv := range attrs {
+			entry = entry.Str(string(kv.Key), kv.Value.Emit())
+		}
 		entry.Str("mod", "cache").
 			Str("provider", "memory").
 			Str("op", op).
@@ -80,20 +243,22 @@ func (c *Cache) observe(ctx context.Context, op string, keyLen int, hit bool, st
 		if op == "get" {
 			span.SetAttributes(attribute.Bool("cache.hit", hit))
 		}
+		span.SetAttributes(attrs...)
 		if err != nil {
 			span.RecordError(err)
 		}
 		span.End()
 	}
 	if c.counter != (metric.Int64Counter{}) {
-		attrs := []attribute.KeyValue{
+		counterAttrs := []attribute.KeyValue{
 			attribute.String("provider", "memory"),
 			attribute.String("op", op),
 		}
 		if op == "get" {
-			attrs = append(attrs, attribute.Bool("hit", hit))
+			counterAttrs = append(counterAttrs, attribute.Bool("hit", hit))
 		}
-		c.counter.Add(ctx, 1, metric.WithAttributes(attrs...))
+		counterAttrs = append(counterAttrs, attrs...)
+		c.counter.Add(ctx, 1, metric.WithAttributes(counterAttrs...))
 	}
 	if c.latency != (metric.Float64Histogram{}) {
 		c.latency.Record(ctx, float64(dur.Milliseconds()), metric.WithAttributes(
@@ -114,10 +279,12 @@ func (c *Cache) SetBytes(ctx context.Context, key string, value []byte) error {
 		c.observe(ctx, "set", keyLen, false, start, err)
 		return err
 	}
-	c.mu.Lock()
-	c.store[key] = append([]byte(nil), value...)
-	c.mu.Unlock()
+	sh := c.shardFor(key)
+	sh.mu.Lock()
+	evicted := sh.setLocked(key, append([]byte(nil), value...), 0)
+	sh.mu.Unlock()
 	c.observe(ctx, "set", keyLen, false, start, nil)
+	c.observeEvictions(ctx, evicted)
 	return nil
 }
 
@@ -128,17 +295,12 @@ func (c *Cache) SetWithTTL(ctx context.Context, key, value string, ttl time.Dura
 		c.observe(ctx, "set", keyLen, false, start, err)
 		return err
 	}
-	c.mu.Lock()
-	c.store[key] = []byte(value)
-	c.mu.Unlock()
-	if ttl > 0 {
-		time.AfterFunc(ttl, func() {
-			c.mu.Lock()
-			delete(c.store, key)
-			c.mu.Unlock()
-		})
-	}
+	sh := c.shardFor(key)
+	sh.mu.Lock()
+	evicted := sh.setLocked(key, []byte(value), ttl)
+	sh.mu.Unlock()
 	c.observe(ctx, "set", keyLen, false, start, nil)
+	c.observeEvictions(ctx, evicted)
 	return nil
 }
 
@@ -154,15 +316,14 @@ func (c *Cache) GetBytes(ctx context.Context, key string) ([]byte, error) {
 		c.observe(ctx, "get", keyLen, false, start, err)
 		return nil, err
 	}
-	c.mu.RLock()
-	v, ok := c.store[key]
-	c.mu.RUnlock()
-	if !ok {
-		err := cache.ErrNotFound
+	sh := c.shardFor(key)
+	sh.readLock()
+	val, err := sh.getLocked(key)
+	sh.readUnlock()
+	if err != nil {
 		c.observe(ctx, "get", keyLen, false, start, err)
 		return nil, err
 	}
-	val := append([]byte(nil), v...)
 	c.observe(ctx, "get", keyLen, true, start, nil)
 	return val, nil
 }
@@ -182,11 +343,13 @@ func (c *Cache) Del(ctx context.Context, keys ...string) error {
 		c.observe(ctx, "del", keyLen, false, start, err)
 		return err
 	}
-	c.mu.Lock()
-	for _, k := range formatted {
-		delete(c.store, k)
+	for sh, keys := range c.groupByShard(formatted) {
+		sh.mu.Lock()
+		for _, k := range keys {
+			sh.deleteLocked(k)
+		}
+		sh.mu.Unlock()
 	}
-	c.mu.Unlock()
 	c.observe(ctx, "del", keyLen, false, start, nil)
 	return nil
 }
@@ -198,11 +361,99 @@ func (c *Cache) Exists(ctx context.Context, key string) (bool, error) {
 		c.observe(ctx, "exists", keyLen, false, start, err)
 		return false, err
 	}
-	c.mu.RLock()
-	_, ok := c.store[key]
-	c.mu.RUnlock()
+	sh := c.shardFor(key)
+	sh.mu.RLock()
+	e, ok := sh.lookupLocked(key)
+	ok = ok && !e.absent
+	sh.mu.RUnlock()
 	c.observe(ctx, "exists", keyLen, false, start, nil)
 	return ok, nil
 }
 
+func (c *Cache) TTL(ctx context.Context, key string) (time.Duration, error) {
+	key, keyLen := c.formatKey(key)
+	start := time.Now()
+	if err := c.checkCtx(ctx); err != nil {
+		c.observe(ctx, "ttl", keyLen, false, start, err)
+		return 0, err
+	}
+	sh := c.shardFor(key)
+	sh.mu.RLock()
+	e, ok := sh.lookupLocked(key)
+	ttl := cache.NoExpiration
+	if ok && e.expireAt != 0 {
+		ttl = max(time.Until(time.Unix(0, e.expireAt)), 0)
+	}
+	sh.mu.RUnlock()
+	var err error
+	if !ok {
+		ttl, err = 0, cache.ErrNotFound
+	}
+	c.observe(ctx, "ttl", keyLen, ok, start, err)
+	return ttl, err
+}
+
+func (c *Cache) Expire(ctx context.Context, key string, ttl time.Duration) error {
+	return c.setDeadline(ctx, "expire", key, ttl)
+}
+
+func (c *Cache) Persist(ctx context.Context, key string) error {
+	return c.setDeadline(ctx, "persist", key, 0)
+}
+
+// setDeadline moves the deadline of an existing key; a zero ttl removes
+// it. Expire with a non-positive ttl deletes the key like Redis does.
+func (c *Cache) setDeadline(ctx context.Context, op, key string, ttl time.Duration) error {
+	key, keyLen := c.formatKey(key)
+	start := time.Now()
+	if err := c.checkCtx(ctx); err != nil {
+		c.observe(ctx, op, keyLen, false, start, err)
+		return err
+	}
+	sh := c.shardFor(key)
+	sh.mu.Lock()
+	e, ok := sh.lookupLocked(key)
+	switch {
+	case !ok:
+	case op == "expire" && ttl <= 0:
+		sh.deleteLocked(key)
+	default:
+		e.expireAt = 0
+		if ttl > 0 {
+			e.expireAt = time.Now().Add(ttl).UnixNano()
+		}
+		sh.scheduleLocked(e)
+	}
+	sh.mu.Unlock()
+	var err error
+	if !ok {
+		err = cache.ErrNotFound
+	}
+	c.observe(ctx, op, keyLen, false, start, err)
+	return err
+}
+
+// Touch marks key as recently used for the eviction policy.
+func (c *Cache) Touch(ctx context.Context, key string) error {
+	key, keyLen := c.formatKey(key)
+	start := time.Now()
+	if err := c.checkCtx(ctx); err != nil {
+		c.observe(ctx, "touch", keyLen, false, start, err)
+		return err
+	}
+	sh := c.shardFor(key)
+	sh.readLock()
+	e, ok := sh.lookupLocked(key)
+	if ok && sh.policy != nil {
+		sh.policy.accessed(e)
+	}
+	sh.readUnlock()
+	var err error
+	if !ok {
+		err = cache.ErrNotFound
+	}
+	c.observe(ctx, "touch", keyLen, false, start, err)
+	return err
+}
+
 var _ cache.Cache = (*Cache)(nil)
@@ -1,11 +1,19 @@
 package memory
 
 import (
+	"cmp"
 	"context"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/carlosealves2/go-infrakit/cache"
+	"github.com/carlosealves2/go-infrakit/observability/logger"
 )
 
 func TestMemoryCacheBasic(t *testing.T) {
@@ -55,3 +63,923 @@ func TestMemoryNamespace(t *testing.T) {
 		t.Fatalf("get failed: %v %s", err, val)
 	}
 }
+
+func TestMemoryCacheTTLOverwrite(t *testing.T) {
+	ctx := context.Background()
+	m := New(cache.Options{})
+	defer m.Close()
+	if err := m.SetWithTTL(ctx, "foo", "old", 30*time.Millisecond); err != nil {
+		t.Fatalf("set ttl: %v", err)
+	}
+	if err := m.SetWithTTL(ctx, "foo", "new", time.Hour); err != nil {
+		t.Fatalf("set ttl: %v", err)
+	}
+	if err := m.SetWithTTL(ctx, "bar", "old", 30*time.Millisecond); err != nil {
+		t.Fatalf("set ttl: %v", err)
+	}
+	if err := m.Set(ctx, "bar", "new"); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	time.Sleep(60 * time.Millisecond)
+	for _, key := range []string{"foo", "bar"} {
+		if val, err := m.Get(ctx, key); err != nil || val != "new" {
+			t.Fatalf("%s: old deadline removed new value: %v %s", key, err, val)
+		}
+	}
+}
+
+func TestMemoryCacheReaper(t *testing.T) {
+	ctx := context.Background()
+	m := New(cache.Options{})
+	defer m.Close()
+	for _, key := range []string{"a", "b", "c"} {
+		if err := m.SetWithTTL(ctx, key, "v", 20*time.Millisecond); err != nil {
+			t.Fatalf("set ttl: %v", err)
+		}
+	}
+	deadline := time.Now().Add(time.Second)
+	for {
+		n, _, pending := usage(m)
+		if n == 0 && pending == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("reaper left %d entries and %d deadlines", n, pending)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestMemoryCacheClose(t *testing.T) {
+	ctx := context.Background()
+	m := New(cache.Options{})
+	if err := m.SetWithTTL(ctx, "foo", "bar", time.Hour); err != nil {
+		t.Fatalf("set ttl: %v", err)
+	}
+	if err := m.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if err := m.Close(); err != nil {
+		t.Fatalf("second close: %v", err)
+	}
+	if _, err := m.Get(ctx, "foo"); err != cache.ErrClosed {
+		t.Fatalf("expected ErrClosed, got %v", err)
+	}
+	if err := m.Set(ctx, "foo", "bar"); err != cache.ErrClosed {
+		t.Fatalf("expected ErrClosed, got %v", err)
+	}
+}
+
+func TestMemoryCacheEvictLRU(t *testing.T) {
+	ctx := context.Background()
+	m := New(cache.Options{MaxEntries: 2})
+	m.Set(ctx, "a", "1")
+	m.Set(ctx, "b", "2")
+	if _, err := m.Get(ctx, "a"); err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	m.Set(ctx, "c", "3")
+	if _, err := m.Get(ctx, "b"); err != cache.ErrNotFound {
+		t.Fatalf("expected b to be evicted, got %v", err)
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, err := m.Get(ctx, key); err != nil {
+			t.Fatalf("expected %s to survive: %v", key, err)
+		}
+	}
+}
+
+func TestMemoryCacheEvictLFU(t *testing.T) {
+	ctx := context.Background()
+	m := New(cache.Options{MaxEntries: 2, Eviction: cache.EvictLFU})
+	m.Set(ctx, "a", "1")
+	m.Set(ctx, "b", "2")
+	for i := 0; i < 3; i++ {
+		m.Get(ctx, "a")
+	}
+	m.Get(ctx, "b")
+	m.Set(ctx, "c", "3")
+	if _, err := m.Get(ctx, "b"); err != cache.ErrNotFound {
+		t.Fatalf("expected b to be evicted, got %v", err)
+	}
+	if _, err := m.Get(ctx, "a"); err != nil {
+		t.Fatalf("expected a to survive: %v", err)
+	}
+}
+
+func TestMemoryCacheEvictARC(t *testing.T) {
+	ctx := context.Background()
+	m := New(cache.Options{MaxEntries: 3, Eviction: cache.EvictARC})
+	m.Set(ctx, "hot", "v")
+	m.Get(ctx, "hot")
+	for i := 0; i < 20; i++ {
+		m.Set(ctx, string(rune('a'+i)), "v")
+		if i%2 == 0 {
+			m.Get(ctx, "hot")
+		}
+	}
+	if _, err := m.Get(ctx, "hot"); err != nil {
+		t.Fatalf("expected frequently used key to survive a scan: %v", err)
+	}
+	if n, _, _ := usage(m); n != 3 {
+		t.Fatalf("expected 3 entries, got %d", n)
+	}
+}
+
+func TestMemoryCacheEvictLFUOverwriteHot(t *testing.T) {
+	ctx := context.Background()
+	m := New(cache.Options{MaxBytes: 8, Eviction: cache.EvictLFU})
+	m.Set(ctx, "hot", "1")
+	for i := 0; i < 3; i++ {
+		m.Get(ctx, "hot")
+	}
+	m.Set(ctx, "a", "1")
+	for i := 0; i < 5; i++ {
+		m.Get(ctx, "a")
+	}
+	// The overwrite leaves hot as the least used entry, so eviction skips
+	// it before dropping a.
+	m.Set(ctx, "hot", "1234")
+	if _, err := m.Get(ctx, "a"); err != cache.ErrNotFound {
+		t.Fatalf("expected a to be evicted, got %v", err)
+	}
+	s := m.shards[0]
+	if freq := s.store["hot"].freq; freq != 5 {
+		t.Fatalf("expected hot to keep its frequency, got %d", freq)
+	}
+}
+
+func TestMemoryCacheEvictARCOverwriteHot(t *testing.T) {
+	ctx := context.Background()
+	m := New(cache.Options{MaxEntries: 3, MaxBytes: 10, Eviction: cache.EvictARC})
+	m.Set(ctx, "hot", "1")
+	m.Get(ctx, "hot")
+	m.Set(ctx, "a", "1")
+	m.Set(ctx, "b", "1")
+	m.Set(ctx, "c", "1") // evicts a into the recent ghosts
+	m.Set(ctx, "a", "1") // the ghost hit grows the recent target
+	m.Del(ctx, "a")
+	s := m.shards[0]
+	p := s.policy.(*arc)
+	target := p.p
+	// hot is alone on the frequent list, so eviction skips it before
+	// dropping c.
+	m.Set(ctx, "hot", "123456")
+	if _, err := m.Get(ctx, "c"); err != cache.ErrNotFound {
+		t.Fatalf("expected c to be evicted, got %v", err)
+	}
+	if _, ok := p.ghosts["hot"]; ok {
+		t.Fatal("expected no ghost for the resident hot key")
+	}
+	if e := s.store["hot"]; e == nil || !e.frequent {
+		t.Fatal("expected hot to stay on the frequent list")
+	}
+	if p.p != target {
+		t.Fatalf("expected target %d to be unchanged, got %d", target, p.p)
+	}
+}
+
+func TestMemoryCacheMaxBytes(t *testing.T) {
+	ctx := context.Background()
+	log := &recordingLogger{}
+	m := New(cache.Options{MaxBytes: 10, Logger: log})
+	m.Set(ctx, "a", "1234")
+	m.Set(ctx, "b", "1234")
+	m.Set(ctx, "c", "1234")
+	if _, err := m.Get(ctx, "a"); err != cache.ErrNotFound {
+		t.Fatalf("expected a to be evicted, got %v", err)
+	}
+	if _, bytes, _ := usage(m); bytes != 10 {
+		t.Fatalf("expected 10 bytes in use, got %d", bytes)
+	}
+	if got := log.reasons("evict"); len(got) != 1 || got[0] != "max_bytes" {
+		t.Fatalf("expected one max_bytes eviction, got %v", got)
+	}
+}
+
+func TestMemoryCacheSharded(t *testing.T) {
+	ctx := context.Background()
+	m := New(cache.Options{Shards: 8, MaxEntries: 64})
+	defer m.Close()
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				key := strconv.Itoa(g*1000 + i)
+				if err := m.SetWithTTL(ctx, key, key, time.Minute); err != nil {
+					t.Errorf("set: %v", err)
+					return
+				}
+				if v, err := m.Get(ctx, key); err == nil && v != key {
+					t.Errorf("get %s: got %s", key, v)
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	if n, _, pending := usage(m); n > 64 || n != pending {
+		t.Fatalf("expected at most 64 scheduled entries, got %d entries and %d deadlines", n, pending)
+	}
+	if err := m.Del(ctx, "1", "2", "3"); err != nil {
+		t.Fatalf("del: %v", err)
+	}
+}
+
+func TestMemoryCacheBatch(t *testing.T) {
+	ctx := context.Background()
+	m := New(cache.Options{Namespace: "ns", Shards: 4})
+	defer m.Close()
+	if err := m.MSet(ctx, map[string][]byte{"a": []byte("1"), "b": []byte("2")}); err != nil {
+		t.Fatalf("mset: %v", err)
+	}
+	if err := m.MSetWithTTL(ctx, map[string][]byte{"c": []byte("3")}, 20*time.Millisecond); err != nil {
+		t.Fatalf("mset ttl: %v", err)
+	}
+	got, err := m.MGet(ctx, "a", "b", "c", "missing")
+	if err != nil {
+		t.Fatalf("mget: %v", err)
+	}
+	if len(got) != 3 || string(got["a"]) != "1" || string(got["b"]) != "2" || string(got["c"]) != "3" {
+		t.Fatalf("unexpected mget result: %v", got)
+	}
+	time.Sleep(30 * time.Millisecond)
+	got, err = m.MGet(ctx, "a", "c")
+	if err != nil {
+		t.Fatalf("mget: %v", err)
+	}
+	if _, ok := got["c"]; ok || len(got) != 1 {
+		t.Fatalf("expected c to expire: %v", got)
+	}
+}
+
+func TestMemoryCacheCounters(t *testing.T) {
+	ctx := context.Background()
+	c := New(cache.Options{})
+	defer c.Close()
+	if n, err := c.Incr(ctx, "hits"); err != nil || n != 1 {
+		t.Fatalf("incr: %v %d", err, n)
+	}
+	if n, err := c.IncrBy(ctx, "hits", 41); err != nil || n != 42 {
+		t.Fatalf("incrby: %v %d", err, n)
+	}
+	if n, err := c.Decr(ctx, "hits"); err != nil || n != 41 {
+		t.Fatalf("decr: %v %d", err, n)
+	}
+	if v, err := c.Get(ctx, "hits"); err != nil || v != "41" {
+		t.Fatalf("counter should read back as string: %v %s", err, v)
+	}
+	if f, err := c.IncrByFloat(ctx, "ratio", 0.5); err != nil || f != 0.5 {
+		t.Fatalf("incrbyfloat: %v %f", err, f)
+	}
+	if f, err := c.IncrByFloat(ctx, "hits", 0.5); err != nil || f != 41.5 {
+		t.Fatalf("incrbyfloat on integer: %v %f", err, f)
+	}
+	c.Set(ctx, "name", "bob")
+	if _, err := c.Incr(ctx, "name"); err != cache.ErrNotNumber {
+		t.Fatalf("expected ErrNotNumber, got %v", err)
+	}
+	if _, err := c.IncrByFloat(ctx, "name", 1); err != cache.ErrNotNumber {
+		t.Fatalf("expected ErrNotNumber, got %v", err)
+	}
+}
+
+func TestMemoryCacheCounterInitialTTL(t *testing.T) {
+	ctx := context.Background()
+	c := New(cache.Options{})
+	defer c.Close()
+	if _, err := c.Incr(ctx, "window", cache.WithInitialTTL(40*time.Millisecond)); err != nil {
+		t.Fatalf("incr: %v", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if n, err := c.Incr(ctx, "window", cache.WithInitialTTL(40*time.Millisecond)); err != nil || n != 2 {
+		t.Fatalf("incr: %v %d", err, n)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if _, err := c.Get(ctx, "window"); err != cache.ErrNotFound {
+		t.Fatalf("later increments must not extend the TTL, got %v", err)
+	}
+}
+
+func TestMemoryCacheConditionalSet(t *testing.T) {
+	ctx := context.Background()
+	c := New(cache.Options{})
+	defer c.Close()
+	nx := cache.SetOptions{Mode: cache.SetIfAbsent, TTL: time.Minute}
+	if err := c.SetWithOptions(ctx, "idem", []byte("first"), nx); err != nil {
+		t.Fatalf("set nx: %v", err)
+	}
+	if err := c.SetWithOptions(ctx, "idem", []byte("second"), nx); err != cache.ErrConditionNotMet {
+		t.Fatalf("expected ErrConditionNotMet, got %v", err)
+	}
+	xx := cache.SetOptions{Mode: cache.SetIfPresent}
+	if err := c.SetWithOptions(ctx, "absent", []byte("v"), xx); err != cache.ErrConditionNotMet {
+		t.Fatalf("expected ErrConditionNotMet, got %v", err)
+	}
+	cas := cache.SetOptions{IfEquals: []byte("first"), KeepTTL: true}
+	if err := c.SetWithOptions(ctx, "idem", []byte("swapped"), cas); err != nil {
+		t.Fatalf("cas: %v", err)
+	}
+	if err := c.SetWithOptions(ctx, "idem", []byte("again"), cas); err != cache.ErrConditionNotMet {
+		t.Fatalf("expected stale cas to fail, got %v", err)
+	}
+	if err := c.SetWithOptions(ctx, "absent", []byte("v"), cas); err != cache.ErrConditionNotMet {
+		t.Fatalf("expected cas on missing key to fail, got %v", err)
+	}
+	if v, err := c.Get(ctx, "idem"); err != nil || v != "swapped" {
+		t.Fatalf("get: %v %s", err, v)
+	}
+	if err := c.SetWithOptions(ctx, "short", []byte("v"), cache.SetOptions{TTL: 20 * time.Millisecond}); err != nil {
+		t.Fatalf("set ttl: %v", err)
+	}
+	if err := c.SetWithOptions(ctx, "short", []byte("w"), cache.SetOptions{KeepTTL: true}); err != nil {
+		t.Fatalf("set keepttl: %v", err)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if _, err := c.Get(ctx, "short"); err != cache.ErrNotFound {
+		t.Fatalf("KeepTTL must keep the deadline, got %v", err)
+	}
+	keep := cache.SetOptions{TTL: time.Minute, KeepTTL: true}
+	if err := c.SetWithOptions(ctx, "fresh", []byte("v"), keep); err != nil {
+		t.Fatalf("set keepttl on missing key: %v", err)
+	}
+	if ttl, err := c.TTL(ctx, "fresh"); err != nil || ttl != cache.NoExpiration {
+		t.Fatalf("KeepTTL must store a missing key without expiration, got %v %v", ttl, err)
+	}
+}
+
+func TestMemoryCacheSetIfAbsentFenced(t *testing.T) {
+	ctx := context.Background()
+	c := New(cache.Options{Shards: 8})
+	defer c.Close()
+	token, err := c.SetIfAbsentFenced(ctx, "lock:{job}", "lock:{job}:fence", []byte("a"), 20*time.Millisecond)
+	if err != nil || token != 1 {
+		t.Fatalf("first fenced set: %d %v", token, err)
+	}
+	if _, err := c.SetIfAbsentFenced(ctx, "lock:{job}", "lock:{job}:fence", []byte("b"), time.Minute); err != cache.ErrConditionNotMet {
+		t.Fatalf("expected ErrConditionNotMet, got %v", err)
+	}
+	if v, err := c.Get(ctx, "lock:{job}"); err != nil || v != "a:1" {
+		t.Fatalf("held value: %q %v", v, err)
+	}
+	time.Sleep(30 * time.Millisecond)
+	token, err = c.SetIfAbsentFenced(ctx, "lock:{job}", "lock:{job}:fence", []byte("b"), time.Minute)
+	if err != nil || token != 2 {
+		t.Fatalf("fenced set after expiry: %d %v", token, err)
+	}
+	if v, err := c.Get(ctx, "lock:{job}:fence"); err != nil || v != "2" {
+		t.Fatalf("a refused write must not draw a token: %q %v", v, err)
+	}
+	if err := c.Set(ctx, "bad:fence", "x"); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	if _, err := c.SetIfAbsentFenced(ctx, "bad", "bad:fence", []byte("v"), time.Minute); err != cache.ErrNotNumber {
+		t.Fatalf("expected ErrNotNumber, got %v", err)
+	}
+	if _, err := c.Get(ctx, "bad"); err != cache.ErrNotFound {
+		t.Fatalf("a failed fenced set must not write the key, got %v", err)
+	}
+}
+
+func TestMemoryCacheTTLOps(t *testing.T) {
+	ctx := context.Background()
+	c := New(cache.Options{})
+	defer c.Close()
+	if _, err := c.TTL(ctx, "missing"); err != cache.ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	for _, err := range []error{c.Expire(ctx, "missing", time.Second), c.Persist(ctx, "missing"), c.Touch(ctx, "missing")} {
+		if err != cache.ErrNotFound {
+			t.Fatalf("expected ErrNotFound, got %v", err)
+		}
+	}
+	c.Set(ctx, "session", "v")
+	if ttl, err := c.TTL(ctx, "session"); err != nil || ttl != cache.NoExpiration {
+		t.Fatalf("expected NoExpiration, got %v %v", err, ttl)
+	}
+	if err := c.Touch(ctx, "session"); err != nil {
+		t.Fatalf("touch: %v", err)
+	}
+	if err := c.Expire(ctx, "session", time.Minute); err != nil {
+		t.Fatalf("expire: %v", err)
+	}
+	if ttl, err := c.TTL(ctx, "session"); err != nil || ttl <= 59*time.Second || ttl > time.Minute {
+		t.Fatalf("unexpected ttl: %v %v", err, ttl)
+	}
+	if err := c.Persist(ctx, "session"); err != nil {
+		t.Fatalf("persist: %v", err)
+	}
+	if err := c.Persist(ctx, "session"); err != nil {
+		t.Fatalf("persist without ttl: %v", err)
+	}
+	if ttl, err := c.TTL(ctx, "session"); err != nil || ttl != cache.NoExpiration {
+		t.Fatalf("expected NoExpiration after persist, got %v %v", err, ttl)
+	}
+	if err := c.Expire(ctx, "session", 20*time.Millisecond); err != nil {
+		t.Fatalf("expire: %v", err)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if _, err := c.Get(ctx, "session"); err != cache.ErrNotFound {
+		t.Fatalf("expected key to expire, got %v", err)
+	}
+	c.Set(ctx, "gone", "v")
+	if err := c.Expire(ctx, "gone", 0); err != nil {
+		t.Fatalf("expire zero: %v", err)
+	}
+	if ok, _ := c.Exists(ctx, "gone"); ok {
+		t.Fatalf("expire with zero ttl must delete the key")
+	}
+}
+
+func TestMemoryCacheScanAndFlush(t *testing.T) {
+	ctx := context.Background()
+	c := New(cache.Options{Namespace: "app", Shards: 4})
+	defer c.Close()
+	for i := 0; i < 50; i++ {
+		c.Set(ctx, "user:"+strconv.Itoa(i), "v")
+	}
+	c.Set(ctx, "order:1", "v")
+	c.SetWithTTL(ctx, "user:expired", "v", time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+
+	seen := map[string]bool{}
+	it := c.Scan(ctx, "user:*")
+	for it.Next(ctx) {
+		if seen[it.Key()] {
+			t.Fatalf("duplicate key %q", it.Key())
+		}
+		seen[it.Key()] = true
+	}
+	if err := it.Err(); err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	if len(seen) != 50 || !seen["user:7"] || seen["user:expired"] {
+		t.Fatalf("unexpected scan result: %d keys", len(seen))
+	}
+
+	it = c.Scan(ctx, "user:[1-2]?")
+	n := 0
+	for it.Next(ctx) {
+		n++
+	}
+	if n != 20 {
+		t.Fatalf("expected 20 keys for class pattern, got %d", n)
+	}
+
+	deleted, err := c.FlushNamespace(ctx)
+	if err != nil || deleted != 51 {
+		t.Fatalf("flush: %v %d", err, deleted)
+	}
+	if entries, bytes, pending := usage(c); entries != 0 || bytes != 0 || pending != 0 {
+		t.Fatalf("expected empty cache after flush, got %d entries %d bytes %d pending", entries, bytes, pending)
+	}
+
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	it = c.Scan(cctx, "*")
+	if it.Next(cctx) || it.Err() != cache.ErrTimeout {
+		t.Fatalf("expected ErrTimeout, got %v", it.Err())
+	}
+}
+
+func TestMemoryCacheTags(t *testing.T) {
+	ctx := context.Background()
+	c := New(cache.Options{Shards: 4})
+	defer c.Close()
+	c.SetWithTags(ctx, "profile:42", []byte("p"), 0, "user:42")
+	c.SetWithTags(ctx, "orders:42", []byte("o"), time.Minute, "user:42", "orders")
+	c.SetWithTags(ctx, "orders:7", []byte("o"), 0, "user:7", "orders")
+	c.Set(ctx, "plain", "v")
+
+	if err := c.InvalidateTags(ctx, "user:42"); err != nil {
+		t.Fatalf("invalidate: %v", err)
+	}
+	for key, want := range map[string]bool{"profile:42": false, "orders:42": false, "orders:7": true, "plain": true} {
+		if ok, _ := c.Exists(ctx, key); ok != want {
+			t.Fatalf("%s: exists=%v, want %v", key, ok, want)
+		}
+	}
+
+	// Rewriting a key without tags detaches it.
+	c.Set(ctx, "orders:7", "v2")
+	c.InvalidateTags(ctx, "orders")
+	if ok, _ := c.Exists(ctx, "orders:7"); !ok {
+		t.Fatalf("untagged rewrite must survive invalidation")
+	}
+
+	// Expired, evicted and deleted entries release their index entries.
+	c.SetWithTags(ctx, "short", []byte("v"), 5*time.Millisecond, "gone")
+	c.SetWithTags(ctx, "deleted", []byte("v"), 0, "gone")
+	c.Del(ctx, "deleted")
+	deadline := time.Now().Add(time.Second)
+	for tagCount(c) != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("tag index leaked %d tags", tagCount(c))
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	bounded := New(cache.Options{MaxEntries: 2})
+	defer bounded.Close()
+	for i := 0; i < 10; i++ {
+		bounded.SetWithTags(ctx, "k"+strconv.Itoa(i), []byte("v"), 0, "t"+strconv.Itoa(i))
+	}
+	if n := tagCount(bounded); n != 2 {
+		t.Fatalf("expected evicted entries to leave the index, got %d tags", n)
+	}
+}
+
+func tagCount(c *Cache) int {
+	n := 0
+	for _, s := range c.shards {
+		s.mu.RLock()
+		n += len(s.tags)
+		s.mu.RUnlock()
+	}
+	return n
+}
+
+func TestMemoryCacheNegative(t *testing.T) {
+	ctx := context.Background()
+	c := New(cache.Options{})
+	defer c.Close()
+	c.Set(ctx, "empty", "")
+	if err := c.SetAbsent(ctx, "gone", 20*time.Millisecond); err != nil {
+		t.Fatalf("set absent: %v", err)
+	}
+	if v, err := c.Get(ctx, "empty"); err != nil || v != "" {
+		t.Fatalf("empty value must read as a hit: %v %q", err, v)
+	}
+	if _, err := c.GetBytes(ctx, "gone"); err != cache.ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	for key, want := range map[string]bool{"empty": false, "gone": true, "missing": false} {
+		if absent, err := c.IsAbsent(ctx, key); err != nil || absent != want {
+			t.Fatalf("%s: absent=%v %v, want %v", key, absent, err, want)
+		}
+	}
+	if ok, _ := c.Exists(ctx, "gone"); ok {
+		t.Fatalf("absent marker must not exist")
+	}
+	if got, _ := c.MGet(ctx, "empty", "gone"); len(got) != 1 {
+		t.Fatalf("expected only the empty value from mget, got %v", got)
+	}
+	if _, err := c.Incr(ctx, "gone"); err != cache.ErrNotNumber {
+		t.Fatalf("expected ErrNotNumber, got %v", err)
+	}
+	if err := c.DelIfEquals(ctx, "gone", nil); err != cache.ErrConditionNotMet {
+		t.Fatalf("a marker must not equal an empty value, got %v", err)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if _, err := c.Get(ctx, "gone"); err != cache.ErrNotFound {
+		t.Fatalf("expected the marker to expire, got %v", err)
+	}
+
+	c.SetAbsent(ctx, "gone", time.Minute)
+	c.Set(ctx, "gone", "back")
+	if v, err := c.Get(ctx, "gone"); err != nil || v != "back" {
+		t.Fatalf("a write must replace the marker: %v %q", err, v)
+	}
+}
+
+func TestMemoryCacheHash(t *testing.T) {
+	ctx := context.Background()
+	c := New(cache.Options{Namespace: "app", MaxBytes: 1 << 10})
+	defer c.Close()
+	if err := c.HSet(ctx, "user:1", map[string][]byte{"name": []byte("ada"), "bio": nil}); err != nil {
+		t.Fatalf("hset: %v", err)
+	}
+	if v, err := c.HGet(ctx, "user:1", "name"); err != nil || string(v) != "ada" {
+		t.Fatalf("hget: %v %q", err, v)
+	}
+	if v, err := c.HGet(ctx, "user:1", "bio"); err != nil || len(v) != 0 {
+		t.Fatalf("an empty field must be found: %v %q", err, v)
+	}
+	if _, err := c.HGet(ctx, "user:1", "age"); err != cache.ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if n, err := c.HIncrBy(ctx, "user:1", "visits", 3); err != nil || n != 3 {
+		t.Fatalf("hincrby: %v %d", err, n)
+	}
+	if _, err := c.HIncrBy(ctx, "user:1", "name", 1); err != cache.ErrNotNumber {
+		t.Fatalf("expected ErrNotNumber, got %v", err)
+	}
+	all, err := c.HGetAll(ctx, "user:1")
+	if err != nil || len(all) != 3 || string(all["visits"]) != "3" {
+		t.Fatalf("hgetall: %v %v", err, all)
+	}
+	if got, _ := c.HGetAll(ctx, "missing"); got == nil || len(got) != 0 {
+		t.Fatalf("expected an empty hash, got %v", got)
+	}
+
+	// Hashes and plain values do not mix.
+	c.Set(ctx, "plain", "v")
+	if err := c.HSet(ctx, "plain", map[string][]byte{"f": nil}); err != cache.ErrWrongType {
+		t.Fatalf("expected ErrWrongType, got %v", err)
+	}
+	if _, err := c.Get(ctx, "user:1"); err != cache.ErrWrongType {
+		t.Fatalf("expected ErrWrongType, got %v", err)
+	}
+	if _, err := c.Incr(ctx, "user:1"); err != cache.ErrWrongType {
+		t.Fatalf("expected ErrWrongType, got %v", err)
+	}
+
+	if c.shards[0].bytes <= int64(len("app:user:1")) {
+		t.Fatalf("fields must count against MaxBytes, got %d bytes", c.shards[0].bytes)
+	}
+	c.HDel(ctx, "user:1", "name", "bio", "visits")
+	if ok, _ := c.Exists(ctx, "user:1"); ok {
+		t.Fatalf("expected the empty hash to be deleted")
+	}
+	if c.shards[0].bytes != int64(len("app:plain")+1) {
+		t.Fatalf("unexpected byte count %d", c.shards[0].bytes)
+	}
+	c.HSet(ctx, "user:2", map[string][]byte{"name": []byte("bob")})
+	c.Set(ctx, "user:2", "flat")
+	if v, _ := c.Get(ctx, "user:2"); v != "flat" {
+		t.Fatalf("a plain write must replace the hash, got %q", v)
+	}
+}
+
+func TestSortedSetMatchesReference(t *testing.T) {
+	z := newSortedSet()
+	ref := map[string]float64{}
+	rng := rand.New(rand.NewPCG(1, 2))
+	for i := range 5000 {
+		m := strconv.Itoa(rng.IntN(300))
+		if rng.IntN(4) == 0 {
+			if z.remove(m) != (ref[m] != 0) {
+				t.Fatalf("step %d: remove %s disagrees", i, m)
+			}
+			delete(ref, m)
+			continue
+		}
+		score := float64(rng.IntN(50) + 1)
+		z.add(m, score)
+		ref[m] = score
+	}
+	want := make([]cache.ScoredMember, 0, len(ref))
+	for m, s := range ref {
+		want = append(want, cache.ScoredMember{Member: m, Score: s})
+	}
+	slices.SortFunc(want, func(a, b cache.ScoredMember) int {
+		if c := cmp.Compare(a.Score, b.Score); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Member, b.Member)
+	})
+	if got := z.rangeByRank(0, -1); !slices.Equal(got, want) {
+		t.Fatalf("order differs from reference")
+	}
+	for i, m := range want {
+		if r := z.rank(m.Member); r != i {
+			t.Fatalf("rank of %s: got %d, want %d", m.Member, r, i)
+		}
+	}
+	if got := z.rangeByRank(10, 19); !slices.Equal(got, want[10:20]) {
+		t.Fatalf("rank range differs from reference")
+	}
+	var inRange []cache.ScoredMember
+	for _, m := range want {
+		if m.Score >= 10 && m.Score <= 20 {
+			inRange = append(inRange, m)
+		}
+	}
+	if got := z.rangeByScore(10, 20); !slices.Equal(got, inRange) {
+		t.Fatalf("score range differs from reference")
+	}
+}
+
+func TestMemoryCacheSortedSet(t *testing.T) {
+	ctx := context.Background()
+	c := New(cache.Options{})
+	defer c.Close()
+	err := c.ZAdd(ctx, "board",
+		cache.ScoredMember{Member: "ann", Score: 30},
+		cache.ScoredMember{Member: "bob", Score: 10},
+		cache.ScoredMember{Member: "cid", Score: 20},
+		cache.ScoredMember{Member: "dee", Score: 20},
+	)
+	if err != nil {
+		t.Fatalf("zadd: %v", err)
+	}
+	c.ZAdd(ctx, "board", cache.ScoredMember{Member: "bob", Score: 40})
+	top, _ := c.ZRange(ctx, "board", -2, -1)
+	if len(top) != 2 || top[0].Member != "ann" || top[1] != (cache.ScoredMember{Member: "bob", Score: 40}) {
+		t.Fatalf("unexpected top members %v", top)
+	}
+	if r, err := c.ZRank(ctx, "board", "dee"); err != nil || r != 1 {
+		t.Fatalf("zrank: %v %d", err, r)
+	}
+	if _, err := c.ZRank(ctx, "board", "eve"); err != cache.ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if s, err := c.ZScore(ctx, "board", "cid"); err != nil || s != 20 {
+		t.Fatalf("zscore: %v %v", err, s)
+	}
+	if got, _ := c.ZRangeByScore(ctx, "board", 20, math.Inf(1)); len(got) != 4 || got[0].Member != "cid" {
+		t.Fatalf("unexpected score range %v", got)
+	}
+	if n, err := c.ZRemRangeByScore(ctx, "board", math.Inf(-1), 20); err != nil || n != 2 {
+		t.Fatalf("zremrangebyscore: %v %d", err, n)
+	}
+	if n, _ := c.ZCard(ctx, "board"); n != 2 {
+		t.Fatalf("expected 2 members left, got %d", n)
+	}
+	if got, err := c.ZRange(ctx, "missing", 0, -1); err != nil || got == nil || len(got) != 0 {
+		t.Fatalf("expected an empty range, got %v %v", got, err)
+	}
+	c.Set(ctx, "plain", "v")
+	if _, err := c.ZCard(ctx, "plain"); err != cache.ErrWrongType {
+		t.Fatalf("expected ErrWrongType, got %v", err)
+	}
+	c.ZRem(ctx, "board", "ann", "bob")
+	if ok, _ := c.Exists(ctx, "board"); ok {
+		t.Fatalf("expected the empty set to be deleted")
+	}
+	if c.shards[0].bytes != int64(len("plain")+1) {
+		t.Fatalf("unexpected byte count %d", c.shards[0].bytes)
+	}
+}
+
+func TestMemoryCacheList(t *testing.T) {
+	ctx := context.Background()
+	c := New(cache.Options{})
+	defer c.Close()
+	c.RPush(ctx, "recent", []byte("b"), []byte("c"))
+	c.LPush(ctx, "recent", []byte("a"), []byte("z"))
+	want := []string{"z", "a", "b", "c"}
+	for i := range 20 {
+		c.RPush(ctx, "grow", []byte(strconv.Itoa(i)))
+	}
+	got, err := c.LRange(ctx, "recent", 0, -1)
+	if err != nil || len(got) != len(want) {
+		t.Fatalf("lrange: %v %q", err, got)
+	}
+	for i, v := range got {
+		if string(v) != want[i] {
+			t.Fatalf("unexpected order %q", got)
+		}
+	}
+	if err := c.LTrim(ctx, "grow", -3, -1); err != nil {
+		t.Fatalf("ltrim: %v", err)
+	}
+	if got, _ := c.LRange(ctx, "grow", 0, 0); len(got) != 1 || string(got[0]) != "17" {
+		t.Fatalf("expected the last three values to be kept, got %q", got)
+	}
+	if v, err := c.RPop(ctx, "recent"); err != nil || string(v) != "c" {
+		t.Fatalf("rpop: %v %q", err, v)
+	}
+	if v, err := c.LPop(ctx, "recent"); err != nil || string(v) != "z" {
+		t.Fatalf("lpop: %v %q", err, v)
+	}
+	if n, _ := c.LLen(ctx, "recent"); n != 2 {
+		t.Fatalf("expected 2 values, got %d", n)
+	}
+	c.LTrim(ctx, "recent", 5, 10)
+	if ok, _ := c.Exists(ctx, "recent"); ok {
+		t.Fatalf("expected the emptied list to be deleted")
+	}
+	if _, err := c.LPop(ctx, "recent"); err != cache.ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	c.Set(ctx, "plain", "v")
+	if err := c.RPush(ctx, "plain", []byte("x")); err != cache.ErrWrongType {
+		t.Fatalf("expected ErrWrongType, got %v", err)
+	}
+}
+
+func TestMemoryCacheBlockingPop(t *testing.T) {
+	ctx := context.Background()
+	c := New(cache.Options{Shards: 4})
+	got := make(chan string)
+	for range 2 {
+		go func() {
+			v, err := c.BRPop(ctx, "jobs")
+			if err != nil {
+				got <- err.Error()
+				return
+			}
+			got <- string(v)
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	c.LPush(ctx, "jobs", []byte("first"))
+	if v := <-got; v != "first" {
+		t.Fatalf("expected a waiting pop to receive the push, got %q", v)
+	}
+
+	tctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if _, err := c.BLPop(tctx, "idle"); err != cache.ErrTimeout {
+		t.Fatalf("expected ErrTimeout, got %v", err)
+	}
+
+	c.Close()
+	if v := <-got; v != cache.ErrClosed.Error() {
+		t.Fatalf("expected Close to release the remaining pop, got %q", v)
+	}
+}
+
+func BenchmarkMemoryCacheParallel(b *testing.B) {
+	ctx := context.Background()
+	keys := make([]string, 1024)
+	for i := range keys {
+		keys[i] = "key:" + strconv.Itoa(i)
+	}
+	for _, shards := range []int{1, 32} {
+		m := New(cache.Options{Shards: shards})
+		for _, k := range keys {
+			m.Set(ctx, k, "value")
+		}
+		b.Run("get/shards="+strconv.Itoa(shards), func(b *testing.B) {
+			b.RunParallel(func(pb *testing.PB) {
+				for i := 0; pb.Next(); i++ {
+					m.GetBytes(ctx, keys[i%len(keys)])
+				}
+			})
+		})
+		b.Run("set/shards="+strconv.Itoa(shards), func(b *testing.B) {
+			b.RunParallel(func(pb *testing.PB) {
+				for i := 0; pb.Next(); i++ {
+					m.Set(ctx, keys[i%len(keys)], "value")
+				}
+			})
+		})
+		b.Run("mixed/shards="+strconv.Itoa(shards), func(b *testing.B) {
+			b.RunParallel(func(pb *testing.PB) {
+				for i := 0; pb.Next(); i++ {
+					if i%4 == 0 {
+						m.Set(ctx, keys[i%len(keys)], "value")
+					} else {
+						m.GetBytes(ctx, keys[i%len(keys)])
+					}
+				}
+			})
+		})
+		m.Close()
+	}
+}
+
+// usage sums the entries, bytes and pending deadlines over all shards.
+func usage(c *Cache) (entries int, bytes int64, pending int) {
+	for _, s := range c.shards {
+		s.mu.RLock()
+		entries += len(s.store)
+		bytes += s.bytes
+		pending += s.expiries.Len()
+		s.mu.RUnlock()
+	}
+	return entries, bytes, pending
+}
+
+// recordingLogger captures the fields of every log entry.
+type recordingLogger struct {
+	mu      sync.Mutex
+	entries []map[string]string
+}
+
+func (l *recordingLogger) Debug() logger.Entry { return l.entry() }
+func (l *recordingLogger) Info() logger.Entry  { return l.entry() }
+func (l *recordingLogger) Error() logger.Entry { return l.entry() }
+
+func (l *recordingLogger) entry() logger.Entry {
+	return &recordingEntry{l: l, fields: make(map[string]string)}
+}
+
+// reasons returns the reason field of every entry logged for op.
+func (l *recordingLogger) reasons(op string) []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var out []string
+	for _, f := range l.entries {
+		if f["op"] == op {
+			out = append(out, f["reason"])
+		}
+	}
+	return out
+}
+
+type recordingEntry struct {
+	l      *recordingLogger
+	fields map[string]string
+}
+
+func (e *recordingEntry) Str(key, val string) logger.Entry {
+	e.fields[key] = val
+	return e
+}
+func (e *recordingEntry) Int(key string, val int) logger.Entry           { return e }
+func (e *recordingEntry) Int64(key string, val int64) logger.Entry       { return e }
+func (e *recordingEntry) Float64(key string, val float64) logger.Entry   { return e }
+func (e *recordingEntry) Bool(key string, val bool) logger.Entry         { return e }
+func (e *recordingEntry) Dur(key string, val time.Duration) logger.Entry { return e }
+func (e *recordingEntry) Time(key string, val time.Time) logger.Entry    { return e }
+func (e *recordingEntry) Err(err error) logger.Entry                     { return e }
+func (e *recordingEntry) Msg(msg string) {
+	e.l.mu.Lock()
+	e.l.entries = append(e.l.entries, e.fields)
+	e.l.mu.Unlock()
+}
@@ -0,0 +1,48 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/carlosealves2/go-infrakit/cache"
+)
+
+var _ cache.NegativeCache = (*Cache)(nil)
+
+// SetAbsent stores an empty entry flagged as absent. It counts against the
+// size limits and is evicted like any other entry.
+func (c *Cache) SetAbsent(ctx context.Context, key string, ttl time.Duration) error {
+	key, keyLen := c.formatKey(key)
+	start := time.Now()
+	if err := c.checkCtx(ctx); err != nil {
+		c.observe(ctx, "set_absent", keyLen, false, start, err)
+		return err
+	}
+	sh := c.shardFor(key)
+	sh.mu.Lock()
+	evicted := sh.setLocked(key, nil, ttl)
+	if e, ok := sh.store[key]; ok {
+		e.absent = true
+	}
+	sh.mu.Unlock()
+	c.observe(ctx, "set_absent", keyLen, false, start, nil)
+	c.observeEvictions(ctx, evicted)
+	return nil
+}
+
+// IsAbsent reports whether key holds a live absent marker.
+func (c *Cache) IsAbsent(ctx context.Context, key string) (bool, error) {
+	key, keyLen := c.formatKey(key)
+	start := time.Now()
+	if err := c.checkCtx(ctx); err != nil {
+		c.observe(ctx, "is_absent", keyLen, false, start, err)
+		return false, err
+	}
+	sh := c.shardFor(key)
+	sh.mu.RLock()
+	e, ok := sh.lookupLocked(key)
+	ok = ok && e.absent
+	sh.mu.RUnlock()
+	c.observe(ctx, "is_absent", keyLen, false, start, nil)
+	return ok, nil
+}
@@ -0,0 +1,103 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/carlosealves2/go-infrakit/cache"
+	"github.com/carlosealves2/go-infrakit/internal/glob"
+)
+
+var _ cache.ScanCache = (*Cache)(nil)
+
+// Scan returns an iterator over the keys matching pattern. Each shard is
+// snapshotted under its lock when the iterator reaches it, so a scan never
+// holds a lock while the caller consumes keys.
+func (c *Cache) Scan(ctx context.Context, pattern string) cache.KeyIterator {
+	return &keyIterator{c: c, pattern: pattern, start: time.Now()}
+}
+
+type keyIterator struct {
+	c       *Cache
+	pattern string
+	start   time.Time
+	shard   int
+	keys    []string
+	key     string
+	found   int
+	err     error
+	done    bool
+}
+
+func (it *keyIterator) Next(ctx context.Context) bool {
+	if it.done {
+		return false
+	}
+	for len(it.keys) == 0 {
+		if err := it.c.checkCtx(ctx); err != nil {
+			it.err = err
+			return it.finish(ctx)
+		}
+		if it.shard == len(it.c.shards) {
+			return it.finish(ctx)
+		}
+		it.keys = it.snapshot(it.c.shards[it.shard])
+		it.shard++
+	}
+	it.key, it.keys = it.keys[0], it.keys[1:]
+	it.found++
+	return true
+}
+
+// snapshot collects the live keys of sh matching the pattern.
+func (it *keyIterator) snapshot(sh *shard) []string {
+	now := time.Now().UnixNano()
+	var keys []string
+	sh.mu.RLock()
+	defer sh.mu.RUnlock()
+	for k, e := range sh.store {
+		if e.expired(now) {
+			continue
+		}
+		k = k[len(k)-it.c.keyLen(k):]
+		if glob.Match(it.pattern, k) {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+func (it *keyIterator) finish(ctx context.Context) bool {
+	it.done, it.key = true, ""
+	it.c.observe(ctx, "scan", len(it.pattern), it.found > 0, it.start, it.err, attribute.Int("keys", it.found))
+	return false
+}
+
+func (it *keyIterator) Key() string { return it.key }
+func (it *keyIterator) Err() error  { return it.err }
+
+// FlushNamespace removes every entry, one shard lock at a time. A memory
+// cache holds a single namespace, so this empties the cache.
+func (c *Cache) FlushNamespace(ctx context.Context) (int64, error) {
+	start := time.Now()
+	if err := c.checkCtx(ctx); err != nil {
+		c.observe(ctx, "flush", 0, false, start, err)
+		return 0, err
+	}
+	now := time.Now().UnixNano()
+	var n int64
+	for _, sh := range c.shards {
+		sh.mu.Lock()
+		for k, e := range sh.store {
+			if !e.expired(now) {
+				n++
+			}
+			sh.deleteLocked(k)
+		}
+		sh.mu.Unlock()
+	}
+	c.observe(ctx, "flush", 0, n > 0, start, nil, attribute.Int("deleted", int(n)))
+	return n, nil
+}
@@ -0,0 +1,273 @@
+package memory
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/carlosealves2/go-infrakit/cache"
+)
+
+// entry is a stored value together with its deadline. Collections keep
+// their elements in a dedicated field and leave value empty.
+type entry struct {
+	key      string
+	value    []byte
+	expireAt int64 // unix nanoseconds, zero when the entry never expires
+	index    int   // position in the expiry heap, -1 when unscheduled
+	tags     []string
+	absent   bool // negative cache marker, read as missing
+
+	hash  map[string][]byte
+	zset  *sortedSet
+	list  *deque
+	extra int64 // bytes held by collection elements
+
+	// Eviction policy bookkeeping.
+	elem     *list.Element
+	freq     int
+	frequent bool
+}
+
+// size is the number of bytes an entry counts against Options.MaxBytes.
+func (e *entry) size() int64 {
+	return int64(len(e.key)+len(e.value)) + e.extra
+}
+
+// isValue reports whether e holds a plain value rather than a collection.
+func (e *entry) isValue() bool {
+	return e.hash == nil && e.zset == nil && e.list == nil
+}
+
+// replace turns e into a plain value, dropping any marker or collection.
+func (e *entry) replace(value []byte) {
+	e.value = value
+	e.absent = false
+	e.hash = nil
+	e.zset = nil
+	e.list = nil
+	e.extra = 0
+}
+
+func (e *entry) expired(now int64) bool {
+	return e.expireAt != 0 && now >= e.expireAt
+}
+
+// shard is an independently locked segment of the cache. Each shard owns its
+// entries, their deadlines and its share of the size limits.
+type shard struct {
+	mu         sync.RWMutex
+	store      map[string]*entry
+	expiries   expiryHeap
+	policy     policy
+	bytes      int64
+	maxEntries int
+	maxBytes   int64
+	// tags indexes tagged entries by tag. It only holds resident entries:
+	// removing an entry for any reason also drops it from the index.
+	tags map[string]map[*entry]struct{}
+	// pushed wakes blocking pops when a list grows. It is created by the
+	// first blocking pop and uses mu as its lock.
+	pushed *sync.Cond
+
+	// onHead is called, with mu held, when a write moves the earliest
+	// deadline of the shard.
+	onHead func()
+}
+
+// eviction describes an entry dropped by the cache itself.
+type eviction struct {
+	key    string
+	reason string
+}
+
+// Eviction reasons reported with the "evict" op.
+const (
+	reasonExpired    = "expired"
+	reasonMaxEntries = "max_entries"
+	reasonMaxBytes   = "max_bytes"
+)
+
+// setLocked stores value under key, replacing any previous deadline and
+// tags. A non-positive ttl stores the value without expiration. It returns
+// the entries evicted to make room, which the caller reports once unlocked.
+func (s *shard) setLocked(key string, value []byte, ttl time.Duration) []eviction {
+	e, ok := s.store[key]
+	if ok {
+		s.bytes -= e.size()
+		s.untagLocked(e)
+	} else {
+		e = &entry{key: key, index: -1}
+		s.store[key] = e
+	}
+	e.replace(value)
+	e.expireAt = 0
+	if ttl > 0 {
+		e.expireAt = time.Now().Add(ttl).UnixNano()
+	}
+	s.bytes += e.size()
+	s.scheduleLocked(e)
+	if s.policy == nil {
+		return nil
+	}
+	if ok {
+		s.policy.accessed(e)
+	} else {
+		s.policy.added(e)
+	}
+	return s.enforceLocked(e)
+}
+
+// updateLocked replaces the value of a resident entry, keeping its deadline.
+func (s *shard) updateLocked(e *entry, value []byte) []eviction {
+	return s.resizeLocked(e, func() { e.replace(value) })
+}
+
+// resizeLocked applies change to a resident entry, then accounts for its
+// new size and enforces the limits.
+func (s *shard) resizeLocked(e *entry, change func()) []eviction {
+	s.bytes -= e.size()
+	change()
+	s.bytes += e.size()
+	if s.policy == nil {
+		return nil
+	}
+	s.policy.accessed(e)
+	return s.enforceLocked(e)
+}
+
+// lookupLocked returns the live entry for key, hiding expired ones that the
+// reaper has not collected yet.
+func (s *shard) lookupLocked(key string) (*entry, bool) {
+	e, ok := s.store[key]
+	if !ok || e.expired(time.Now().UnixNano()) {
+		return nil, false
+	}
+	return e, true
+}
+
+// readLock locks the shard for a read. Eviction policies update their
+// bookkeeping on reads, so a bounded shard takes the write lock.
+func (s *shard) readLock() {
+	if s.policy != nil {
+		s.mu.Lock()
+	} else {
+		s.mu.RLock()
+	}
+}
+
+func (s *shard) readUnlock() {
+	if s.policy != nil {
+		s.mu.Unlock()
+	} else {
+		s.mu.RUnlock()
+	}
+}
+
+// getLocked returns a copy of the live value for key and records the access.
+// Absent markers read as missing and collections return ErrWrongType. The
+// shard must be locked with readLock.
+func (s *shard) getLocked(key string) ([]byte, error) {
+	e, ok := s.lookupLocked(key)
+	switch {
+	case !ok, e.absent:
+		return nil, cache.ErrNotFound
+	case !e.isValue():
+		return nil, cache.ErrWrongType
+	}
+	s.accessedLocked(e)
+	return append([]byte(nil), e.value...), nil
+}
+
+// accessedLocked records a read of e for the eviction policy.
+func (s *shard) accessedLocked(e *entry) {
+	if s.policy != nil {
+		s.policy.accessed(e)
+	}
+}
+
+func (s *shard) deleteLocked(key string) {
+	if e, ok := s.store[key]; ok {
+		s.removeLocked(e)
+		if s.policy != nil {
+			s.policy.removed(e)
+		}
+	}
+}
+
+// removeLocked drops e from the store and the expiry heap.
+func (s *shard) removeLocked(e *entry) {
+	s.untagLocked(e)
+	s.expiries.unschedule(e)
+	delete(s.store, e.key)
+	s.bytes -= e.size()
+}
+
+// scheduleLocked updates the deadline of e in the expiry heap and notifies
+// the reaper when e becomes the earliest deadline.
+func (s *shard) scheduleLocked(e *entry) {
+	s.expiries.schedule(e)
+	if s.expiries.peek() == e {
+		s.onHead()
+	}
+}
+
+// enforceLocked evicts entries until the shard fits its limits. Expired
+// entries still waiting for the reaper are dropped before live ones, and
+// the entry just written is only evicted when nothing else is left.
+func (s *shard) enforceLocked(written *entry) []eviction {
+	if !s.overLimitLocked() {
+		return nil
+	}
+	evicted := s.expireLocked(time.Now().UnixNano(), nil)
+	skipped := false
+	for s.overLimitLocked() {
+		reason := reasonMaxBytes
+		if s.maxEntries > 0 && len(s.store) > s.maxEntries {
+			reason = reasonMaxEntries
+		}
+		e := s.policy.evict()
+		if e == written {
+			skipped = true
+			continue
+		}
+		if e == nil {
+			if !skipped {
+				break
+			}
+			e, skipped = written, false
+		}
+		s.removeLocked(e)
+		evicted = append(evicted, eviction{key: e.key, reason: reason})
+	}
+	if skipped {
+		s.policy.restored(written)
+	}
+	return evicted
+}
+
+func (s *shard) overLimitLocked() bool {
+	return (s.maxEntries > 0 && len(s.store) > s.maxEntries) ||
+		(s.maxBytes > 0 && s.bytes > s.maxBytes)
+}
+
+// expireLocked deletes every entry whose deadline is not after now,
+// appending them to evicted.
+func (s *shard) expireLocked(now int64, evicted []eviction) []eviction {
+	for {
+		e := s.expiries.peek()
+		if e == nil || !e.expired(now) {
+			return evicted
+		}
+		s.deleteLocked(e.key)
+		evicted = append(evicted, eviction{key: e.key, reason: reasonExpired})
+	}
+}
+
+// nextDeadlineLocked returns the earliest pending deadline, or zero.
+func (s *shard) nextDeadlineLocked() int64 {
+	if e := s.expiries.peek(); e != nil {
+		return e.expireAt
+	}
+	return 0
+}
@@ -0,0 +1,216 @@
+package memory
+
+import (
+	"math/rand/v2"
+
+	"github.com/carlosealves2/go-infrakit/cache"
+)
+
+const (
+	skiplistMaxLevel = 32
+	// skiplistP is the chance of a node reaching the next level.
+	skiplistP = 0.25
+)
+
+// sortedSet orders members by score, then by member, in a skiplist whose
+// links record how many nodes they span, so ranks are found in logarithmic
+// time as well. scores indexes members for direct lookups.
+type sortedSet struct {
+	head   *skipNode
+	level  int
+	length int
+	scores map[string]float64
+}
+
+type skipNode struct {
+	member string
+	score  float64
+	level  []skipLink
+}
+
+type skipLink struct {
+	next *skipNode
+	span int // nodes moved over by following next; to the end when nil
+}
+
+func newSortedSet() *sortedSet {
+	return &sortedSet{
+		head:   &skipNode{level: make([]skipLink, skiplistMaxLevel)},
+		level:  1,
+		scores: make(map[string]float64),
+	}
+}
+
+// before reports whether n sorts before (score, member).
+func (n *skipNode) before(score float64, member string) bool {
+	return n.score < score || (n.score == score && n.member < member)
+}
+
+func randomLevel() int {
+	lvl := 1
+	for lvl < skiplistMaxLevel && rand.Float64() < skiplistP {
+		lvl++
+	}
+	return lvl
+}
+
+// add inserts member or moves it to its new score. It reports whether the
+// member is new.
+func (z *sortedSet) add(member string, score float64) bool {
+	cur, ok := z.scores[member]
+	if ok {
+		if cur == score {
+			return false
+		}
+		z.unlink(member, cur)
+	}
+	z.insert(member, score)
+	z.scores[member] = score
+	return !ok
+}
+
+// remove deletes member and reports whether it was present.
+func (z *sortedSet) remove(member string) bool {
+	score, ok := z.scores[member]
+	if ok {
+		z.unlink(member, score)
+		delete(z.scores, member)
+	}
+	return ok
+}
+
+func (z *sortedSet) insert(member string, score float64) {
+	var update [skiplistMaxLevel]*skipNode
+	var rank [skiplistMaxLevel]int
+	x := z.head
+	for i := z.level - 1; i >= 0; i-- {
+		if i < z.level-1 {
+			rank[i] = rank[i+1]
+		}
+		for x.level[i].next != nil && x.level[i].next.before(score, member) {
+			rank[i] += x.level[i].span
+			x = x.level[i].next
+		}
+		update[i] = x
+	}
+	lvl := randomLevel()
+	if lvl > z.level {
+		for i := z.level; i < lvl; i++ {
+			update[i] = z.head
+			update[i].level[i].span = z.length
+		}
+		z.level = lvl
+	}
+	n := &skipNode{member: member, score: score, level: make([]skipLink, lvl)}
+	for i := range lvl {
+		n.level[i].next = update[i].level[i].next
+		update[i].level[i].next = n
+		n.level[i].span = update[i].level[i].span - (rank[0] - rank[i])
+		update[i].level[i].span = rank[0] - rank[i] + 1
+	}
+	for i := lvl; i < z.level; i++ {
+		update[i].level[i].span++
+	}
+	z.length++
+}
+
+func (z *sortedSet) unlink(member string, score float64) {
+	var update [skiplistMaxLevel]*skipNode
+	x := z.head
+	for i := z.level - 1; i >= 0; i-- {
+		for x.level[i].next != nil && x.level[i].next.before(score, member) {
+			x = x.level[i].next
+		}
+		update[i] = x
+	}
+	x = x.level[0].next
+	for i := range z.level {
+		if update[i].level[i].next == x {
+			update[i].level[i].span += x.level[i].span - 1
+			update[i].level[i].next = x.level[i].next
+		} else {
+			update[i].level[i].span--
+		}
+	}
+	for z.level > 1 && z.head.level[z.level-1].next == nil {
+		z.level--
+	}
+	z.length--
+}
+
+// rank returns the zero-based position of member, or -1 when it is missing.
+func (z *sortedSet) rank(member string) int {
+	score, ok := z.scores[member]
+	if !ok {
+		return -1
+	}
+	rank := 0
+	x := z.head
+	for i := z.level - 1; i >= 0; i-- {
+		for x.level[i].next != nil && (x.level[i].next.before(score, member) || x.level[i].next.member == member) {
+			rank += x.level[i].span
+			x = x.level[i].next
+		}
+		if x.member == member && x != z.head {
+			return rank - 1
+		}
+	}
+	return -1
+}
+
+// nodeAt returns the node at the zero-based rank, which must be in range.
+func (z *sortedSet) nodeAt(rank int) *skipNode {
+	rank++
+	traversed := 0
+	x := z.head
+	for i := z.level - 1; i >= 0; i-- {
+		for x.level[i].next != nil && traversed+x.level[i].span <= rank {
+			traversed += x.level[i].span
+			x = x.level[i].next
+		}
+		if traversed == rank {
+			return x
+		}
+	}
+	return nil
+}
+
+// firstFrom returns the first node scored at least lo.
+func (z *sortedSet) firstFrom(lo float64) *skipNode {
+	x := z.head
+	for i := z.level - 1; i >= 0; i-- {
+		for x.level[i].next != nil && x.level[i].next.score < lo {
+			x = x.level[i].next
+		}
+	}
+	return x.level[0].next
+}
+
+// rangeByRank returns the members ranked start through stop, with negative
+// ranks counting from the end as in Redis.
+func (z *sortedSet) rangeByRank(start, stop int) []cache.ScoredMember {
+	if start < 0 {
+		start = max(z.length+start, 0)
+	}
+	if stop < 0 {
+		stop = z.length + stop
+	}
+	stop = min(stop, z.length-1)
+	if start > stop {
+		return []cache.ScoredMember{}
+	}
+	out := make([]cache.ScoredMember, 0, stop-start+1)
+	for x := z.nodeAt(start); x != nil && len(out) < cap(out); x = x.level[0].next {
+		out = append(out, cache.ScoredMember{Member: x.member, Score: x.score})
+	}
+	return out
+}
+
+// rangeByScore returns the members scored within [lo, hi].
+func (z *sortedSet) rangeByScore(lo, hi float64) []cache.ScoredMember {
+	out := []cache.ScoredMember{}
+	for x := z.firstFrom(lo); x != nil && x.score <= hi; x = x.level[0].next {
+		out = append(out, cache.ScoredMember{Member: x.member, Score: x.score})
+	}
+	return out
+}
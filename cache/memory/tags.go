@@ -0,0 +1,87 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/carlosealves2/go-infrakit/cache"
+)
+
+var _ cache.TagCache = (*Cache)(nil)
+
+// tagLocked attaches tags to a resident entry.
+func (s *shard) tagLocked(e *entry, tags []string) {
+	if len(tags) == 0 {
+		return
+	}
+	if s.tags == nil {
+		s.tags = make(map[string]map[*entry]struct{})
+	}
+	for _, t := range tags {
+		set := s.tags[t]
+		if set == nil {
+			set = make(map[*entry]struct{})
+			s.tags[t] = set
+		}
+		set[e] = struct{}{}
+	}
+	e.tags = append(e.tags[:0], tags...)
+}
+
+// untagLocked drops e from the tag index, releasing empty tags.
+func (s *shard) untagLocked(e *entry) {
+	for _, t := range e.tags {
+		delete(s.tags[t], e)
+		if len(s.tags[t]) == 0 {
+			delete(s.tags, t)
+		}
+	}
+	e.tags = nil
+}
+
+// SetWithTags stores value and indexes key under tags. The index entry is
+// released together with the entry, whether it is deleted, overwritten,
+// evicted or expired.
+func (c *Cache) SetWithTags(ctx context.Context, key string, value []byte, ttl time.Duration, tags ...string) error {
+	key, keyLen := c.formatKey(key)
+	start := time.Now()
+	if err := c.checkCtx(ctx); err != nil {
+		c.observe(ctx, "set", keyLen, false, start, err)
+		return err
+	}
+	sh := c.shardFor(key)
+	sh.mu.Lock()
+	evicted := sh.setLocked(key, append([]byte(nil), value...), ttl)
+	if e, ok := sh.store[key]; ok {
+		sh.tagLocked(e, tags)
+	}
+	sh.mu.Unlock()
+	c.observeEvictions(ctx, evicted)
+	c.observe(ctx, "set", keyLen, false, start, nil, attribute.Int("tags", len(tags)))
+	return nil
+}
+
+// InvalidateTags deletes the entries carrying any of tags, one shard lock
+// at a time.
+func (c *Cache) InvalidateTags(ctx context.Context, tags ...string) error {
+	start := time.Now()
+	if err := c.checkCtx(ctx); err != nil {
+		c.observe(ctx, "invalidate_tags", 0, false, start, err)
+		return err
+	}
+	deleted := 0
+	for _, sh := range c.shards {
+		sh.mu.Lock()
+		for _, t := range tags {
+			for e := range sh.tags[t] {
+				sh.deleteLocked(e.key)
+				deleted++
+			}
+		}
+		sh.mu.Unlock()
+	}
+	c.observe(ctx, "invalidate_tags", 0, deleted > 0, start, nil, attribute.Int("deleted", deleted))
+	return nil
+}
@@ -0,0 +1,230 @@
+package memory
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/carlosealves2/go-infrakit/cache"
+)
+
+var _ cache.SortedSetCache = (*Cache)(nil)
+
+// zsetMemberSize is what a member counts against Options.MaxBytes: the
+// member itself and its score.
+func zsetMemberSize(member string) int64 {
+	return int64(len(member) + 8)
+}
+
+// zsetLocked returns the live sorted set at key like hashLocked does for
+// hashes.
+func (s *shard) zsetLocked(key string, create bool) (*entry, []eviction, error) {
+	e, ok := s.lookupLocked(key)
+	switch {
+	case ok && e.zset == nil:
+		return nil, nil, cache.ErrWrongType
+	case ok || !create:
+		return e, nil, nil
+	}
+	evicted := s.setLocked(key, nil, 0)
+	if e = s.store[key]; e != nil {
+		e.zset = newSortedSet()
+	}
+	return e, evicted, nil
+}
+
+// zremLocked removes members from the set of e, deleting the entry once it
+// is empty.
+func (s *shard) zremLocked(e *entry, members []string) {
+	s.resizeLocked(e, func() {
+		for _, m := range members {
+			if e.zset.remove(m) {
+				e.extra -= zsetMemberSize(m)
+			}
+		}
+	})
+	if e.zset.length == 0 {
+		s.deleteLocked(e.key)
+	}
+}
+
+func (c *Cache) ZAdd(ctx context.Context, key string, members ...cache.ScoredMember) error {
+	if len(members) == 0 {
+		return nil
+	}
+	key, keyLen := c.formatKey(key)
+	start := time.Now()
+	if err := c.checkCtx(ctx); err != nil {
+		c.observe(ctx, "zadd", keyLen, false, start, err)
+		return err
+	}
+	for _, m := range members {
+		if math.IsNaN(m.Score) {
+			c.observe(ctx, "zadd", keyLen, false, start, cache.ErrNotNumber)
+			return cache.ErrNotNumber
+		}
+	}
+	sh := c.shardFor(key)
+	sh.mu.Lock()
+	e, evicted, err := sh.zsetLocked(key, true)
+	if e != nil {
+		evicted = append(evicted, sh.resizeLocked(e, func() {
+			for _, m := range members {
+				if e.zset.add(m.Member, m.Score) {
+					e.extra += zsetMemberSize(m.Member)
+				}
+			}
+		})...)
+	}
+	sh.mu.Unlock()
+	c.observe(ctx, "zadd", keyLen, false, start, err, attribute.Int("members", len(members)))
+	c.observeEvictions(ctx, evicted)
+	return err
+}
+
+func (c *Cache) ZRem(ctx context.Context, key string, members ...string) error {
+	if len(members) == 0 {
+		return nil
+	}
+	key, keyLen := c.formatKey(key)
+	start := time.Now()
+	if err := c.checkCtx(ctx); err != nil {
+		c.observe(ctx, "zrem", keyLen, false, start, err)
+		return err
+	}
+	sh := c.shardFor(key)
+	sh.mu.Lock()
+	e, _, err := sh.zsetLocked(key, false)
+	if e != nil {
+		sh.zremLocked(e, members)
+	}
+	sh.mu.Unlock()
+	c.observe(ctx, "zrem", keyLen, false, start, err)
+	return err
+}
+
+// zread runs read on the sorted set at key, or on nil when it is missing,
+// under the shard read lock.
+func (c *Cache) zread(ctx context.Context, op, key string, read func(z *sortedSet) error) error {
+	key, keyLen := c.formatKey(key)
+	start := time.Now()
+	if err := c.checkCtx(ctx); err != nil {
+		c.observe(ctx, op, keyLen, false, start, err)
+		return err
+	}
+	sh := c.shardFor(key)
+	sh.readLock()
+	e, _, err := sh.zsetLocked(key, false)
+	if err == nil {
+		var z *sortedSet
+		if e != nil {
+			sh.accessedLocked(e)
+			z = e.zset
+		}
+		err = read(z)
+	}
+	sh.readUnlock()
+	c.observe(ctx, op, keyLen, false, start, err)
+	return err
+}
+
+func (c *Cache) ZScore(ctx context.Context, key, member string) (float64, error) {
+	var score float64
+	err := c.zread(ctx, "zscore", key, func(z *sortedSet) error {
+		var ok bool
+		if z != nil {
+			score, ok = z.scores[member]
+		}
+		if !ok {
+			return cache.ErrNotFound
+		}
+		return nil
+	})
+	return score, err
+}
+
+func (c *Cache) ZRank(ctx context.Context, key, member string) (int64, error) {
+	rank := -1
+	err := c.zread(ctx, "zrank", key, func(z *sortedSet) error {
+		if z != nil {
+			rank = z.rank(member)
+		}
+		if rank < 0 {
+			return cache.ErrNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return int64(rank), nil
+}
+
+func (c *Cache) ZCard(ctx context.Context, key string) (int64, error) {
+	var n int
+	err := c.zread(ctx, "zcard", key, func(z *sortedSet) error {
+		if z != nil {
+			n = z.length
+		}
+		return nil
+	})
+	return int64(n), err
+}
+
+func (c *Cache) ZRange(ctx context.Context, key string, start, stop int64) ([]cache.ScoredMember, error) {
+	var out []cache.ScoredMember
+	err := c.zread(ctx, "zrange", key, func(z *sortedSet) error {
+		if z == nil {
+			out = []cache.ScoredMember{}
+			return nil
+		}
+		out = z.rangeByRank(clampRank(start), clampRank(stop))
+		return nil
+	})
+	return out, err
+}
+
+// clampRank fits a rank into an int; ranks beyond the set size behave alike.
+func clampRank(r int64) int {
+	return int(max(min(r, math.MaxInt32), math.MinInt32))
+}
+
+func (c *Cache) ZRangeByScore(ctx context.Context, key string, min, max float64) ([]cache.ScoredMember, error) {
+	var out []cache.ScoredMember
+	err := c.zread(ctx, "zrangebyscore", key, func(z *sortedSet) error {
+		if z == nil {
+			out = []cache.ScoredMember{}
+			return nil
+		}
+		out = z.rangeByScore(min, max)
+		return nil
+	})
+	return out, err
+}
+
+func (c *Cache) ZRemRangeByScore(ctx context.Context, key string, min, max float64) (int64, error) {
+	key, keyLen := c.formatKey(key)
+	start := time.Now()
+	if err := c.checkCtx(ctx); err != nil {
+		c.observe(ctx, "zremrangebyscore", keyLen, false, start, err)
+		return 0, err
+	}
+	sh := c.shardFor(key)
+	sh.mu.Lock()
+	var n int
+	e, _, err := sh.zsetLocked(key, false)
+	if e != nil {
+		matched := e.zset.rangeByScore(min, max)
+		members := make([]string, len(matched))
+		for i, m := range matched {
+			members[i] = m.Member
+		}
+		n = len(members)
+		sh.zremLocked(e, members)
+	}
+	sh.mu.Unlock()
+	c.observe(ctx, "zremrangebyscore", keyLen, false, start, err, attribute.Int("removed", n))
+	return int64(n), err
+}
@@ -2,6 +2,7 @@ package cache
 
 import (
 	"errors"
+	"time"
 
 	"github.com/carlosealves2/go-infrakit/observability/logger"
 	"go.opentelemetry.io/otel/metric"
@@ -14,6 +15,17 @@ type Driver string
 const (
 	MemoryDriver Driver = "memory"
 	RedisDriver  Driver = "redis"
+	// TieredDriver keeps a bounded memory cache in front of Redis.
+	TieredDriver Driver = "tiered"
+)
+
+// EvictionPolicy selects how a bounded memory cache picks entries to drop.
+type EvictionPolicy string
+
+const (
+	EvictLRU EvictionPolicy = "lru"
+	EvictLFU EvictionPolicy = "lfu"
+	EvictARC EvictionPolicy = "arc"
 )
 
 // Options defines configuration for cache instances.
@@ -22,12 +34,76 @@ type Options struct {
 	Driver    Driver
 	Namespace string
 
-	// Redis specific fields
+	// Redis specific fields. The driver speaks RESP3 to servers that
+	// support HELLO and RESP2 otherwise, authenticating with Username and
+	// Password and selecting DB on every connection.
 	Addr     string
 	DB       int
 	Username string
 	Password string
-	TLS      bool
+
+	// Redis TLS. TLS, or any of the fields below, enables it. The server
+	// certificate is verified against the CA bundle, given as a file or as
+	// PEM, or against the system roots without one, for TLSServerName or
+	// else the host dialed. A client certificate and key, as files or as
+	// PEM, are presented when the server asks. TLSMinVersion defaults to
+	// TLS 1.2. Files are read again for new connections once they change
+	// on disk, so rotated certificates are picked up without a restart;
+	// existing connections keep theirs until replaced, see ConnMaxLifetime.
+	TLS           bool
+	TLSCAFile     string
+	TLSCAPEM      []byte
+	TLSCertFile   string
+	TLSKeyFile    string
+	TLSCertPEM    []byte
+	TLSKeyPEM     []byte
+	TLSServerName string
+	TLSMinVersion uint16
+
+	// Redis topologies, which take the place of Addr. With SentinelAddrs
+	// the master monitored as MasterName is looked up through the
+	// sentinels, authenticating with SentinelUsername and
+	// SentinelPassword, and looked up again when it stops accepting
+	// writes or cannot be reached. With ClusterAddrs the slot table is
+	// loaded from those seed nodes; commands are routed by key slot,
+	// follow MOVED and ASK redirections and reload the table after a
+	// failover. Multi-key operations are split per slot, so they are
+	// atomic per slot only. A cluster has no DB.
+	SentinelAddrs    []string
+	MasterName       string
+	SentinelUsername string
+	SentinelPassword string
+	ClusterAddrs     []string
+
+	// Redis connection pool. PoolSize caps the connections in use and
+	// defaults to ten per CPU; operations wait up to PoolTimeout for one
+	// to be returned. MinIdleConns are dialed ahead of demand. Connections
+	// older than ConnMaxLifetime or idle for longer than ConnMaxIdleTime
+	// are replaced. Zero values keep the go-redis defaults: 5 second
+	// dials, 3 second reads and writes, and a 30 minute idle time. -1
+	// disables a timeout.
+	PoolSize        int
+	PoolTimeout     time.Duration
+	MinIdleConns    int
+	DialTimeout     time.Duration
+	ReadTimeout     time.Duration
+	WriteTimeout    time.Duration
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+
+	// Memory specific fields. Zero limits leave the cache unbounded;
+	// MaxBytes counts key and value bytes. Eviction defaults to EvictLRU.
+	// Shards splits the cache into independently locked segments and
+	// defaults to one; limits are divided evenly between shards.
+	MaxEntries int
+	MaxBytes   int64
+	Eviction   EvictionPolicy
+	Shards     int
+
+	// Tiered specific fields. The tiered driver builds its L1 from the
+	// memory fields and its L2 from the Redis fields. L1TTL bounds how long
+	// a value is served from L1 and defaults to 10 seconds.
+	L1TTL time.Duration
 
 	// Observability adapters
 	Logger logger.Logger
@@ -39,4 +115,20 @@ var (
 	ErrNotFound = errors.New("cache: not found")
 	ErrTimeout  = errors.New("cache: timeout")
 	ErrClosed   = errors.New("cache: closed")
+
+	// ErrNotNumber is returned when incrementing a value that does not
+	// hold a number.
+	ErrNotNumber = errors.New("cache: value is not a number")
+
+	// ErrWrongType is returned when an operation targets a key holding
+	// another kind of value, such as a string read as a hash.
+	ErrWrongType = errors.New("cache: wrong type")
+
+	// ErrConditionNotMet is returned when a conditional write is skipped
+	// because its condition did not hold.
+	ErrConditionNotMet = errors.New("cache: condition not met")
+
+	// ErrDecode is returned by Typed when a stored value cannot be decoded.
+	// It wraps the codec error and, unlike ErrNotFound, means the key exists.
+	ErrDecode = errors.New("cache: decode failed")
 )
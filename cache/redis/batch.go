@@ -0,0 +1,103 @@
+package redis
+
+import (
+	"context"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/carlosealves2/go-infrakit/cache"
+)
+
+// MGet reads keys with a single MGET, or one per slot in a cluster.
+// Missing keys and absent markers are left out of the result.
+func (c *Cache) MGet(ctx context.Context, keys ...string) (map[string][]byte, error) {
+	out := make(map[string][]byte, len(keys))
+	if len(keys) == 0 {
+		return out, nil
+	}
+	formatted := make([]string, len(keys))
+	original := make(map[string]string, len(keys))
+	_, keyLen := c.formatKey(keys[0])
+	for i, k := range keys {
+		formatted[i], _ = c.formatKey(k)
+		original[formatted[i]] = k
+	}
+	start := time.Now()
+	type mget struct {
+		keys []string
+		cmd  *goredis.SliceCmd
+	}
+	var gets []mget
+	err := mapError(c.perSlot(ctx, formatted, func(pipe goredis.Cmdable, keys []string) goredis.Cmder {
+		cmd := pipe.MGet(ctx, keys...)
+		gets = append(gets, mget{keys, cmd})
+		return cmd
+	}))
+	if err != nil {
+		c.observe(ctx, "mget", keyLen, false, start, err)
+		return nil, err
+	}
+	for _, g := range gets {
+		for i, v := range g.cmd.Val() {
+			if s, ok := v.(string); ok && s != absentMarker {
+				out[original[g.keys[i]]] = []byte(s)
+			}
+		}
+	}
+	c.observe(ctx, "mget", keyLen, len(out) > 0, start, nil, attribute.Int("hits", len(out)))
+	return out, nil
+}
+
+// MSet writes items with a single MSET, or one per slot in a cluster.
+func (c *Cache) MSet(ctx context.Context, items map[string][]byte) error {
+	if len(items) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(items))
+	values := make(map[string][]byte, len(items))
+	keyLen := 0
+	for k, v := range items {
+		if len(keys) == 0 {
+			keyLen = len(k)
+		}
+		formatted, _ := c.formatKey(k)
+		keys = append(keys, formatted)
+		values[formatted] = v
+	}
+	start := time.Now()
+	err := mapError(c.perSlot(ctx, keys, func(pipe goredis.Cmdable, keys []string) goredis.Cmder {
+		args := make([]interface{}, 0, 2*len(keys))
+		for _, k := range keys {
+			args = append(args, k, values[k])
+		}
+		return pipe.MSet(ctx, args...)
+	}))
+	c.observe(ctx, "mset", keyLen, false, start, err)
+	return err
+}
+
+// MSetWithTTL pipelines one SET PX per item since MSET cannot set a TTL.
+func (c *Cache) MSetWithTTL(ctx context.Context, items map[string][]byte, ttl time.Duration) error {
+	if len(items) == 0 {
+		return nil
+	}
+	keyLen := 0
+	start := time.Now()
+	_, err := c.client.Pipelined(ctx, func(pipe goredis.Pipeliner) error {
+		for k, v := range items {
+			if pipe.Len() == 0 {
+				keyLen = len(k)
+			}
+			formatted, _ := c.formatKey(k)
+			pipe.Set(ctx, formatted, v, ttl)
+		}
+		return nil
+	})
+	err = mapError(err)
+	c.observe(ctx, "mset", keyLen, false, start, err)
+	return err
+}
+
+var _ cache.BatchCache = (*Cache)(nil)
@@ -0,0 +1,101 @@
+package redis
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// slotCount is the number of hash slots a Redis Cluster divides keys into.
+const slotCount = 16384
+
+// crc16Table is the CRC16/XMODEM table keys are hashed to slots with.
+var crc16Table = func() (t [256]uint16) {
+	for i := range t {
+		crc := uint16(i) << 8
+		for range 8 {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+		t[i] = crc
+	}
+	return t
+}()
+
+// keySlot returns the cluster slot of key. A non-empty {hash tag} is
+// hashed instead of the whole key.
+func keySlot(key string) int {
+	if i := strings.IndexByte(key, '{'); i >= 0 {
+		if j := strings.IndexByte(key[i+1:], '}'); j > 0 {
+			key = key[i+1 : i+1+j]
+		}
+	}
+	var crc uint16
+	for i := 0; i < len(key); i++ {
+		crc = crc<<8 ^ crc16Table[byte(crc>>8)^key[i]]
+	}
+	return int(crc) % slotCount
+}
+
+// slotGroups splits keys by cluster slot, keeping their order within each
+// group. Outside a cluster the keys form a single group.
+func (c *Cache) slotGroups(keys []string) [][]string {
+	if c.cluster == nil {
+		return [][]string{keys}
+	}
+	index := make(map[int]int)
+	var groups [][]string
+	for _, k := range keys {
+		slot := keySlot(k)
+		i, ok := index[slot]
+		if !ok {
+			i = len(groups)
+			index[slot] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], k)
+	}
+	return groups
+}
+
+// perSlot issues the command built by cmd for each slot group of keys and
+// returns the first error. Several groups travel together in a pipeline,
+// which sends them to their nodes concurrently.
+func (c *Cache) perSlot(ctx context.Context, keys []string, cmd func(pipe goredis.Cmdable, keys []string) goredis.Cmder) error {
+	groups := c.slotGroups(keys)
+	if len(groups) == 1 {
+		return cmd(c.client, groups[0]).Err()
+	}
+	_, err := c.client.Pipelined(ctx, func(pipe goredis.Pipeliner) error {
+		for _, g := range groups {
+			cmd(pipe, g)
+		}
+		return nil
+	})
+	return err
+}
+
+// scanIterators returns a SCAN iterator per cluster master, since each
+// node only walks its own keys, or a single one outside a cluster.
+func (c *Cache) scanIterators(ctx context.Context, match string) ([]*goredis.ScanIterator, error) {
+	if c.cluster == nil {
+		return []*goredis.ScanIterator{c.client.Scan(ctx, 0, match, scanCount).Iterator()}, nil
+	}
+	var (
+		mu    sync.Mutex
+		iters []*goredis.ScanIterator
+	)
+	err := c.cluster.ForEachMaster(ctx, func(ctx context.Context, node *goredis.Client) error {
+		iter := node.Scan(ctx, 0, match, scanCount).Iterator()
+		mu.Lock()
+		iters = append(iters, iter)
+		mu.Unlock()
+		return nil
+	})
+	return iters, err
+}
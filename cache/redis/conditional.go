@@ -0,0 +1,154 @@
+package redis
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+
+	"github.com/carlosealves2/go-infrakit/cache"
+)
+
+// SetWithOptions maps the conditions to SET NX/XX/KEEPTTL. Compare-and-swap
+// writes WATCH the key and only SET inside MULTI when it still holds the
+// expected value, so a concurrent change aborts the write.
+func (c *Cache) SetWithOptions(ctx context.Context, key string, value []byte, opts cache.SetOptions) error {
+	key, keyLen := c.formatKey(key)
+	start := time.Now()
+	// Redis rejects KEEPTTL together with an expiration.
+	args := goredis.SetArgs{KeepTTL: opts.KeepTTL}
+	if !opts.KeepTTL {
+		args.TTL = opts.TTL
+	}
+	switch opts.Mode {
+	case cache.SetIfAbsent:
+		args.Mode = "NX"
+	case cache.SetIfPresent:
+		args.Mode = "XX"
+	}
+	var err error
+	if opts.IfEquals == nil {
+		err = c.client.SetArgs(ctx, key, value, args).Err()
+	} else {
+		err = c.compareAndSet(ctx, key, value, opts.IfEquals, args)
+	}
+	if err == goredis.Nil || err == goredis.TxFailedErr {
+		err = cache.ErrConditionNotMet
+	}
+	err = mapError(err)
+	c.observe(ctx, "set", keyLen, false, start, err)
+	return err
+}
+
+func (c *Cache) compareAndSet(ctx context.Context, key string, value, expected []byte, args goredis.SetArgs) error {
+	return c.client.Watch(ctx, func(tx *goredis.Tx) error {
+		cur, err := tx.Get(ctx, key).Result()
+		if err != nil {
+			return err
+		}
+		if cur != string(expected) {
+			return cache.ErrConditionNotMet
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe goredis.Pipeliner) error {
+			pipe.SetArgs(ctx, key, value, args)
+			return nil
+		})
+		return err
+	}, key)
+}
+
+// DelIfEquals deletes key when it holds expected, using WATCH so a
+// concurrent rewrite aborts the delete.
+func (c *Cache) DelIfEquals(ctx context.Context, key string, expected []byte) error {
+	key, keyLen := c.formatKey(key)
+	start := time.Now()
+	err := c.delIfValue(ctx, key, string(expected))
+	c.observe(ctx, "del", keyLen, false, start, err)
+	return err
+}
+
+// delIfValue deletes the formatted key while it holds value and returns
+// ErrConditionNotMet otherwise.
+func (c *Cache) delIfValue(ctx context.Context, key, value string) error {
+	err := c.client.Watch(ctx, func(tx *goredis.Tx) error {
+		cur, err := tx.Get(ctx, key).Result()
+		if err != nil {
+			return err
+		}
+		if cur != value {
+			return cache.ErrConditionNotMet
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe goredis.Pipeliner) error {
+			pipe.Del(ctx, key)
+			return nil
+		})
+		return err
+	}, key)
+	if err == goredis.Nil || err == goredis.TxFailedErr {
+		return cache.ErrConditionNotMet
+	}
+	return mapError(err)
+}
+
+// fencedAttempts bounds how often SetIfAbsentFenced retries a transaction
+// aborted by a concurrent write. Reading a lease that just expired deletes
+// it, which aborts the first attempt by itself.
+const fencedAttempts = 3
+
+// SetIfAbsentFenced WATCHes key and fence, reads the counter and, while key
+// is still absent, runs SET PX and INCR in one MULTI, so the stored value
+// carries the count INCR returns.
+func (c *Cache) SetIfAbsentFenced(ctx context.Context, key, fence string, value []byte, ttl time.Duration) (int64, error) {
+	key, keyLen := c.formatKey(key)
+	fence, _ = c.formatKey(fence)
+	start := time.Now()
+	var token int64
+	fenced := func(tx *goredis.Tx) error {
+		if err := tx.Get(ctx, key).Err(); err != goredis.Nil {
+			if err == nil {
+				err = cache.ErrConditionNotMet
+			}
+			return err
+		}
+		cur, err := tx.Get(ctx, fence).Result()
+		var n int64
+		switch {
+		case err == nil:
+			if n, err = strconv.ParseInt(cur, 10, 64); err != nil {
+				return cache.ErrNotNumber
+			}
+		case err != goredis.Nil:
+			return err
+		}
+		held := strconv.AppendInt(append(append([]byte(nil), value...), ':'), n+1, 10)
+		var incr *goredis.IntCmd
+		_, err = tx.TxPipelined(ctx, func(pipe goredis.Pipeliner) error {
+			pipe.Set(ctx, key, held, ttl)
+			incr = pipe.Incr(ctx, fence)
+			return nil
+		})
+		token = incr.Val()
+		return err
+	}
+	var err error
+	for range fencedAttempts {
+		if err = c.client.Watch(ctx, fenced, key, fence); err != goredis.TxFailedErr {
+			break
+		}
+	}
+	if err == goredis.TxFailedErr {
+		err = cache.ErrConditionNotMet
+	}
+	err = mapError(err)
+	c.observe(ctx, "set", keyLen, false, start, err)
+	if err != nil {
+		return 0, err
+	}
+	return token, nil
+}
+
+var (
+	_ cache.ConditionalCache = (*Cache)(nil)
+	_ cache.FencedCache      = (*Cache)(nil)
+)
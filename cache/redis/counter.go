@@ -0,0 +1,70 @@
+package redis
+
+import (
+	"context"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+
+	"github.com/carlosealves2/go-infrakit/cache"
+)
+
+func (c *Cache) Incr(ctx context.Context, key string, opts ...cache.IncrOption) (int64, error) {
+	return c.IncrBy(ctx, key, 1, opts...)
+}
+
+func (c *Cache) Decr(ctx context.Context, key string, opts ...cache.IncrOption) (int64, error) {
+	return c.IncrBy(ctx, key, -1, opts...)
+}
+
+// IncrBy runs INCRBY. With an initial TTL the key is first created with
+// SET 0 PX ttl NX in the same MULTI so only the first write sets the TTL.
+func (c *Cache) IncrBy(ctx context.Context, key string, delta int64, opts ...cache.IncrOption) (int64, error) {
+	key, keyLen := c.formatKey(key)
+	start := time.Now()
+	o := cache.ApplyIncrOptions(opts)
+	var cmd *goredis.IntCmd
+	err := c.withInitialTTL(ctx, key, o.InitialTTL, func(pipe goredis.Cmdable) {
+		cmd = pipe.IncrBy(ctx, key, delta)
+	})
+	if err == nil {
+		err = cmd.Err()
+	}
+	err = mapError(err)
+	c.observe(ctx, "incr", keyLen, false, start, err)
+	return cmd.Val(), err
+}
+
+// IncrByFloat runs INCRBYFLOAT with the same initial TTL handling as IncrBy.
+func (c *Cache) IncrByFloat(ctx context.Context, key string, delta float64, opts ...cache.IncrOption) (float64, error) {
+	key, keyLen := c.formatKey(key)
+	start := time.Now()
+	o := cache.ApplyIncrOptions(opts)
+	var cmd *goredis.FloatCmd
+	err := c.withInitialTTL(ctx, key, o.InitialTTL, func(pipe goredis.Cmdable) {
+		cmd = pipe.IncrByFloat(ctx, key, delta)
+	})
+	if err == nil {
+		err = cmd.Err()
+	}
+	err = mapError(err)
+	c.observe(ctx, "incr", keyLen, false, start, err)
+	return cmd.Val(), err
+}
+
+// withInitialTTL issues the increment queued by incr, preceded by a
+// SET NX that creates key with ttl when ttl is positive.
+func (c *Cache) withInitialTTL(ctx context.Context, key string, ttl time.Duration, incr func(goredis.Cmdable)) error {
+	if ttl <= 0 {
+		incr(c.client)
+		return nil
+	}
+	_, err := c.client.TxPipelined(ctx, func(pipe goredis.Pipeliner) error {
+		pipe.SetNX(ctx, key, 0, ttl)
+		incr(pipe)
+		return nil
+	})
+	return err
+}
+
+var _ cache.CounterCache = (*Cache)(nil)
@@ -0,0 +1,74 @@
+package redis
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/carlosealves2/go-infrakit/cache"
+)
+
+var _ cache.HashCache = (*Cache)(nil)
+
+// HSet writes fields with a single HSET.
+func (c *Cache) HSet(ctx context.Context, key string, fields map[string][]byte) error {
+	if len(fields) == 0 {
+		return nil
+	}
+	key, keyLen := c.formatKey(key)
+	start := time.Now()
+	values := make([]interface{}, 0, 2*len(fields))
+	for f, v := range fields {
+		values = append(values, f, v)
+	}
+	err := mapError(c.client.HSet(ctx, key, values...).Err())
+	c.observe(ctx, "hset", keyLen, false, start, err, attribute.Int("fields", len(fields)))
+	return err
+}
+
+func (c *Cache) HGet(ctx context.Context, key, field string) ([]byte, error) {
+	key, keyLen := c.formatKey(key)
+	start := time.Now()
+	val, err := c.client.HGet(ctx, key, field).Bytes()
+	err = mapError(err)
+	c.observe(ctx, "hget", keyLen, err == nil, start, err)
+	return val, err
+}
+
+func (c *Cache) HGetAll(ctx context.Context, key string) (map[string][]byte, error) {
+	key, keyLen := c.formatKey(key)
+	start := time.Now()
+	vals, err := c.client.HGetAll(ctx, key).Result()
+	err = mapError(err)
+	if err != nil {
+		c.observe(ctx, "hgetall", keyLen, false, start, err)
+		return nil, err
+	}
+	out := make(map[string][]byte, len(vals))
+	for f, v := range vals {
+		out[f] = []byte(v)
+	}
+	c.observe(ctx, "hgetall", keyLen, len(out) > 0, start, nil, attribute.Int("fields", len(out)))
+	return out, nil
+}
+
+func (c *Cache) HDel(ctx context.Context, key string, fields ...string) error {
+	if len(fields) == 0 {
+		return nil
+	}
+	key, keyLen := c.formatKey(key)
+	start := time.Now()
+	err := mapError(c.client.HDel(ctx, key, fields...).Err())
+	c.observe(ctx, "hdel", keyLen, false, start, err)
+	return err
+}
+
+func (c *Cache) HIncrBy(ctx context.Context, key, field string, delta int64) (int64, error) {
+	key, keyLen := c.formatKey(key)
+	start := time.Now()
+	n, err := c.client.HIncrBy(ctx, key, field, delta).Result()
+	err = mapError(err)
+	c.observe(ctx, "hincrby", keyLen, false, start, err)
+	return n, err
+}
@@ -0,0 +1,137 @@
+package redis
+
+import (
+	"context"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/carlosealves2/go-infrakit/cache"
+)
+
+var _ cache.ListCache = (*Cache)(nil)
+
+func (c *Cache) LPush(ctx context.Context, key string, values ...[]byte) error {
+	return c.push(ctx, "lpush", key, values, c.client.LPush)
+}
+
+func (c *Cache) RPush(ctx context.Context, key string, values ...[]byte) error {
+	return c.push(ctx, "rpush", key, values, c.client.RPush)
+}
+
+func (c *Cache) push(ctx context.Context, op, key string, values [][]byte, push func(context.Context, string, ...interface{}) *goredis.IntCmd) error {
+	if len(values) == 0 {
+		return nil
+	}
+	key, keyLen := c.formatKey(key)
+	start := time.Now()
+	args := make([]interface{}, len(values))
+	for i, v := range values {
+		args[i] = v
+	}
+	err := mapError(push(ctx, key, args...).Err())
+	c.observe(ctx, op, keyLen, false, start, err, attribute.Int("values", len(values)))
+	return err
+}
+
+func (c *Cache) LPop(ctx context.Context, key string) ([]byte, error) {
+	return c.pop(ctx, "lpop", key, c.client.LPop)
+}
+
+func (c *Cache) RPop(ctx context.Context, key string) ([]byte, error) {
+	return c.pop(ctx, "rpop", key, c.client.RPop)
+}
+
+func (c *Cache) pop(ctx context.Context, op, key string, pop func(context.Context, string) *goredis.StringCmd) ([]byte, error) {
+	key, keyLen := c.formatKey(key)
+	start := time.Now()
+	val, err := pop(ctx, key).Bytes()
+	err = mapError(err)
+	c.observe(ctx, op, keyLen, err == nil, start, err)
+	return val, err
+}
+
+func (c *Cache) BLPop(ctx context.Context, key string) ([]byte, error) {
+	return c.blockingPop(ctx, "blpop", key, c.client.BLPop)
+}
+
+func (c *Cache) BRPop(ctx context.Context, key string) ([]byte, error) {
+	return c.blockingPop(ctx, "brpop", key, c.client.BRPop)
+}
+
+// blockingPollInterval bounds each server wait of a blocking pop whose ctx
+// has no deadline, so a cancellation is noticed between waits.
+const blockingPollInterval = time.Second
+
+// blockingPop waits on the server until the ctx deadline, or in rounds of
+// blockingPollInterval while ctx is not canceled when it has no deadline.
+func (c *Cache) blockingPop(ctx context.Context, op, key string, pop func(context.Context, time.Duration, ...string) *goredis.StringSliceCmd) ([]byte, error) {
+	key, keyLen := c.formatKey(key)
+	start := time.Now()
+	var reply []string
+	var err error
+	for {
+		timeout, bounded := popTimeout(ctx)
+		reply, err = pop(ctx, timeout, key).Result()
+		if err != goredis.Nil || bounded || ctx.Err() != nil {
+			break
+		}
+	}
+	if err == goredis.Nil {
+		err = cache.ErrTimeout
+	}
+	err = mapError(err)
+	var val []byte
+	if err == nil {
+		val = []byte(reply[1])
+	}
+	c.observe(ctx, op, keyLen, err == nil, start, err)
+	return val, err
+}
+
+// popTimeout returns the server timeout for one wait of a blocking pop and
+// whether ctx has a deadline. Redis counts whole seconds and treats zero as
+// forever, so the time left is rounded up to at least a second and the ctx
+// deadline ends the call first.
+func popTimeout(ctx context.Context) (time.Duration, bool) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return blockingPollInterval, false
+	}
+	left := time.Until(deadline) + time.Second - 1
+	return max(left.Truncate(time.Second), time.Second), true
+}
+
+func (c *Cache) LRange(ctx context.Context, key string, start, stop int64) ([][]byte, error) {
+	key, keyLen := c.formatKey(key)
+	begin := time.Now()
+	vals, err := c.client.LRange(ctx, key, start, stop).Result()
+	err = mapError(err)
+	c.observe(ctx, "lrange", keyLen, false, begin, err)
+	if err != nil {
+		return nil, err
+	}
+	out := make([][]byte, len(vals))
+	for i, v := range vals {
+		out[i] = []byte(v)
+	}
+	return out, nil
+}
+
+func (c *Cache) LTrim(ctx context.Context, key string, start, stop int64) error {
+	key, keyLen := c.formatKey(key)
+	begin := time.Now()
+	err := mapError(c.client.LTrim(ctx, key, start, stop).Err())
+	c.observe(ctx, "ltrim", keyLen, false, begin, err)
+	return err
+}
+
+func (c *Cache) LLen(ctx context.Context, key string) (int64, error) {
+	key, keyLen := c.formatKey(key)
+	start := time.Now()
+	n, err := c.client.LLen(ctx, key).Result()
+	err = mapError(err)
+	c.observe(ctx, "llen", keyLen, false, start, err)
+	return n, err
+}
@@ -0,0 +1,56 @@
+package redis
+
+import (
+	"context"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+
+	"github.com/carlosealves2/go-infrakit/cache"
+)
+
+var _ cache.NegativeCache = (*Cache)(nil)
+
+// absentMarker is the value stored for keys marked absent. Its control bytes
+// keep it apart from empty and textual values.
+const absentMarker = "\x00\xffcache:absent\xff\x00"
+
+// SetAbsent stores the absent marker under key with ttl.
+func (c *Cache) SetAbsent(ctx context.Context, key string, ttl time.Duration) error {
+	key, keyLen := c.formatKey(key)
+	start := time.Now()
+	err := mapError(c.client.Set(ctx, key, absentMarker, ttl).Err())
+	c.observe(ctx, "set_absent", keyLen, false, start, err)
+	return err
+}
+
+// IsAbsent reads one byte more than the marker, so a long value is not
+// transferred to tell it apart.
+func (c *Cache) IsAbsent(ctx context.Context, key string) (bool, error) {
+	key, keyLen := c.formatKey(key)
+	start := time.Now()
+	prefix, err := c.client.GetRange(ctx, key, 0, int64(len(absentMarker))).Result()
+	if err = mapError(err); err == cache.ErrWrongType {
+		// Collections are never markers.
+		err = nil
+	}
+	c.observe(ctx, "is_absent", keyLen, false, start, err)
+	return err == nil && prefix == absentMarker, err
+}
+
+// exists runs EXISTS together with a GETRANGE one byte longer than the
+// marker, so a marker is recognised without reading whole values. The
+// GETRANGE error for non-string keys is ignored as those are never markers.
+func (c *Cache) exists(ctx context.Context, key string) (bool, error) {
+	var n *goredis.IntCmd
+	var prefix *goredis.StringCmd
+	_, _ = c.client.Pipelined(ctx, func(pipe goredis.Pipeliner) error {
+		n = pipe.Exists(ctx, key)
+		prefix = pipe.GetRange(ctx, key, 0, int64(len(absentMarker)))
+		return nil
+	})
+	if err := n.Err(); err != nil {
+		return false, err
+	}
+	return n.Val() == 1 && (prefix.Err() != nil || prefix.Val() != absentMarker), nil
+}
@@ -0,0 +1,52 @@
+package redis
+
+import (
+	"context"
+	"errors"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// Transport carries messages over a Redis pub/sub channel. It satisfies
+// invalidation.Transport.
+type Transport struct {
+	client  goredis.UniversalClient
+	channel string
+}
+
+// Transport returns a pub/sub transport on channel sharing the cache's
+// connection settings. The channel is not namespaced.
+func (c *Cache) Transport(channel string) *Transport {
+	return &Transport{client: c.client, channel: channel}
+}
+
+func (t *Transport) Publish(ctx context.Context, payload []byte) error {
+	return mapError(t.client.Publish(ctx, t.channel, payload).Err())
+}
+
+// Subscribe delivers messages until ctx is done. ready is called each time
+// the subscription is confirmed, including after the client reconnects on
+// its own, since messages may have been lost in between.
+func (t *Transport) Subscribe(ctx context.Context, ready func(), deliver func(payload []byte)) error {
+	ps := t.client.Subscribe(ctx, t.channel)
+	defer ps.Close()
+	ch := ps.ChannelWithSubscriptions()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg, ok := <-ch:
+			if !ok {
+				return errors.New("redis: subscription closed")
+			}
+			switch m := msg.(type) {
+			case *goredis.Subscription:
+				if m.Kind == "subscribe" {
+					ready()
+				}
+			case *goredis.Message:
+				deliver([]byte(m.Payload))
+			}
+		}
+	}
+}
@@ -2,7 +2,9 @@ package redis
 
 import (
 	"context"
-	"crypto/tls"
+	"errors"
+	"net"
+	"strings"
 	"time"
 
 	goredis "github.com/redis/go-redis/v9"
@@ -17,7 +19,10 @@ import (
 
 // Cache is a Redis-backed implementation of cache.Cache.
 type Cache struct {
-	client  *goredis.Client
+	client goredis.UniversalClient
+	// cluster is set for a cluster client, whose multi-key commands are
+	// split per slot.
+	cluster *goredis.ClusterClient
 	ns      string
 	logger  logger.Logger
 	tracer  trace.Tracer
@@ -25,19 +30,42 @@ type Cache struct {
 	latency metric.Float64Histogram
 }
 
-// New creates a new Redis cache.
+// New creates a new Redis cache for a single server, the master of a
+// Sentinel deployment or a cluster. Tagged writes need Redis 7 or later
+// for the NX and GT flags of PEXPIREAT.
 func New(opts cache.Options) (*Cache, error) {
-	rOpts := &goredis.Options{
-		Addr:     opts.Addr,
-		DB:       opts.DB,
-		Username: opts.Username,
-		Password: opts.Password,
+	uOpts := &goredis.UniversalOptions{
+		Addrs:            []string{opts.Addr},
+		DB:               opts.DB,
+		Username:         opts.Username,
+		Password:         opts.Password,
+		SentinelUsername: opts.SentinelUsername,
+		SentinelPassword: opts.SentinelPassword,
+		PoolSize:         opts.PoolSize,
+		PoolTimeout:      opts.PoolTimeout,
+		MinIdleConns:     opts.MinIdleConns,
+		DialTimeout:      opts.DialTimeout,
+		ReadTimeout:      opts.ReadTimeout,
+		WriteTimeout:     opts.WriteTimeout,
+		ConnMaxLifetime:  opts.ConnMaxLifetime,
+		ConnMaxIdleTime:  opts.ConnMaxIdleTime,
 	}
-	if opts.TLS {
-		rOpts.TLSConfig = &tls.Config{}
+	switch {
+	case len(opts.ClusterAddrs) > 0:
+		uOpts.Addrs, uOpts.IsClusterMode = opts.ClusterAddrs, true
+	case len(opts.SentinelAddrs) > 0:
+		uOpts.Addrs, uOpts.MasterName = opts.SentinelAddrs, opts.MasterName
 	}
-	client := goredis.NewClient(rOpts)
+	dialer, err := newTLSDialer(opts)
+	if err != nil {
+		return nil, err
+	}
+	if dialer != nil {
+		uOpts.Dialer = dialer.DialContext
+	}
+	client := goredis.NewUniversalClient(uOpts)
 	if err := client.Ping(context.Background()).Err(); err != nil {
+		client.Close()
 		return nil, err
 	}
 	c := &Cache{
@@ -46,13 +74,51 @@ func New(opts cache.Options) (*Cache, error) {
 		logger: opts.Logger,
 		tracer: opts.Tracer,
 	}
+	c.cluster, _ = client.(*goredis.ClusterClient)
 	if opts.Meter != (metric.Meter{}) {
 		c.counter, _ = opts.Meter.Int64Counter("cache_ops_total")
 		c.latency, _ = opts.Meter.Float64Histogram("cache_latency_ms")
+		c.observePool(opts.Meter)
 	}
 	return c, nil
 }
 
+// poolMetrics lists the connection pool statistics exported as observable
+// instruments. Waits and timeouts are cumulative.
+var poolMetrics = []struct {
+	name    string
+	counter bool
+	read    func(*goredis.PoolStats) int64
+}{
+	{"cache_pool_in_use", false, func(s *goredis.PoolStats) int64 { return int64(s.TotalConns) - int64(s.IdleConns) }},
+	{"cache_pool_idle", false, func(s *goredis.PoolStats) int64 { return int64(s.IdleConns) }},
+	{"cache_pool_waits_total", true, func(s *goredis.PoolStats) int64 { return int64(s.WaitCount) }},
+	{"cache_pool_timeouts_total", true, func(s *goredis.PoolStats) int64 { return int64(s.Timeouts) }},
+}
+
+// observePool registers poolMetrics, read from the client on every
+// collection.
+func (c *Cache) observePool(m metric.Meter) {
+	for _, pm := range poolMetrics {
+		read := pm.read
+		callback := metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+			o.Observe(read(c.client.PoolStats()), metric.WithAttributes(attribute.String("provider", "redis")))
+			return nil
+		})
+		if pm.counter {
+			_, _ = m.Int64ObservableCounter(pm.name, callback)
+		} else {
+			_, _ = m.Int64ObservableGauge(pm.name, callback)
+		}
+	}
+}
+
+// Close releases the connections of the client. Operations on a closed
+// cache fail.
+func (c *Cache) Close() error {
+	return c.client.Close()
+}
+
 func (c *Cache) formatKey(key string) (string, int) {
 	keyLen := len(key)
 	if c.ns != "" {
@@ -65,22 +131,40 @@ func mapError(err error) error {
 	if err == nil {
 		return nil
 	}
-	if err == context.Canceled || err == context.DeadlineExceeded {
+	var netErr net.Error
+	if err == context.Canceled || err == context.DeadlineExceeded ||
+		err == goredis.ErrPoolTimeout || errors.As(err, &netErr) && netErr.Timeout() {
 		return cache.ErrTimeout
 	}
 	if err == goredis.Nil {
 		return cache.ErrNotFound
 	}
+	if err == goredis.ErrClosed {
+		return cache.ErrClosed
+	}
+	msg := err.Error()
+	if strings.HasPrefix(msg, "ERR value is not an integer") ||
+		strings.HasPrefix(msg, "ERR value is not a valid float") ||
+		strings.HasPrefix(msg, "ERR hash value is not an integer") ||
+		strings.HasPrefix(msg, "ERR increment") {
+		return cache.ErrNotNumber
+	}
+	if strings.HasPrefix(msg, "WRONGTYPE") {
+		return cache.ErrWrongType
+	}
 	return err
 }
 
-func (c *Cache) observe(ctx context.Context, op string, keyLen int, hit bool, start time.Time, err error) {
+func (c *Cache) observe(ctx context.Context, op string, keyLen int, hit bool, start time.Time, err error, attrs ...attribute.KeyValue) {
 	dur := time.Since(start)
 	if c.logger != nil {
 		entry := c.logger.Info()
 		if err != nil {
 			entry = c.logger.Error().Err(err)
 		}
+		for _, kv := range attrs {
+			entry = entry.Str(string(kv.Key), kv.Value.Emit())
+		}
 		entry.Str("mod", "cache").
 			Str("provider", "redis").
 			Str("op", op).
@@ -99,20 +183,22 @@ func (c *Cache) observe(ctx context.Context, op string, keyLen int, hit bool, st
 		if op == "get" {
 			span.SetAttributes(attribute.Bool("cache.hit", hit))
 		}
+		span.SetAttributes(attrs...)
 		if err != nil {
 			span.RecordError(err)
 		}
 		span.End()
 	}
 	if c.counter != (metric.Int64Counter{}) {
-		attrs := []attribute.KeyValue{
+		counterAttrs := []attribute.KeyValue{
 			attribute.String("provider", "redis"),
 			attribute.String("op", op),
 		}
 		if op == "get" {
-			attrs = append(attrs, attribute.Bool("hit", hit))
+			counterAttrs = append(counterAttrs, attribute.Bool("hit", hit))
 		}
-		c.counter.Add(ctx, 1, metric.WithAttributes(attrs...))
+		counterAttrs = append(counterAttrs, attrs...)
+		c.counter.Add(ctx, 1, metric.WithAttributes(counterAttrs...))
 	}
 	if c.latency != (metric.Float64Histogram{}) {
 		c.latency.Record(ctx, float64(dur.Milliseconds()), metric.WithAttributes(
@@ -151,6 +237,9 @@ func (c *Cache) Get(ctx context.Context, key string) (string, error) {
 	start := time.Now()
 	val, err := c.client.Get(ctx, key).Result()
 	err = mapError(err)
+	if err == nil && val == absentMarker {
+		val, err = "", cache.ErrNotFound
+	}
 	c.observe(ctx, "get", keyLen, err == nil, start, err)
 	return val, err
 }
@@ -160,10 +249,14 @@ func (c *Cache) GetBytes(ctx context.Context, key string) ([]byte, error) {
 	start := time.Now()
 	val, err := c.client.Get(ctx, key).Bytes()
 	err = mapError(err)
+	if err == nil && string(val) == absentMarker {
+		val, err = nil, cache.ErrNotFound
+	}
 	c.observe(ctx, "get", keyLen, err == nil, start, err)
 	return val, err
 }
 
+// Del deletes keys with a single DEL, or one per slot in a cluster.
 func (c *Cache) Del(ctx context.Context, keys ...string) error {
 	if len(keys) == 0 {
 		return nil
@@ -175,7 +268,9 @@ func (c *Cache) Del(ctx context.Context, keys ...string) error {
 		formatted[i], _ = c.formatKey(keys[i])
 	}
 	start := time.Now()
-	err := mapError(c.client.Del(ctx, formatted...).Err())
+	err := mapError(c.perSlot(ctx, formatted, func(pipe goredis.Cmdable, keys []string) goredis.Cmder {
+		return pipe.Del(ctx, keys...)
+	}))
 	c.observe(ctx, "del", keyLen, false, start, err)
 	return err
 }
@@ -183,10 +278,84 @@ func (c *Cache) Del(ctx context.Context, keys ...string) error {
 func (c *Cache) Exists(ctx context.Context, key string) (bool, error) {
 	key, keyLen := c.formatKey(key)
 	start := time.Now()
-	n, err := c.client.Exists(ctx, key).Result()
+	ok, err := c.exists(ctx, key)
 	err = mapError(err)
 	c.observe(ctx, "exists", keyLen, false, start, err)
-	return n == 1, err
+	return ok, err
+}
+
+// TTL maps the -2 and -1 replies of PTTL to ErrNotFound and
+// cache.NoExpiration.
+func (c *Cache) TTL(ctx context.Context, key string) (time.Duration, error) {
+	key, keyLen := c.formatKey(key)
+	start := time.Now()
+	ttl, err := c.client.PTTL(ctx, key).Result()
+	err = mapError(err)
+	switch {
+	case err != nil:
+		ttl = 0
+	case ttl == -2:
+		ttl, err = 0, cache.ErrNotFound
+	case ttl < 0:
+		ttl = cache.NoExpiration
+	}
+	c.observe(ctx, "ttl", keyLen, err == nil, start, err)
+	return ttl, err
+}
+
+func (c *Cache) Expire(ctx context.Context, key string, ttl time.Duration) error {
+	key, keyLen := c.formatKey(key)
+	start := time.Now()
+	var err error
+	if ttl <= 0 {
+		// PEXPIRE with a non-positive value deletes the key; DEL does the
+		// same and reports whether it existed.
+		var n int64
+		n, err = c.client.Del(ctx, key).Result()
+		if err == nil && n == 0 {
+			err = cache.ErrNotFound
+		}
+	} else {
+		var ok bool
+		ok, err = c.client.PExpire(ctx, key, ttl).Result()
+		if err == nil && !ok {
+			err = cache.ErrNotFound
+		}
+	}
+	err = mapError(err)
+	c.observe(ctx, "expire", keyLen, false, start, err)
+	return err
+}
+
+// Persist runs PERSIST together with EXISTS in one MULTI since PERSIST
+// replies 0 both for missing keys and keys without a TTL.
+func (c *Cache) Persist(ctx context.Context, key string) error {
+	key, keyLen := c.formatKey(key)
+	start := time.Now()
+	var exists *goredis.IntCmd
+	_, err := c.client.TxPipelined(ctx, func(pipe goredis.Pipeliner) error {
+		pipe.Persist(ctx, key)
+		exists = pipe.Exists(ctx, key)
+		return nil
+	})
+	if err == nil && exists.Val() == 0 {
+		err = cache.ErrNotFound
+	}
+	err = mapError(err)
+	c.observe(ctx, "persist", keyLen, false, start, err)
+	return err
+}
+
+func (c *Cache) Touch(ctx context.Context, key string) error {
+	key, keyLen := c.formatKey(key)
+	start := time.Now()
+	n, err := c.client.Touch(ctx, key).Result()
+	if err == nil && n == 0 {
+		err = cache.ErrNotFound
+	}
+	err = mapError(err)
+	c.observe(ctx, "touch", keyLen, false, start, err)
+	return err
 }
 
 var _ cache.Cache = (*Cache)(nil)
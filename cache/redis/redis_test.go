@@ -1,15 +1,75 @@
 package redis
 
 import (
+	"bufio"
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/carlosealves2/go-infrakit/cache"
+	"github.com/carlosealves2/go-infrakit/internal/redistest"
+	"github.com/carlosealves2/go-infrakit/observability/logger"
 )
 
+// stallingServer answers the first n commands on each connection with OK
+// and then stops replying.
+func stallingServer(t *testing.T, n int) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			nc, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer nc.Close()
+				rd := bufio.NewReader(nc)
+				for i := 0; ; i++ {
+					line, err := rd.ReadString('\n')
+					if err != nil {
+						return
+					}
+					args, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+					for range 2 * args {
+						if _, err := rd.ReadString('\n'); err != nil {
+							return
+						}
+					}
+					if i < n {
+						nc.Write([]byte("+OK\r\n"))
+					}
+				}
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
 func newTestCache(t *testing.T) cache.Cache {
-	c, err := New(cache.Options{})
+	c, err := New(cache.Options{Addr: redistest.Serve(t, &redistest.Server{})})
 	if err != nil {
 		t.Fatalf("new redis: %v", err)
 	}
@@ -52,7 +112,7 @@ func TestRedisCacheTTL(t *testing.T) {
 
 func TestRedisNamespace(t *testing.T) {
 	ctx := context.Background()
-	c, err := New(cache.Options{Namespace: "ns"})
+	c, err := New(cache.Options{Addr: redistest.Serve(t, &redistest.Server{}), Namespace: "ns"})
 	if err != nil {
 		t.Fatalf("new: %v", err)
 	}
@@ -67,3 +127,1153 @@ func TestRedisNamespace(t *testing.T) {
 		t.Fatalf("expected namespaced key, got %v %s", err, v)
 	}
 }
+
+func TestRedisBatch(t *testing.T) {
+	ctx := context.Background()
+	c, err := New(cache.Options{Addr: redistest.Serve(t, &redistest.Server{}), Namespace: "ns"})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	if err := c.MSet(ctx, map[string][]byte{"a": []byte("1"), "b": []byte("2")}); err != nil {
+		t.Fatalf("mset: %v", err)
+	}
+	if err := c.MSetWithTTL(ctx, map[string][]byte{"c": []byte("3")}, 20*time.Millisecond); err != nil {
+		t.Fatalf("mset ttl: %v", err)
+	}
+	got, err := c.MGet(ctx, "a", "b", "c", "missing")
+	if err != nil {
+		t.Fatalf("mget: %v", err)
+	}
+	if len(got) != 3 || string(got["a"]) != "1" || string(got["b"]) != "2" || string(got["c"]) != "3" {
+		t.Fatalf("unexpected mget result: %v", got)
+	}
+	if v, err := c.Get(ctx, "a"); err != nil || v != "1" {
+		t.Fatalf("mset must namespace keys: %v %s", err, v)
+	}
+	time.Sleep(30 * time.Millisecond)
+	got, err = c.MGet(ctx, "a", "c")
+	if err != nil {
+		t.Fatalf("mget: %v", err)
+	}
+	if _, ok := got["c"]; ok || len(got) != 1 {
+		t.Fatalf("expected c to expire: %v", got)
+	}
+}
+
+func TestRedisCounters(t *testing.T) {
+	ctx := context.Background()
+	c, err := New(cache.Options{Addr: redistest.Serve(t, &redistest.Server{})})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	if n, err := c.Incr(ctx, "hits"); err != nil || n != 1 {
+		t.Fatalf("incr: %v %d", err, n)
+	}
+	if n, err := c.IncrBy(ctx, "hits", 41); err != nil || n != 42 {
+		t.Fatalf("incrby: %v %d", err, n)
+	}
+	if n, err := c.Decr(ctx, "hits"); err != nil || n != 41 {
+		t.Fatalf("decr: %v %d", err, n)
+	}
+	if v, err := c.Get(ctx, "hits"); err != nil || v != "41" {
+		t.Fatalf("counter should read back as string: %v %s", err, v)
+	}
+	if f, err := c.IncrByFloat(ctx, "ratio", 0.5); err != nil || f != 0.5 {
+		t.Fatalf("incrbyfloat: %v %f", err, f)
+	}
+	if f, err := c.IncrByFloat(ctx, "hits", 0.5); err != nil || f != 41.5 {
+		t.Fatalf("incrbyfloat on integer: %v %f", err, f)
+	}
+	c.Set(ctx, "name", "bob")
+	if _, err := c.Incr(ctx, "name"); err != cache.ErrNotNumber {
+		t.Fatalf("expected ErrNotNumber, got %v", err)
+	}
+	if _, err := c.IncrByFloat(ctx, "name", 1); err != cache.ErrNotNumber {
+		t.Fatalf("expected ErrNotNumber, got %v", err)
+	}
+}
+
+func TestRedisCounterInitialTTL(t *testing.T) {
+	ctx := context.Background()
+	c, err := New(cache.Options{Addr: redistest.Serve(t, &redistest.Server{})})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	if _, err := c.Incr(ctx, "window", cache.WithInitialTTL(40*time.Millisecond)); err != nil {
+		t.Fatalf("incr: %v", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if n, err := c.Incr(ctx, "window", cache.WithInitialTTL(40*time.Millisecond)); err != nil || n != 2 {
+		t.Fatalf("incr: %v %d", err, n)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if _, err := c.Get(ctx, "window"); err != cache.ErrNotFound {
+		t.Fatalf("later increments must not extend the TTL, got %v", err)
+	}
+}
+
+func TestRedisConditionalSet(t *testing.T) {
+	ctx := context.Background()
+	c, err := New(cache.Options{Addr: redistest.Serve(t, &redistest.Server{})})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	nx := cache.SetOptions{Mode: cache.SetIfAbsent, TTL: time.Minute}
+	if err := c.SetWithOptions(ctx, "idem", []byte("first"), nx); err != nil {
+		t.Fatalf("set nx: %v", err)
+	}
+	if err := c.SetWithOptions(ctx, "idem", []byte("second"), nx); err != cache.ErrConditionNotMet {
+		t.Fatalf("expected ErrConditionNotMet, got %v", err)
+	}
+	xx := cache.SetOptions{Mode: cache.SetIfPresent}
+	if err := c.SetWithOptions(ctx, "absent", []byte("v"), xx); err != cache.ErrConditionNotMet {
+		t.Fatalf("expected ErrConditionNotMet, got %v", err)
+	}
+	cas := cache.SetOptions{IfEquals: []byte("first"), KeepTTL: true}
+	if err := c.SetWithOptions(ctx, "idem", []byte("swapped"), cas); err != nil {
+		t.Fatalf("cas: %v", err)
+	}
+	if err := c.SetWithOptions(ctx, "idem", []byte("again"), cas); err != cache.ErrConditionNotMet {
+		t.Fatalf("expected stale cas to fail, got %v", err)
+	}
+	if err := c.SetWithOptions(ctx, "absent", []byte("v"), cas); err != cache.ErrConditionNotMet {
+		t.Fatalf("expected cas on missing key to fail, got %v", err)
+	}
+	if v, err := c.Get(ctx, "idem"); err != nil || v != "swapped" {
+		t.Fatalf("get: %v %s", err, v)
+	}
+	if err := c.SetWithOptions(ctx, "short", []byte("v"), cache.SetOptions{TTL: 20 * time.Millisecond}); err != nil {
+		t.Fatalf("set ttl: %v", err)
+	}
+	if err := c.SetWithOptions(ctx, "short", []byte("w"), cache.SetOptions{KeepTTL: true}); err != nil {
+		t.Fatalf("set keepttl: %v", err)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if _, err := c.Get(ctx, "short"); err != cache.ErrNotFound {
+		t.Fatalf("KeepTTL must keep the deadline, got %v", err)
+	}
+	keep := cache.SetOptions{TTL: time.Minute, KeepTTL: true}
+	if err := c.SetWithOptions(ctx, "fresh", []byte("v"), keep); err != nil {
+		t.Fatalf("set keepttl on missing key: %v", err)
+	}
+	if ttl, err := c.TTL(ctx, "fresh"); err != nil || ttl != cache.NoExpiration {
+		t.Fatalf("KeepTTL must store a missing key without expiration, got %v %v", ttl, err)
+	}
+}
+
+func TestRedisSetIfAbsentFenced(t *testing.T) {
+	ctx := context.Background()
+	_, _, addrs := serveCluster(t, 3)
+	c, err := New(cache.Options{ClusterAddrs: addrs[:1]})
+	if err != nil {
+		t.Fatalf("new redis: %v", err)
+	}
+	defer c.Close()
+	token, err := c.SetIfAbsentFenced(ctx, "lock:{job}", "lock:{job}:fence", []byte("a"), 20*time.Millisecond)
+	if err != nil || token != 1 {
+		t.Fatalf("first fenced set: %d %v", token, err)
+	}
+	if _, err := c.SetIfAbsentFenced(ctx, "lock:{job}", "lock:{job}:fence", []byte("b"), time.Minute); err != cache.ErrConditionNotMet {
+		t.Fatalf("expected ErrConditionNotMet, got %v", err)
+	}
+	if v, err := c.Get(ctx, "lock:{job}"); err != nil || v != "a:1" {
+		t.Fatalf("held value: %q %v", v, err)
+	}
+	time.Sleep(30 * time.Millisecond)
+	token, err = c.SetIfAbsentFenced(ctx, "lock:{job}", "lock:{job}:fence", []byte("b"), time.Minute)
+	if err != nil || token != 2 {
+		t.Fatalf("fenced set after expiry: %d %v", token, err)
+	}
+	if v, err := c.Get(ctx, "lock:{job}:fence"); err != nil || v != "2" {
+		t.Fatalf("a refused write must not draw a token: %q %v", v, err)
+	}
+}
+
+func TestRedisTTLOps(t *testing.T) {
+	ctx := context.Background()
+	c, err := New(cache.Options{Addr: redistest.Serve(t, &redistest.Server{})})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	if _, err := c.TTL(ctx, "missing"); err != cache.ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	for _, err := range []error{c.Expire(ctx, "missing", time.Second), c.Persist(ctx, "missing"), c.Touch(ctx, "missing")} {
+		if err != cache.ErrNotFound {
+			t.Fatalf("expected ErrNotFound, got %v", err)
+		}
+	}
+	c.Set(ctx, "session", "v")
+	if ttl, err := c.TTL(ctx, "session"); err != nil || ttl != cache.NoExpiration {
+		t.Fatalf("expected NoExpiration, got %v %v", err, ttl)
+	}
+	if err := c.Touch(ctx, "session"); err != nil {
+		t.Fatalf("touch: %v", err)
+	}
+	if err := c.Expire(ctx, "session", time.Minute); err != nil {
+		t.Fatalf("expire: %v", err)
+	}
+	if ttl, err := c.TTL(ctx, "session"); err != nil || ttl <= 59*time.Second || ttl > time.Minute {
+		t.Fatalf("unexpected ttl: %v %v", err, ttl)
+	}
+	if err := c.Persist(ctx, "session"); err != nil {
+		t.Fatalf("persist: %v", err)
+	}
+	if err := c.Persist(ctx, "session"); err != nil {
+		t.Fatalf("persist without ttl: %v", err)
+	}
+	if ttl, err := c.TTL(ctx, "session"); err != nil || ttl != cache.NoExpiration {
+		t.Fatalf("expected NoExpiration after persist, got %v %v", err, ttl)
+	}
+	if err := c.Expire(ctx, "session", 20*time.Millisecond); err != nil {
+		t.Fatalf("expire: %v", err)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if _, err := c.Get(ctx, "session"); err != cache.ErrNotFound {
+		t.Fatalf("expected key to expire, got %v", err)
+	}
+	c.Set(ctx, "gone", "v")
+	if err := c.Expire(ctx, "gone", 0); err != nil {
+		t.Fatalf("expire zero: %v", err)
+	}
+	if ok, _ := c.Exists(ctx, "gone"); ok {
+		t.Fatalf("expire with zero ttl must delete the key")
+	}
+}
+
+func TestRedisCacheScanAndFlush(t *testing.T) {
+	ctx := context.Background()
+	c, err := New(cache.Options{Addr: redistest.Serve(t, &redistest.Server{}), Namespace: "a*p"})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	for i := 0; i < 1200; i++ {
+		c.Set(ctx, "user:"+strconv.Itoa(i), "v")
+	}
+	c.Set(ctx, "order:1", "v")
+	// Keys outside the namespace, including one the unescaped glob would match.
+	c.client.Set(ctx, "abp:user:1", "v", 0)
+	c.client.Set(ctx, "other", "v", 0)
+
+	seen := map[string]bool{}
+	it := c.Scan(ctx, "user:*")
+	for it.Next(ctx) {
+		if seen[it.Key()] {
+			t.Fatalf("duplicate key %q", it.Key())
+		}
+		seen[it.Key()] = true
+	}
+	if err := it.Err(); err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	if len(seen) != 1200 || !seen["user:42"] {
+		t.Fatalf("unexpected scan result: %d keys", len(seen))
+	}
+
+	deleted, err := c.FlushNamespace(ctx)
+	if err != nil || deleted != 1201 {
+		t.Fatalf("flush: %v %d", err, deleted)
+	}
+	if n, _ := c.client.Exists(ctx, "abp:user:1", "other").Result(); n != 2 {
+		t.Fatalf("flush must keep keys outside the namespace, kept %d", n)
+	}
+	if ok, _ := c.Exists(ctx, "order:1"); ok {
+		t.Fatalf("expected namespace to be flushed")
+	}
+}
+
+func TestRedisCacheTags(t *testing.T) {
+	ctx := context.Background()
+	log := &recordingLogger{}
+	c, err := New(cache.Options{Addr: redistest.Serve(t, &redistest.Server{}), Namespace: "app", Logger: log})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	c.SetWithTags(ctx, "profile:42", []byte("p"), 0, "user:42")
+	c.SetWithTags(ctx, "orders:42", []byte("o"), time.Minute, "user:42", "orders")
+	c.SetWithTags(ctx, "orders:7", []byte("o"), 0, "user:7", "orders")
+	c.Set(ctx, "plain", "v")
+
+	it := c.Scan(ctx, "*")
+	n := 0
+	for it.Next(ctx) {
+		n++
+	}
+	if n != 4 {
+		t.Fatalf("scan must hide tag index keys, got %d keys", n)
+	}
+
+	if err := c.InvalidateTags(ctx, "user:42"); err != nil {
+		t.Fatalf("invalidate: %v", err)
+	}
+	if got := log.field("invalidate_tags", "deleted"); len(got) != 1 || got[0] != "2" {
+		t.Fatalf("expected only the two member keys to count as deleted, got %v", got)
+	}
+	for key, want := range map[string]bool{"profile:42": false, "orders:42": false, "orders:7": true, "plain": true} {
+		if ok, _ := c.Exists(ctx, key); ok != want {
+			t.Fatalf("%s: exists=%v, want %v", key, ok, want)
+		}
+	}
+	for _, k := range []string{"app:_tag:user:42", "app:_tagttl:user:42"} {
+		if n, _ := c.client.Exists(ctx, k).Result(); n != 0 {
+			t.Fatalf("expected %s to be removed", k)
+		}
+	}
+
+	// The expiring index follows its latest member and prunes expired ones.
+	c.SetWithTags(ctx, "a", []byte("v"), 20*time.Millisecond, "short")
+	c.SetWithTags(ctx, "b", []byte("v"), 40*time.Millisecond, "short")
+	c.SetWithTags(ctx, "c", []byte("v"), 10*time.Millisecond, "short")
+	if ttl, _ := c.client.PTTL(ctx, "app:_tagttl:short").Result(); ttl < 30*time.Millisecond {
+		t.Fatalf("expected index ttl to follow the latest member, got %v", ttl)
+	}
+	time.Sleep(25 * time.Millisecond)
+	c.SetWithTags(ctx, "d", []byte("v"), 40*time.Millisecond, "short")
+	if n, _ := c.client.ZCard(ctx, "app:_tagttl:short").Result(); n != 2 {
+		t.Fatalf("expected expired members to be pruned, got %d", n)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if n, _ := c.client.Exists(ctx, "app:_tagttl:short").Result(); n != 0 {
+		t.Fatalf("expected index to expire with its members")
+	}
+
+	// Tagged writes prune members of the plain index whose keys are gone.
+	c.SetWithTags(ctx, "p1", []byte("v"), 0, "kept")
+	c.SetWithTags(ctx, "p2", []byte("v"), 0, "kept")
+	c.Del(ctx, "p1")
+	c.SetWithTags(ctx, "p3", []byte("v"), 0, "kept")
+	members, _ := c.client.SMembers(ctx, "app:_tag:kept").Result()
+	sort.Strings(members)
+	if len(members) != 2 || members[0] != "app:p2" || members[1] != "app:p3" {
+		t.Fatalf("expected the deleted key to be pruned, got %v", members)
+	}
+}
+
+func TestRedisNegative(t *testing.T) {
+	ctx := context.Background()
+	c, err := New(cache.Options{Addr: redistest.Serve(t, &redistest.Server{}), Namespace: "app"})
+	if err != nil {
+		t.Fatalf("new redis: %v", err)
+	}
+	c.Set(ctx, "empty", "")
+	c.client.SAdd(ctx, "app:set", "m")
+	if err := c.SetAbsent(ctx, "gone", 20*time.Millisecond); err != nil {
+		t.Fatalf("set absent: %v", err)
+	}
+	if v, err := c.Get(ctx, "empty"); err != nil || v != "" {
+		t.Fatalf("empty value must read as a hit: %v %q", err, v)
+	}
+	if _, err := c.GetBytes(ctx, "gone"); err != cache.ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	for key, want := range map[string]bool{"empty": false, "gone": true, "set": false, "missing": false} {
+		if absent, err := c.IsAbsent(ctx, key); err != nil || absent != want {
+			t.Fatalf("%s: absent=%v %v, want %v", key, absent, err, want)
+		}
+	}
+	for key, want := range map[string]bool{"empty": true, "gone": false, "set": true, "missing": false} {
+		if ok, err := c.Exists(ctx, key); err != nil || ok != want {
+			t.Fatalf("%s: exists=%v %v, want %v", key, ok, err, want)
+		}
+	}
+	if got, _ := c.MGet(ctx, "empty", "gone"); len(got) != 1 {
+		t.Fatalf("expected only the empty value from mget, got %v", got)
+	}
+	if _, err := c.Incr(ctx, "gone"); err != cache.ErrNotNumber {
+		t.Fatalf("expected ErrNotNumber, got %v", err)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if _, err := c.Get(ctx, "gone"); err != cache.ErrNotFound {
+		t.Fatalf("expected the marker to expire, got %v", err)
+	}
+}
+
+func TestRedisHash(t *testing.T) {
+	ctx := context.Background()
+	c, err := New(cache.Options{Addr: redistest.Serve(t, &redistest.Server{}), Namespace: "app"})
+	if err != nil {
+		t.Fatalf("new redis: %v", err)
+	}
+	if err := c.HSet(ctx, "user:1", map[string][]byte{"name": []byte("ada"), "bio": nil}); err != nil {
+		t.Fatalf("hset: %v", err)
+	}
+	if n, _ := c.client.HGetAll(ctx, "app:user:1").Result(); len(n) != 2 {
+		t.Fatalf("expected the hash under the namespaced key, got %v", n)
+	}
+	if v, err := c.HGet(ctx, "user:1", "name"); err != nil || string(v) != "ada" {
+		t.Fatalf("hget: %v %q", err, v)
+	}
+	if _, err := c.HGet(ctx, "user:1", "age"); err != cache.ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if n, err := c.HIncrBy(ctx, "user:1", "visits", 3); err != nil || n != 3 {
+		t.Fatalf("hincrby: %v %d", err, n)
+	}
+	if _, err := c.HIncrBy(ctx, "user:1", "name", 1); err != cache.ErrNotNumber {
+		t.Fatalf("expected ErrNotNumber, got %v", err)
+	}
+	if all, err := c.HGetAll(ctx, "user:1"); err != nil || len(all) != 3 || string(all["visits"]) != "3" {
+		t.Fatalf("hgetall: %v %v", err, all)
+	}
+	if _, err := c.Get(ctx, "user:1"); err != cache.ErrWrongType {
+		t.Fatalf("expected ErrWrongType, got %v", err)
+	}
+	c.HDel(ctx, "user:1", "name", "bio", "visits")
+	if ok, _ := c.Exists(ctx, "user:1"); ok {
+		t.Fatalf("expected the empty hash to be deleted")
+	}
+}
+
+func TestRedisSortedSet(t *testing.T) {
+	ctx := context.Background()
+	c, err := New(cache.Options{Addr: redistest.Serve(t, &redistest.Server{}), Namespace: "app"})
+	if err != nil {
+		t.Fatalf("new redis: %v", err)
+	}
+	err = c.ZAdd(ctx, "window",
+		cache.ScoredMember{Member: "r1", Score: 100},
+		cache.ScoredMember{Member: "r2", Score: 200},
+		cache.ScoredMember{Member: "r3", Score: 300},
+	)
+	if err != nil {
+		t.Fatalf("zadd: %v", err)
+	}
+	if n, _ := c.client.ZCard(ctx, "app:window").Result(); n != 3 {
+		t.Fatalf("expected the set under the namespaced key, got %d members", n)
+	}
+	if got, _ := c.ZRange(ctx, "window", 0, 0); len(got) != 1 || got[0] != (cache.ScoredMember{Member: "r1", Score: 100}) {
+		t.Fatalf("unexpected first member %v", got)
+	}
+	if r, err := c.ZRank(ctx, "window", "r3"); err != nil || r != 2 {
+		t.Fatalf("zrank: %v %d", err, r)
+	}
+	if _, err := c.ZScore(ctx, "window", "r9"); err != cache.ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	// Slide the window: drop everything up to 200 and count what is left.
+	if n, err := c.ZRemRangeByScore(ctx, "window", math.Inf(-1), 200); err != nil || n != 2 {
+		t.Fatalf("zremrangebyscore: %v %d", err, n)
+	}
+	if got, _ := c.ZRangeByScore(ctx, "window", 0, math.Inf(1)); len(got) != 1 || got[0].Member != "r3" {
+		t.Fatalf("unexpected members %v", got)
+	}
+	c.Set(ctx, "plain", "v")
+	if err := c.ZAdd(ctx, "plain", cache.ScoredMember{Member: "m"}); err != cache.ErrWrongType {
+		t.Fatalf("expected ErrWrongType, got %v", err)
+	}
+}
+
+func TestRedisList(t *testing.T) {
+	ctx := context.Background()
+	c, err := New(cache.Options{Addr: redistest.Serve(t, &redistest.Server{}), Namespace: "app"})
+	if err != nil {
+		t.Fatalf("new redis: %v", err)
+	}
+	c.RPush(ctx, "recent", []byte("b"), []byte("c"))
+	c.LPush(ctx, "recent", []byte("a"))
+	if n, _ := c.client.LLen(ctx, "app:recent").Result(); n != 3 {
+		t.Fatalf("expected the list under the namespaced key, got %d values", n)
+	}
+	if got, _ := c.LRange(ctx, "recent", 0, -1); len(got) != 3 || string(got[0]) != "a" || string(got[2]) != "c" {
+		t.Fatalf("unexpected values %q", got)
+	}
+	c.LTrim(ctx, "recent", 0, 1)
+	if v, err := c.RPop(ctx, "recent"); err != nil || string(v) != "b" {
+		t.Fatalf("rpop: %v %q", err, v)
+	}
+	c.LPop(ctx, "recent")
+	if _, err := c.LPop(ctx, "recent"); err != cache.ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	got := make(chan string)
+	go func() {
+		v, _ := c.BRPop(ctx, "jobs")
+		got <- string(v)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	c.LPush(ctx, "jobs", []byte("job"))
+	if v := <-got; v != "job" {
+		t.Fatalf("expected the blocked pop to receive the push, got %q", v)
+	}
+	tctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if _, err := c.BLPop(tctx, "jobs"); err != cache.ErrTimeout {
+		t.Fatalf("expected ErrTimeout, got %v", err)
+	}
+}
+
+func TestRedisBlockingPopCancel(t *testing.T) {
+	c, err := New(cache.Options{Addr: redistest.Serve(t, &redistest.Server{})})
+	if err != nil {
+		t.Fatalf("new redis: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	if _, err := c.BLPop(ctx, "jobs"); err != cache.ErrTimeout {
+		t.Fatalf("expected ErrTimeout after cancel, got %v", err)
+	}
+}
+
+func TestPopTimeout(t *testing.T) {
+	if d, bounded := popTimeout(context.Background()); d != blockingPollInterval || bounded {
+		t.Fatalf("expected %v unbounded without a deadline, got %v %v", blockingPollInterval, d, bounded)
+	}
+	for left, want := range map[time.Duration]time.Duration{
+		-time.Second:            time.Second,
+		100 * time.Millisecond:  time.Second,
+		1500 * time.Millisecond: 2 * time.Second,
+		3 * time.Second:         3 * time.Second,
+	} {
+		ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(left))
+		d, bounded := popTimeout(ctx)
+		cancel()
+		// The deadline is a moment closer by the time it is read.
+		if d != want || !bounded {
+			t.Fatalf("%v left: expected %v bounded, got %v %v", left, want, d, bounded)
+		}
+	}
+}
+
+func TestRedisConnectionOptions(t *testing.T) {
+	ctx := context.Background()
+	addr := redistest.Serve(t, &redistest.Server{Username: "app", Password: "secret"})
+	if _, err := New(cache.Options{Addr: addr}); err == nil {
+		t.Fatal("expected an unauthenticated connection to fail")
+	}
+	if _, err := New(cache.Options{Addr: addr, Username: "app", Password: "wrong"}); err == nil {
+		t.Fatal("expected a wrong password to fail")
+	}
+	db0, err := New(cache.Options{Addr: addr, Username: "app", Password: "secret"})
+	if err != nil {
+		t.Fatalf("new redis: %v", err)
+	}
+	db1, err := New(cache.Options{Addr: addr, Username: "app", Password: "secret", DB: 1})
+	if err != nil {
+		t.Fatalf("new redis: %v", err)
+	}
+	db0.Set(ctx, "k", "zero")
+	if _, err := db1.Get(ctx, "k"); err != cache.ErrNotFound {
+		t.Fatalf("expected DB 1 not to see DB 0 keys, got %v", err)
+	}
+	db1.Set(ctx, "k", "one")
+	if v, _ := db0.Get(ctx, "k"); v != "zero" {
+		t.Fatalf("expected DB 0 to keep its value, got %q", v)
+	}
+	if _, err := New(cache.Options{Addr: "127.0.0.1:1"}); err == nil {
+		t.Fatal("expected an unreachable address to fail")
+	}
+	db1.Close()
+	if _, err := db1.Get(ctx, "k"); err != cache.ErrClosed {
+		t.Fatalf("expected ErrClosed, got %v", err)
+	}
+}
+
+// TestRedisRESP2 runs against a server without HELLO, so the driver falls
+// back to RESP2 and AUTH.
+func TestRedisRESP2(t *testing.T) {
+	ctx := context.Background()
+	addr := redistest.Serve(t, &redistest.Server{Password: "secret", DisableHello: true})
+	c, err := New(cache.Options{Addr: addr, Password: "secret", DB: 2})
+	if err != nil {
+		t.Fatalf("new redis: %v", err)
+	}
+	c.HSet(ctx, "h", map[string][]byte{"a": []byte("1"), "b": []byte("2")})
+	if got, err := c.HGetAll(ctx, "h"); err != nil || len(got) != 2 || string(got["b"]) != "2" {
+		t.Fatalf("hgetall: %v %v", err, got)
+	}
+	c.ZAdd(ctx, "z", cache.ScoredMember{Member: "x", Score: 1.5}, cache.ScoredMember{Member: "y", Score: math.Inf(1)})
+	if got, err := c.ZRange(ctx, "z", 0, -1); err != nil || len(got) != 2 || got[0].Score != 1.5 || !math.IsInf(got[1].Score, 1) {
+		t.Fatalf("zrange: %v %v", err, got)
+	}
+	c.Set(ctx, "v", "old")
+	if err := c.SetWithOptions(ctx, "v", []byte("new"), cache.SetOptions{IfEquals: []byte("old")}); err != nil {
+		t.Fatalf("compare and swap: %v", err)
+	}
+	if err := c.SetWithOptions(ctx, "v", []byte("newer"), cache.SetOptions{IfEquals: []byte("old")}); err != cache.ErrConditionNotMet {
+		t.Fatalf("expected ErrConditionNotMet, got %v", err)
+	}
+}
+
+func TestRedisTLS(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := redistest.Serve(t, &redistest.Server{TLSConfig: &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}},
+	}})
+	if _, err := New(cache.Options{Addr: addr}); err == nil {
+		t.Fatal("expected a plaintext connection to a TLS server to fail")
+	}
+	// The self-signed certificate is not among the system roots, so a
+	// failed verification shows the handshake took place.
+	var unknown x509.UnknownAuthorityError
+	if _, err := New(cache.Options{Addr: addr, TLS: true}); !errors.As(err, &unknown) {
+		t.Fatalf("expected the certificate to be verified, got %v", err)
+	}
+}
+
+func TestRedisTransport(t *testing.T) {
+	c, err := New(cache.Options{Addr: redistest.Serve(t, &redistest.Server{})})
+	if err != nil {
+		t.Fatalf("new redis: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tr := c.Transport("events")
+	ready := make(chan struct{}, 1)
+	got := make(chan string, 1)
+	go tr.Subscribe(ctx, func() { ready <- struct{}{} }, func(p []byte) { got <- string(p) })
+	<-ready
+	if err := tr.Publish(ctx, []byte("hello")); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	if v := <-got; v != "hello" {
+		t.Fatalf("expected the published payload, got %q", v)
+	}
+}
+
+// poolMetric reads the instrument named name of poolMetrics.
+func poolMetric(c *Cache, name string) int64 {
+	for _, pm := range poolMetrics {
+		if pm.name == name {
+			return pm.read(c.client.PoolStats())
+		}
+	}
+	return -1
+}
+
+func TestRedisPool(t *testing.T) {
+	ctx := context.Background()
+	addr := redistest.Serve(t, &redistest.Server{})
+	c, err := New(cache.Options{Addr: addr, PoolSize: 1, PoolTimeout: 30 * time.Millisecond, MinIdleConns: 1})
+	if err != nil {
+		t.Fatalf("new redis: %v", err)
+	}
+	// MinIdleConns are dialed in the background.
+	for i := 0; i < 100 && poolMetric(c, "cache_pool_idle") != 1; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	if n := poolMetric(c, "cache_pool_idle"); n != 1 {
+		t.Fatalf("expected one idle connection, got %d", n)
+	}
+
+	// A blocking pop holds the only connection, so other operations time
+	// out waiting for it.
+	popped := make(chan error)
+	go func() {
+		_, err := c.BLPop(ctx, "jobs")
+		popped <- err
+	}()
+	for poolMetric(c, "cache_pool_in_use") != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	if _, err := c.Get(ctx, "k"); err != cache.ErrTimeout {
+		t.Fatalf("expected ErrTimeout from the exhausted pool, got %v", err)
+	}
+	if w, to := poolMetric(c, "cache_pool_waits_total"), poolMetric(c, "cache_pool_timeouts_total"); w != 1 || to != 1 {
+		t.Fatalf("expected one wait and one timeout, got %d and %d", w, to)
+	}
+	other, err := New(cache.Options{Addr: addr})
+	if err != nil {
+		t.Fatalf("new redis: %v", err)
+	}
+	other.LPush(ctx, "jobs", []byte("job"))
+	if err := <-popped; err != nil {
+		t.Fatalf("blpop: %v", err)
+	}
+	if n := poolMetric(c, "cache_pool_in_use"); n != 0 {
+		t.Fatalf("expected the connection to be returned, got %d in use", n)
+	}
+
+	// Connections past their lifetime are replaced.
+	aged, err := New(cache.Options{Addr: addr, ConnMaxLifetime: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("new redis: %v", err)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if _, err := aged.Get(ctx, "k"); err != cache.ErrNotFound {
+		t.Fatalf("get: %v", err)
+	}
+	if s := aged.client.PoolStats(); s.StaleConns != 1 || s.TotalConns != 1 {
+		t.Fatalf("expected the aged connection to be replaced, got %+v", s)
+	}
+}
+
+func TestRedisTimeouts(t *testing.T) {
+	start := time.Now()
+	if _, err := New(cache.Options{Addr: stallingServer(t, 0), DialTimeout: 50 * time.Millisecond}); err == nil {
+		t.Fatal("expected the handshake to time out")
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("expected the dial timeout to bound the handshake, took %v", d)
+	}
+	// HELLO and PING are answered, later commands are not.
+	c, err := New(cache.Options{Addr: stallingServer(t, 2), ReadTimeout: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("new redis: %v", err)
+	}
+	if _, err := c.Get(context.Background(), "k"); err != cache.ErrTimeout {
+		t.Fatalf("expected ErrTimeout, got %v", err)
+	}
+}
+
+// serveCluster starts n nodes of a cluster, splitting the slots evenly
+// between them, and returns their addresses.
+func serveCluster(t *testing.T, n int) (*redistest.Cluster, []*redistest.Server, []string) {
+	t.Helper()
+	cl := &redistest.Cluster{}
+	nodes := make([]*redistest.Server, n)
+	addrs := make([]string, n)
+	for i := range nodes {
+		nodes[i] = &redistest.Server{Cluster: cl}
+		addrs[i] = redistest.Serve(t, nodes[i])
+		cl.Assign(nodes[i], i*slotCount/n, (i+1)*slotCount/n-1)
+	}
+	return cl, nodes, addrs
+}
+
+func TestRedisKeySlot(t *testing.T) {
+	for key, want := range map[string]int{"foo": 12182, "bar": 5061, "123456789": 12739, "{user1000}.following": 3443, "{user1000}.followers": 3443, "foo{}{bar}": 8363} {
+		if got := keySlot(key); got != want {
+			t.Fatalf("keySlot(%q) = %d, want %d", key, got, want)
+		}
+	}
+}
+
+func TestRedisCluster(t *testing.T) {
+	ctx := context.Background()
+	cl, nodes, addrs := serveCluster(t, 3)
+	c, err := New(cache.Options{ClusterAddrs: addrs[:1], Namespace: "app"})
+	if err != nil {
+		t.Fatalf("new redis: %v", err)
+	}
+	defer c.Close()
+
+	// The nodes reject commands whose keys span slots, so multi-key
+	// operations only succeed when split per slot.
+	items := make(map[string][]byte)
+	keys := make([]string, 50)
+	for i := range keys {
+		keys[i] = "k" + strconv.Itoa(i)
+		items[keys[i]] = []byte(keys[i])
+	}
+	if err := c.MSet(ctx, items); err != nil {
+		t.Fatalf("mset: %v", err)
+	}
+	if got, err := c.MGet(ctx, keys...); err != nil || len(got) != 50 || string(got["k7"]) != "k7" {
+		t.Fatalf("mget: %v %d", err, len(got))
+	}
+	n := 0
+	for it := c.Scan(ctx, "k*"); it.Next(ctx); n++ {
+	}
+	if n != 50 {
+		t.Fatalf("scan must walk every master, got %d keys", n)
+	}
+	if err := c.Del(ctx, keys[:25]...); err != nil {
+		t.Fatalf("del: %v", err)
+	}
+	if got, err := c.MGet(ctx, keys...); err != nil || len(got) != 25 {
+		t.Fatalf("mget after del: %v %d", err, len(got))
+	}
+	c.SetWithTags(ctx, "t1", []byte("v"), 0, "group")
+	c.SetWithTags(ctx, "t2", []byte("v"), time.Minute, "group")
+	if err := c.InvalidateTags(ctx, "group"); err != nil {
+		t.Fatalf("invalidate: %v", err)
+	}
+	if got, _ := c.MGet(ctx, "t1", "t2"); len(got) != 0 {
+		t.Fatalf("expected tagged keys to be invalidated, got %v", got)
+	}
+
+	owner := func(key string) int { return keySlot(key) * len(nodes) / slotCount }
+
+	// MOVED: the slot of k30 is reassigned to the next node.
+	slot := keySlot("app:k30")
+	cl.Assign(nodes[(owner("app:k30")+1)%3], slot, slot)
+	if v, err := c.Get(ctx, "k30"); err != nil || v != "k30" {
+		t.Fatalf("get after MOVED: %v %q", err, v)
+	}
+
+	// ASK: the slot of k40 is being migrated. A counter in the same slot
+	// checks transactions too.
+	slot = keySlot("app:k40")
+	target := nodes[(owner("app:k40")+1)%3]
+	cl.Migrate(slot, target)
+	if v, err := c.Get(ctx, "k40"); err != nil || v != "k40" {
+		t.Fatalf("get after ASK: %v %q", err, v)
+	}
+	if err := c.Set(ctx, "k40", "migrating"); err != nil {
+		t.Fatalf("set after ASK: %v", err)
+	}
+	if n, err := c.Incr(ctx, "n{app:k40}", cache.WithInitialTTL(time.Minute)); err != nil || n != 1 {
+		t.Fatalf("incr after ASK: %v %d", err, n)
+	}
+	cl.Assign(target, slot, slot)
+	if v, err := c.Get(ctx, "k40"); err != nil || v != "migrating" {
+		t.Fatalf("get after migration: %v %q", err, v)
+	}
+
+	// Failover: the slots of the seed node move to another node and the
+	// seed goes away.
+	cl.Assign(nodes[1], 0, slotCount/3-1)
+	nodes[0].Close()
+	if got, err := c.MGet(ctx, keys...); err != nil || len(got) != 25 {
+		t.Fatalf("mget after failover: %v %d", err, len(got))
+	}
+	for _, k := range keys[25:] {
+		if owner("app:"+k) == 0 {
+			if err := c.SetWithOptions(ctx, k, []byte("swapped"), cache.SetOptions{IfEquals: []byte(k)}); err != nil {
+				t.Fatalf("compare and swap after failover: %v", err)
+			}
+			break
+		}
+	}
+	deleted, err := c.FlushNamespace(ctx)
+	if err != nil || deleted != 26 {
+		t.Fatalf("flush: %v %d", err, deleted)
+	}
+}
+
+func TestRedisClusterTransport(t *testing.T) {
+	_, _, addrs := serveCluster(t, 3)
+	c, err := New(cache.Options{ClusterAddrs: addrs})
+	if err != nil {
+		t.Fatalf("new redis: %v", err)
+	}
+	defer c.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tr := c.Transport("events")
+	ready := make(chan struct{}, 1)
+	got := make(chan string, 1)
+	go tr.Subscribe(ctx, func() { ready <- struct{}{} }, func(p []byte) { got <- string(p) })
+	<-ready
+	// Publishing from any node reaches subscribers of every node.
+	for range 3 {
+		if err := tr.Publish(ctx, []byte("hello")); err != nil {
+			t.Fatalf("publish: %v", err)
+		}
+		if v := <-got; v != "hello" {
+			t.Fatalf("expected the published payload, got %q", v)
+		}
+	}
+}
+
+func TestRedisSentinel(t *testing.T) {
+	ctx := context.Background()
+	first, second, third := &redistest.Server{}, &redistest.Server{}, &redistest.Server{}
+	redistest.Serve(t, first)
+	redistest.Serve(t, second)
+	redistest.Serve(t, third)
+	sentinel := &redistest.Sentinel{}
+	sentinel.SetMaster("primary", first.Addr())
+	// The first sentinel is down, so the second one is asked.
+	sentinels := []string{stallingServer(t, 0), redistest.Serve(t, &redistest.Server{Sentinel: sentinel, Password: "s3"})}
+	c, err := New(cache.Options{SentinelAddrs: sentinels, MasterName: "primary", SentinelPassword: "s3", DialTimeout: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("new redis: %v", err)
+	}
+	defer c.Close()
+	c.Set(ctx, "k", "1")
+	direct := func(addr string) *Cache {
+		d, err := New(cache.Options{Addr: addr})
+		if err != nil {
+			t.Fatalf("new redis: %v", err)
+		}
+		t.Cleanup(func() { d.Close() })
+		return d
+	}
+	if v, _ := direct(first.Addr()).Get(ctx, "k"); v != "1" {
+		t.Fatalf("expected the write on the first master, got %q", v)
+	}
+
+	// The old master is demoted to a read-only replica.
+	sentinel.SetMaster("primary", second.Addr())
+	first.SetReadOnly(true)
+	if err := c.Set(ctx, "k", "2"); err != nil {
+		t.Fatalf("set after failover: %v", err)
+	}
+	if v, _ := direct(second.Addr()).Get(ctx, "k"); v != "2" {
+		t.Fatalf("expected the write on the new master, got %q", v)
+	}
+
+	// The master goes away.
+	sentinel.SetMaster("primary", third.Addr())
+	second.Close()
+	if err := c.Set(ctx, "k", "3"); err != nil {
+		t.Fatalf("set after failover: %v", err)
+	}
+	if v, _ := direct(third.Addr()).Get(ctx, "k"); v != "3" {
+		t.Fatalf("expected the write on the new master, got %q", v)
+	}
+
+	if _, err := New(cache.Options{SentinelAddrs: sentinels[1:], MasterName: "unknown", SentinelPassword: "s3"}); err == nil {
+		t.Fatalf("expected an error for an unknown master")
+	}
+}
+
+// testCA issues certificates for the TLS tests.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T, name string) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue signs a server certificate for dnsName, or a client certificate
+// when dnsName is empty, and returns it with its PEM certificate and key.
+func (ca *testCA) issue(t *testing.T, dnsName string) (tls.Certificate, []byte, []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	if dnsName != "" {
+		tmpl.DNSNames = []string{dnsName}
+		tmpl.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, certPEM, keyPEM
+}
+
+func TestRedisTLSOptions(t *testing.T) {
+	ctx := context.Background()
+	ca := newTestCA(t, "ca")
+	serverCert, _, _ := ca.issue(t, "redis.test")
+	_, certPEM, keyPEM := ca.issue(t, "")
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca.cert)
+	addr := redistest.Serve(t, &redistest.Server{TLSConfig: &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+	}})
+
+	pemOpts := cache.Options{Addr: addr, TLSCAPEM: ca.pem, TLSCertPEM: certPEM, TLSKeyPEM: keyPEM, TLSServerName: "redis.test"}
+	c, err := New(pemOpts)
+	if err != nil {
+		t.Fatalf("new redis: %v", err)
+	}
+	defer c.Close()
+	if err := c.Set(ctx, "k", "v"); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+
+	dir := t.TempDir()
+	files := map[string][]byte{"ca.pem": ca.pem, "cert.pem": certPEM, "key.pem": keyPEM}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fileOpts := cache.Options{
+		Addr:          addr,
+		TLSCAFile:     filepath.Join(dir, "ca.pem"),
+		TLSCertFile:   filepath.Join(dir, "cert.pem"),
+		TLSKeyFile:    filepath.Join(dir, "key.pem"),
+		TLSServerName: "redis.test",
+	}
+	fc, err := New(fileOpts)
+	if err != nil {
+		t.Fatalf("new redis from files: %v", err)
+	}
+	defer fc.Close()
+	if v, err := fc.Get(ctx, "k"); err != nil || v != "v" {
+		t.Fatalf("get: %v %q", err, v)
+	}
+
+	// The certificate names redis.test, not the address dialed.
+	noSNI := pemOpts
+	noSNI.TLSServerName = ""
+	var hostname x509.HostnameError
+	if _, err := New(noSNI); !errors.As(err, &hostname) {
+		t.Fatalf("expected the host dialed to be verified, got %v", err)
+	}
+	noCert := pemOpts
+	noCert.TLSCertPEM, noCert.TLSKeyPEM = nil, nil
+	if _, err := New(noCert); err == nil {
+		t.Fatal("expected the server to require a client certificate")
+	}
+
+	tls12 := redistest.Serve(t, &redistest.Server{TLSConfig: &tls.Config{Certificates: []tls.Certificate{serverCert}, MaxVersion: tls.VersionTLS12}})
+	minVersion := cache.Options{Addr: tls12, TLSCAPEM: ca.pem, TLSServerName: "redis.test", TLSMinVersion: tls.VersionTLS13}
+	if _, err := New(minVersion); err == nil {
+		t.Fatal("expected TLSMinVersion to reject TLS 1.2")
+	}
+
+	for name, opts := range map[string]cache.Options{
+		"invalid CA":   {Addr: addr, TLSCAPEM: []byte("junk")},
+		"missing CA":   {Addr: addr, TLSCAFile: filepath.Join(dir, "missing.pem")},
+		"both CAs":     {Addr: addr, TLSCAPEM: ca.pem, TLSCAFile: fileOpts.TLSCAFile},
+		"key mismatch": {Addr: addr, TLSCertPEM: certPEM, TLSKeyPEM: ca.pem},
+	} {
+		if _, err := New(opts); err == nil {
+			t.Fatalf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestRedisTLSReload(t *testing.T) {
+	ctx := context.Background()
+	oldCA, newCA := newTestCA(t, "old"), newTestCA(t, "new")
+	serverCerts := make(map[*testCA]tls.Certificate)
+	for _, ca := range []*testCA{oldCA, newCA} {
+		serverCerts[ca], _, _ = ca.issue(t, "redis.test")
+	}
+	// The server presents a certificate of the current CA and accepts
+	// client certificates of that CA only.
+	var current atomic.Pointer[testCA]
+	current.Store(oldCA)
+	addr := redistest.Serve(t, &redistest.Server{TLSConfig: &tls.Config{
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			cert := serverCerts[current.Load()]
+			return &cert, nil
+		},
+		ClientAuth: tls.RequireAnyClientCert,
+		VerifyPeerCertificate: func(raw [][]byte, _ [][]*x509.Certificate) error {
+			cert, err := x509.ParseCertificate(raw[0])
+			if err != nil {
+				return err
+			}
+			return cert.CheckSignatureFrom(current.Load().cert)
+		},
+	}})
+
+	dir := t.TempDir()
+	opts := cache.Options{
+		Addr:          addr,
+		TLSCAFile:     filepath.Join(dir, "ca.pem"),
+		TLSCertFile:   filepath.Join(dir, "cert.pem"),
+		TLSKeyFile:    filepath.Join(dir, "key.pem"),
+		TLSServerName: "redis.test",
+		// Every operation dials, so each one sees the files as they are.
+		ConnMaxLifetime: time.Millisecond,
+	}
+	rotate := func(ca *testCA) {
+		_, certPEM, keyPEM := ca.issue(t, "")
+		for path, data := range map[string][]byte{opts.TLSCAFile: ca.pem, opts.TLSCertFile: certPEM, opts.TLSKeyFile: keyPEM} {
+			if err := os.WriteFile(path, data, 0o600); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	rotate(oldCA)
+	c, err := New(opts)
+	if err != nil {
+		t.Fatalf("new redis: %v", err)
+	}
+	defer c.Close()
+
+	current.Store(newCA)
+	time.Sleep(5 * time.Millisecond)
+	if err := c.Set(ctx, "k", "v"); err == nil {
+		t.Fatal("expected the rotated server certificate to be rejected before the files change")
+	}
+	rotate(newCA)
+	time.Sleep(5 * time.Millisecond)
+	if err := c.Set(ctx, "k", "v"); err != nil {
+		t.Fatalf("expected the rotated files to be picked up, got %v", err)
+	}
+}
+
+// recordingLogger captures the fields of every log entry.
+type recordingLogger struct {
+	mu      sync.Mutex
+	entries []map[string]string
+}
+
+func (l *recordingLogger) Debug() logger.Entry { return l.entry() }
+func (l *recordingLogger) Info() logger.Entry  { return l.entry() }
+func (l *recordingLogger) Error() logger.Entry { return l.entry() }
+
+func (l *recordingLogger) entry() logger.Entry {
+	return &recordingEntry{l: l, fields: make(map[string]string)}
+}
+
+// field returns the value of key in every entry logged for op.
+func (l *recordingLogger) field(op, key string) []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var out []string
+	for _, f := range l.entries {
+		if f["op"] == op {
+			out = append(out, f[key])
+		}
+	}
+	return out
+}
+
+type recordingEntry struct {
+	l      *recordingLogger
+	fields map[string]string
+}
+
+func (e *recordingEntry) Str(key, val string) logger.Entry {
+	e.fields[key] = val
+	return e
+}
+func (e *recordingEntry) Int(key string, val int) logger.Entry           { return e }
+func (e *recordingEntry) Int64(key string, val int64) logger.Entry       { return e }
+func (e *recordingEntry) Float64(key string, val float64) logger.Entry   { return e }
+func (e *recordingEntry) Bool(key string, val bool) logger.Entry         { return e }
+func (e *recordingEntry) Dur(key string, val time.Duration) logger.Entry { return e }
+func (e *recordingEntry) Time(key string, val time.Time) logger.Entry    { return e }
+func (e *recordingEntry) Err(err error) logger.Entry                     { return e }
+func (e *recordingEntry) Msg(msg string) {
+	e.l.mu.Lock()
+	e.l.entries = append(e.l.entries, e.fields)
+	e.l.mu.Unlock()
+}
@@ -0,0 +1,127 @@
+package redis
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/carlosealves2/go-infrakit/cache"
+)
+
+var _ cache.ScanCache = (*Cache)(nil)
+
+// scanCount is the COUNT hint passed to SCAN, which also bounds the size of
+// each UNLINK batch in FlushNamespace.
+const scanCount = 500
+
+// Scan iterates keys matching pattern with SCAN, so large keyspaces are
+// walked incrementally instead of blocking the server like KEYS would. A
+// cluster is walked one master after another. The tag index keys are
+// skipped.
+func (c *Cache) Scan(ctx context.Context, pattern string) cache.KeyIterator {
+	it := &keyIterator{c: c, pattern: pattern, start: time.Now()}
+	it.iters, it.err = c.scanIterators(ctx, c.matchPattern(pattern))
+	return it
+}
+
+// matchPattern scopes pattern to the namespace, escaping glob characters
+// in the namespace itself.
+func (c *Cache) matchPattern(pattern string) string {
+	if c.ns == "" {
+		return pattern
+	}
+	var b strings.Builder
+	for _, r := range c.ns {
+		switch r {
+		case '*', '?', '[', ']', '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String() + ":" + pattern
+}
+
+type keyIterator struct {
+	c       *Cache
+	pattern string
+	start   time.Time
+	iters   []*goredis.ScanIterator
+	key     string
+	found   int
+	err     error
+	done    bool
+}
+
+func (it *keyIterator) Next(ctx context.Context) bool {
+	if it.done {
+		return false
+	}
+	for it.err == nil && len(it.iters) > 0 {
+		iter := it.iters[0]
+		if !iter.Next(ctx) {
+			it.err = iter.Err()
+			it.iters = it.iters[1:]
+			continue
+		}
+		it.key = iter.Val()
+		if it.c.ns != "" {
+			it.key = it.key[len(it.c.ns)+1:]
+		}
+		if isTagKey(it.key) {
+			continue
+		}
+		it.found++
+		return true
+	}
+	it.done, it.key = true, ""
+	it.err = mapError(it.err)
+	it.c.observe(ctx, "scan", len(it.pattern), it.found > 0, it.start, it.err, attribute.Int("keys", it.found))
+	return false
+}
+
+func (it *keyIterator) Key() string { return it.key }
+func (it *keyIterator) Err() error  { return it.err }
+
+// FlushNamespace deletes the namespace with SCAN and batched UNLINK, so the
+// server reclaims memory in the background and is never blocked for long.
+// In a cluster every master is scanned and batches are split per slot.
+// Without a namespace every key in the database is removed.
+func (c *Cache) FlushNamespace(ctx context.Context) (int64, error) {
+	start := time.Now()
+	iters, err := c.scanIterators(ctx, c.matchPattern("*"))
+	var (
+		n     int64
+		batch = make([]string, 0, scanCount)
+	)
+	flush := func() {
+		var cmds []*goredis.IntCmd
+		err = c.perSlot(ctx, batch, func(pipe goredis.Cmdable, keys []string) goredis.Cmder {
+			cmd := pipe.Unlink(ctx, keys...)
+			cmds = append(cmds, cmd)
+			return cmd
+		})
+		for _, cmd := range cmds {
+			n += cmd.Val()
+		}
+		batch = batch[:0]
+	}
+	for _, iter := range iters {
+		for err == nil && iter.Next(ctx) {
+			if batch = append(batch, iter.Val()); len(batch) == scanCount {
+				flush()
+			}
+		}
+		if err == nil {
+			err = iter.Err()
+		}
+	}
+	if err == nil && len(batch) > 0 {
+		flush()
+	}
+	err = mapError(err)
+	c.observe(ctx, "flush", 0, n > 0, start, err, attribute.Int("deleted", int(n)))
+	return n, err
+}
@@ -0,0 +1,193 @@
+package redis
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/carlosealves2/go-infrakit/cache"
+)
+
+var _ cache.TagCache = (*Cache)(nil)
+
+// Tag index keys live in the namespace under reserved prefixes, which Scan
+// hides. Keys without expiration are members of a plain set. Expiring keys
+// are members of a sorted set scored by their deadline: every tagged write
+// prunes members past their deadline and extends the set's own expiration
+// to its latest member, so the index never outlives the keys it tracks.
+// Writes without tags leave the index alone, so members of the plain set
+// can outlive their keys; tagged writes prune them, see pruneTags.
+const (
+	tagPrefix    = "_tag:"
+	tagTTLPrefix = "_tagttl:"
+)
+
+// tagPruneSample is the number of plain set members each tagged write
+// checks for keys that no longer exist.
+const tagPruneSample = 16
+
+func isTagKey(key string) bool {
+	return strings.HasPrefix(key, tagPrefix) || strings.HasPrefix(key, tagTTLPrefix)
+}
+
+func (c *Cache) tagKeys(tag string) (persistent, expiring string) {
+	persistent, _ = c.formatKey(tagPrefix + tag)
+	expiring, _ = c.formatKey(tagTTLPrefix + tag)
+	return persistent, expiring
+}
+
+// SetWithTags writes the value and its tag index entries in one MULTI. In
+// a cluster the index keys live in other slots, so there is one MULTI per
+// slot. It needs Redis 7 or later, see New.
+func (c *Cache) SetWithTags(ctx context.Context, key string, value []byte, ttl time.Duration, tags ...string) error {
+	key, keyLen := c.formatKey(key)
+	start := time.Now()
+	if ttl < 0 {
+		ttl = 0
+	}
+	now := start.UnixMilli()
+	deadline := start.Add(ttl).UnixMilli()
+	samples := make(map[string]*goredis.StringSliceCmd)
+	_, err := c.client.TxPipelined(ctx, func(pipe goredis.Pipeliner) error {
+		pipe.Set(ctx, key, value, ttl)
+		for _, tag := range tags {
+			persistent, expiring := c.tagKeys(tag)
+			if ttl == 0 {
+				pipe.ZRem(ctx, expiring, key)
+				samples[persistent] = pipe.SRandMemberN(ctx, persistent, tagPruneSample)
+				pipe.SAdd(ctx, persistent, key)
+				continue
+			}
+			pipe.SRem(ctx, persistent, key)
+			pipe.ZRemRangeByScore(ctx, expiring, "-inf", "("+strconv.FormatInt(now, 10))
+			pipe.ZAdd(ctx, expiring, goredis.Z{Score: float64(deadline), Member: key})
+			// NX covers a set that was just created, GT an existing one.
+			pipe.Do(ctx, "pexpireat", expiring, deadline, "nx")
+			pipe.Do(ctx, "pexpireat", expiring, deadline, "gt")
+		}
+		return nil
+	})
+	if err == nil && len(samples) > 0 {
+		// The write has succeeded; a failed pruning is left to the next one.
+		c.pruneTags(ctx, samples)
+	}
+	err = mapError(err)
+	c.observe(ctx, "set", keyLen, false, start, err, attribute.Int("tags", len(tags)))
+	return err
+}
+
+// pruneTags removes the sampled members of plain tag sets whose keys no
+// longer exist because they were deleted, expired after a rewrite or
+// evicted by Redis. Members recreated meanwhile are added back, so a
+// tagged write racing with the pruning keeps its tag.
+func (c *Cache) pruneTags(ctx context.Context, samples map[string]*goredis.StringSliceCmd) {
+	exists := make(map[string]*goredis.IntCmd)
+	_, err := c.client.Pipelined(ctx, func(pipe goredis.Pipeliner) error {
+		for _, cmd := range samples {
+			for _, key := range cmd.Val() {
+				if _, ok := exists[key]; !ok {
+					exists[key] = pipe.Exists(ctx, key)
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return
+	}
+	stale := make(map[string][]string)
+	for index, cmd := range samples {
+		for _, key := range cmd.Val() {
+			if exists[key].Val() == 0 {
+				stale[index] = append(stale[index], key)
+			}
+		}
+	}
+	if len(stale) == 0 {
+		return
+	}
+	_, err = c.client.Pipelined(ctx, func(pipe goredis.Pipeliner) error {
+		for index, keys := range stale {
+			members := make([]interface{}, len(keys))
+			for i, key := range keys {
+				members[i] = key
+			}
+			pipe.SRem(ctx, index, members...)
+		}
+		return nil
+	})
+	if err != nil {
+		return
+	}
+	_, err = c.client.Pipelined(ctx, func(pipe goredis.Pipeliner) error {
+		for _, keys := range stale {
+			for _, key := range keys {
+				exists[key] = pipe.Exists(ctx, key)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return
+	}
+	_, _ = c.client.Pipelined(ctx, func(pipe goredis.Pipeliner) error {
+		for index, keys := range stale {
+			for _, key := range keys {
+				if exists[key].Val() == 1 {
+					pipe.SAdd(ctx, index, key)
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// InvalidateTags reads and unlinks the index of every tag in one MULTI,
+// or one per slot in a cluster, then unlinks the members per slot and
+// reports how many of them it removed in the "deleted" attribute. A
+// tagged write racing with the invalidation lands either in the index that
+// was read, so its key is unlinked, or in a fresh index that survives.
+func (c *Cache) InvalidateTags(ctx context.Context, tags ...string) error {
+	start := time.Now()
+	if len(tags) == 0 {
+		return nil
+	}
+	var (
+		members  []*goredis.StringSliceCmd
+		unlinked []*goredis.IntCmd
+	)
+	_, err := c.client.TxPipelined(ctx, func(pipe goredis.Pipeliner) error {
+		for _, tag := range tags {
+			persistent, expiring := c.tagKeys(tag)
+			members = append(members, pipe.SMembers(ctx, persistent))
+			members = append(members, pipe.ZRangeByScore(ctx, expiring, &goredis.ZRangeBy{Min: "-inf", Max: "+inf"}))
+			pipe.Unlink(ctx, persistent)
+			pipe.Unlink(ctx, expiring)
+		}
+		return nil
+	})
+	var keys []string
+	if err == nil {
+		for _, cmd := range members {
+			keys = append(keys, cmd.Val()...)
+		}
+	}
+	if err == nil && len(keys) > 0 {
+		err = c.perSlot(ctx, keys, func(pipe goredis.Cmdable, keys []string) goredis.Cmder {
+			cmd := pipe.Unlink(ctx, keys...)
+			unlinked = append(unlinked, cmd)
+			return cmd
+		})
+	}
+	var deleted int64
+	for _, cmd := range unlinked {
+		deleted += cmd.Val()
+	}
+	err = mapError(err)
+	c.observe(ctx, "invalidate_tags", 0, deleted > 0, start, err, attribute.Int("deleted", int(deleted)))
+	return err
+}
@@ -0,0 +1,179 @@
+package redis
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/carlosealves2/go-infrakit/cache"
+)
+
+// tlsDialer opens TLS connections with a configuration built for every
+// dial, so CA and client certificate files changed on disk since the last
+// dial are read again.
+type tlsDialer struct {
+	base  *tls.Config
+	roots *reloader[*x509.CertPool]
+	cert  *reloader[tls.Certificate]
+}
+
+// newTLSDialer returns the dialer for the TLS options, or nil when TLS is
+// off. Certificates are loaded up front so configuration errors surface
+// in New.
+func newTLSDialer(opts cache.Options) (*tlsDialer, error) {
+	if !opts.TLS && opts.TLSCAFile == "" && len(opts.TLSCAPEM) == 0 &&
+		opts.TLSCertFile == "" && opts.TLSKeyFile == "" && len(opts.TLSCertPEM) == 0 && len(opts.TLSKeyPEM) == 0 &&
+		opts.TLSServerName == "" && opts.TLSMinVersion == 0 {
+		return nil, nil
+	}
+	d := &tlsDialer{base: &tls.Config{ServerName: opts.TLSServerName, MinVersion: opts.TLSMinVersion}}
+	if d.base.MinVersion == 0 {
+		d.base.MinVersion = tls.VersionTLS12
+	}
+
+	switch {
+	case opts.TLSCAFile != "" && len(opts.TLSCAPEM) > 0:
+		return nil, errors.New("redis: set TLSCAFile or TLSCAPEM, not both")
+	case len(opts.TLSCAPEM) > 0:
+		pool, err := parseCAs(opts.TLSCAPEM)
+		if err != nil {
+			return nil, err
+		}
+		d.base.RootCAs = pool
+	case opts.TLSCAFile != "":
+		d.roots = &reloader[*x509.CertPool]{
+			paths: []string{opts.TLSCAFile},
+			parse: func(data [][]byte) (*x509.CertPool, error) { return parseCAs(data[0]) },
+		}
+		if _, err := d.roots.get(); err != nil {
+			return nil, err
+		}
+	}
+
+	fromFiles := opts.TLSCertFile != "" || opts.TLSKeyFile != ""
+	fromPEM := len(opts.TLSCertPEM) > 0 || len(opts.TLSKeyPEM) > 0
+	switch {
+	case fromFiles && fromPEM:
+		return nil, errors.New("redis: set the client certificate as files or as PEM, not both")
+	case fromPEM:
+		cert, err := tls.X509KeyPair(opts.TLSCertPEM, opts.TLSKeyPEM)
+		if err != nil {
+			return nil, fmt.Errorf("redis: client certificate: %w", err)
+		}
+		d.base.Certificates = []tls.Certificate{cert}
+	case fromFiles:
+		d.cert = &reloader[tls.Certificate]{
+			paths: []string{opts.TLSCertFile, opts.TLSKeyFile},
+			parse: func(data [][]byte) (tls.Certificate, error) { return tls.X509KeyPair(data[0], data[1]) },
+		}
+		if _, err := d.cert.get(); err != nil {
+			return nil, fmt.Errorf("redis: client certificate: %w", err)
+		}
+	}
+	return d, nil
+}
+
+func parseCAs(pem []byte) (*x509.CertPool, error) {
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, errors.New("redis: no CA certificates found in PEM")
+	}
+	return pool, nil
+}
+
+// config returns the configuration for a connection to addr, verifying the
+// host dialed unless a server name is set.
+func (d *tlsDialer) config(addr string) (*tls.Config, error) {
+	cfg := d.base.Clone()
+	if cfg.ServerName == "" {
+		cfg.ServerName, _, _ = net.SplitHostPort(addr)
+	}
+	if d.roots != nil {
+		pool, err := d.roots.get()
+		if err != nil {
+			return nil, err
+		}
+		cfg.RootCAs = pool
+	}
+	if d.cert != nil {
+		cert, err := d.cert.get()
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+func (d *tlsDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	cfg, err := d.config(addr)
+	if err != nil {
+		return nil, err
+	}
+	td := &tls.Dialer{NetDialer: &net.Dialer{KeepAlive: 5 * time.Minute}, Config: cfg}
+	return td.DialContext(ctx, network, addr)
+}
+
+// reloader holds a value parsed from files and parses them again when one
+// of them changes on disk, which is checked on every get.
+type reloader[T any] struct {
+	paths []string
+	parse func(data [][]byte) (T, error)
+
+	mu     sync.Mutex
+	stamps []fileStamp
+	val    T
+}
+
+type fileStamp struct {
+	modNs int64
+	size  int64
+}
+
+// get returns the value of the files as they are now. A failed reload,
+// such as while the certificate and key of a rotation are replaced one
+// after the other, keeps the last value and is tried again next time.
+func (r *reloader[T]) get() (T, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stamps := make([]fileStamp, len(r.paths))
+	changed := r.stamps == nil
+	for i, path := range r.paths {
+		fi, err := os.Stat(path)
+		if err != nil {
+			return r.fallback(err)
+		}
+		stamps[i] = fileStamp{fi.ModTime().UnixNano(), fi.Size()}
+		changed = changed || stamps[i] != r.stamps[i]
+	}
+	if !changed {
+		return r.val, nil
+	}
+	data := make([][]byte, len(r.paths))
+	for i, path := range r.paths {
+		var err error
+		if data[i], err = os.ReadFile(path); err != nil {
+			return r.fallback(err)
+		}
+	}
+	val, err := r.parse(data)
+	if err != nil {
+		return r.fallback(err)
+	}
+	r.val, r.stamps = val, stamps
+	return val, nil
+}
+
+func (r *reloader[T]) fallback(err error) (T, error) {
+	if r.stamps != nil {
+		return r.val, nil
+	}
+	var zero T
+	return zero, err
+}
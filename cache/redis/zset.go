@@ -0,0 +1,120 @@
+package redis
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/carlosealves2/go-infrakit/cache"
+)
+
+var _ cache.SortedSetCache = (*Cache)(nil)
+
+// scoreArg formats an inclusive score bound. Infinities render as "+Inf"
+// and "-Inf", which Redis accepts.
+func scoreArg(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func scoredMembers(zs []goredis.Z) []cache.ScoredMember {
+	out := make([]cache.ScoredMember, len(zs))
+	for i, z := range zs {
+		out[i] = cache.ScoredMember{Member: z.Member.(string), Score: z.Score}
+	}
+	return out
+}
+
+func (c *Cache) ZAdd(ctx context.Context, key string, members ...cache.ScoredMember) error {
+	if len(members) == 0 {
+		return nil
+	}
+	key, keyLen := c.formatKey(key)
+	start := time.Now()
+	zs := make([]goredis.Z, len(members))
+	for i, m := range members {
+		zs[i] = goredis.Z{Score: m.Score, Member: m.Member}
+	}
+	err := mapError(c.client.ZAdd(ctx, key, zs...).Err())
+	c.observe(ctx, "zadd", keyLen, false, start, err, attribute.Int("members", len(members)))
+	return err
+}
+
+func (c *Cache) ZRem(ctx context.Context, key string, members ...string) error {
+	if len(members) == 0 {
+		return nil
+	}
+	key, keyLen := c.formatKey(key)
+	start := time.Now()
+	args := make([]interface{}, len(members))
+	for i, m := range members {
+		args[i] = m
+	}
+	err := mapError(c.client.ZRem(ctx, key, args...).Err())
+	c.observe(ctx, "zrem", keyLen, false, start, err)
+	return err
+}
+
+func (c *Cache) ZScore(ctx context.Context, key, member string) (float64, error) {
+	key, keyLen := c.formatKey(key)
+	start := time.Now()
+	score, err := c.client.ZScore(ctx, key, member).Result()
+	err = mapError(err)
+	c.observe(ctx, "zscore", keyLen, false, start, err)
+	return score, err
+}
+
+func (c *Cache) ZRank(ctx context.Context, key, member string) (int64, error) {
+	key, keyLen := c.formatKey(key)
+	start := time.Now()
+	rank, err := c.client.ZRank(ctx, key, member).Result()
+	err = mapError(err)
+	c.observe(ctx, "zrank", keyLen, false, start, err)
+	return rank, err
+}
+
+func (c *Cache) ZCard(ctx context.Context, key string) (int64, error) {
+	key, keyLen := c.formatKey(key)
+	start := time.Now()
+	n, err := c.client.ZCard(ctx, key).Result()
+	err = mapError(err)
+	c.observe(ctx, "zcard", keyLen, false, start, err)
+	return n, err
+}
+
+// ZRange runs ZRANGE with WITHSCORES.
+func (c *Cache) ZRange(ctx context.Context, key string, start, stop int64) ([]cache.ScoredMember, error) {
+	key, keyLen := c.formatKey(key)
+	begin := time.Now()
+	zs, err := c.client.ZRangeWithScores(ctx, key, start, stop).Result()
+	err = mapError(err)
+	c.observe(ctx, "zrange", keyLen, false, begin, err)
+	if err != nil {
+		return nil, err
+	}
+	return scoredMembers(zs), nil
+}
+
+// ZRangeByScore runs ZRANGEBYSCORE with WITHSCORES.
+func (c *Cache) ZRangeByScore(ctx context.Context, key string, min, max float64) ([]cache.ScoredMember, error) {
+	key, keyLen := c.formatKey(key)
+	start := time.Now()
+	zs, err := c.client.ZRangeByScoreWithScores(ctx, key, &goredis.ZRangeBy{Min: scoreArg(min), Max: scoreArg(max)}).Result()
+	err = mapError(err)
+	c.observe(ctx, "zrangebyscore", keyLen, false, start, err)
+	if err != nil {
+		return nil, err
+	}
+	return scoredMembers(zs), nil
+}
+
+func (c *Cache) ZRemRangeByScore(ctx context.Context, key string, min, max float64) (int64, error) {
+	key, keyLen := c.formatKey(key)
+	start := time.Now()
+	n, err := c.client.ZRemRangeByScore(ctx, key, scoreArg(min), scoreArg(max)).Result()
+	err = mapError(err)
+	c.observe(ctx, "zremrangebyscore", keyLen, false, start, err, attribute.Int("removed", int(n)))
+	return n, err
+}
@@ -0,0 +1,55 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// flight is an in-progress load shared by concurrent callers.
+type flight struct {
+	done chan struct{}
+	val  []byte
+	err  error
+}
+
+// group coalesces concurrent calls for the same key into one execution.
+type group struct {
+	mu      sync.Mutex
+	flights map[string]*flight
+}
+
+// do runs fn once for all callers of key that overlap with the first one.
+// Callers stop waiting when their own context is done; fn keeps running for
+// the remaining callers, so it must not depend on the context of any one of
+// them. A panic in fn is returned to every caller as an error.
+func (g *group) do(ctx context.Context, key string, fn func() ([]byte, error)) ([]byte, error) {
+	g.mu.Lock()
+	if g.flights == nil {
+		g.flights = make(map[string]*flight)
+	}
+	f, ok := g.flights[key]
+	if !ok {
+		f = &flight{done: make(chan struct{})}
+		g.flights[key] = f
+		go func() {
+			defer func() {
+				if r := recover(); r != nil {
+					f.val, f.err = nil, fmt.Errorf("cache: loader panicked: %v", r)
+				}
+				g.mu.Lock()
+				delete(g.flights, key)
+				g.mu.Unlock()
+				close(f.done)
+			}()
+			f.val, f.err = fn()
+		}()
+	}
+	g.mu.Unlock()
+	select {
+	case <-f.done:
+		return f.val, f.err
+	case <-ctx.Done():
+		return nil, ErrTimeout
+	}
+}
@@ -0,0 +1,241 @@
+// Package swr adds stale-while-revalidate semantics on top of any cache.Cache.
+//
+// Values are stored with a soft expiry next to them. Until the soft expiry a
+// read is a plain hit; after it the stale value is still served while a
+// registered loader refreshes the entry in the background. The hard TTL of
+// the underlying driver still removes the entry for good.
+package swr
+
+import (
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/carlosealves2/go-infrakit/cache"
+	"github.com/carlosealves2/go-infrakit/observability/logger"
+)
+
+// Loader produces a fresh value for key during a background refresh.
+type Loader func(ctx context.Context, key string) ([]byte, error)
+
+// Options configures a stale-while-revalidate cache.
+type Options struct {
+	// SoftTTL is how long a value is served as fresh. Zero or less leaves
+	// values fresh until the hard TTL removes them.
+	SoftTTL time.Duration
+	// HardTTL is the driver TTL used by Set and SetBytes; zero keeps values
+	// until they are deleted or evicted.
+	HardTTL time.Duration
+	// Loader refreshes stale values. Without it stale values are served
+	// until the hard TTL removes them.
+	Loader Loader
+	// RefreshTimeout bounds a background refresh. It defaults to 10s.
+	RefreshTimeout time.Duration
+
+	Logger logger.Logger
+	Meter  metric.Meter
+}
+
+// Cache wraps a cache.Cache with soft-TTL metadata.
+type Cache struct {
+	next    cache.Cache
+	opts    Options
+	mu      sync.Mutex
+	pending map[string]struct{}
+	counter metric.Int64Counter
+}
+
+// New wraps next with stale-while-revalidate semantics.
+func New(next cache.Cache, opts Options) *Cache {
+	if opts.RefreshTimeout <= 0 {
+		opts.RefreshTimeout = 10 * time.Second
+	}
+	c := &Cache{
+		next:    next,
+		opts:    opts,
+		pending: make(map[string]struct{}),
+	}
+	if opts.Meter != (metric.Meter{}) {
+		c.counter, _ = opts.Meter.Int64Counter("cache_ops_total")
+	}
+	return c
+}
+
+// Refresh results reported with the "result" attribute.
+const (
+	resultOK    = "ok"
+	resultError = "error"
+)
+
+// envelope layout: magic (2 bytes), soft expiry in unix nanoseconds, or
+// zero for none, and the hard TTL in nanoseconds (8 bytes each, big
+// endian), a CRC-32C of the expiries and the value (4 bytes), then the
+// value. The checksum keeps plain values that happen to start with the
+// magic from being read as envelopes.
+var magic = [2]byte{0xC1, 'S'}
+
+const headerLen = 2 + 8 + 8 + 4
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+func checksum(header, value []byte) uint32 {
+	return crc32.Update(crc32.Checksum(header, crcTable), crcTable, value)
+}
+
+func encode(value []byte, softAt time.Time, hardTTL time.Duration) []byte {
+	buf := make([]byte, headerLen+len(value))
+	copy(buf, magic[:])
+	if !softAt.IsZero() {
+		binary.BigEndian.PutUint64(buf[2:], uint64(softAt.UnixNano()))
+	}
+	binary.BigEndian.PutUint64(buf[10:], uint64(hardTTL))
+	copy(buf[headerLen:], value)
+	binary.BigEndian.PutUint32(buf[18:], checksum(buf[2:18], value))
+	return buf
+}
+
+// decode splits an envelope. Values written without one, and envelopes
+// without a soft expiry, are returned as always fresh.
+func decode(raw []byte) (value []byte, softAt time.Time, hardTTL time.Duration) {
+	if len(raw) < headerLen || raw[0] != magic[0] || raw[1] != magic[1] ||
+		binary.BigEndian.Uint32(raw[18:]) != checksum(raw[2:18], raw[headerLen:]) {
+		return raw, time.Time{}, 0
+	}
+	if ns := int64(binary.BigEndian.Uint64(raw[2:])); ns != 0 {
+		softAt = time.Unix(0, ns)
+	}
+	hardTTL = time.Duration(binary.BigEndian.Uint64(raw[10:]))
+	return raw[headerLen:], softAt, hardTTL
+}
+
+// count records op in cache_ops_total. Reads are already counted by the
+// wrapped driver, so the decorator only records the stale values it serves
+// as the "stale" op, and its refreshes.
+func (c *Cache) count(ctx context.Context, op string, attrs ...attribute.KeyValue) {
+	if c.counter != (metric.Int64Counter{}) {
+		c.counter.Add(ctx, 1, metric.WithAttributes(append([]attribute.KeyValue{
+			attribute.String("provider", "swr"),
+			attribute.String("op", op),
+		}, attrs...)...))
+	}
+}
+
+// store writes value with a fresh soft expiry and the given hard TTL.
+func (c *Cache) store(ctx context.Context, key string, value []byte, hardTTL time.Duration) error {
+	var softAt time.Time
+	if c.opts.SoftTTL > 0 {
+		softAt = time.Now().Add(c.opts.SoftTTL)
+	}
+	data := encode(value, softAt, hardTTL)
+	if hardTTL > 0 {
+		return c.next.SetWithTTL(ctx, key, string(data), hardTTL)
+	}
+	return c.next.SetBytes(ctx, key, data)
+}
+
+func (c *Cache) Set(ctx context.Context, key, value string) error {
+	return c.store(ctx, key, []byte(value), c.opts.HardTTL)
+}
+
+func (c *Cache) SetBytes(ctx context.Context, key string, value []byte) error {
+	return c.store(ctx, key, value, c.opts.HardTTL)
+}
+
+// SetWithTTL stores value with ttl as its hard TTL. The soft TTL still comes
+// from Options.
+func (c *Cache) SetWithTTL(ctx context.Context, key, value string, ttl time.Duration) error {
+	return c.store(ctx, key, []byte(value), ttl)
+}
+
+func (c *Cache) Get(ctx context.Context, key string) (string, error) {
+	b, err := c.GetBytes(ctx, key)
+	return string(b), err
+}
+
+// GetBytes returns the value for key. Stale values are returned without an
+// error and trigger a background refresh when a Loader is configured.
+func (c *Cache) GetBytes(ctx context.Context, key string) ([]byte, error) {
+	raw, err := c.next.GetBytes(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	value, softAt, hardTTL := decode(raw)
+	if softAt.IsZero() || time.Now().Before(softAt) {
+		return value, nil
+	}
+	c.count(ctx, "stale")
+	c.refresh(ctx, key, hardTTL)
+	return value, nil
+}
+
+// refresh reloads key in the background unless a refresh is already running.
+func (c *Cache) refresh(ctx context.Context, key string, hardTTL time.Duration) {
+	if c.opts.Loader == nil {
+		return
+	}
+	c.mu.Lock()
+	if _, ok := c.pending[key]; ok {
+		c.mu.Unlock()
+		return
+	}
+	c.pending[key] = struct{}{}
+	c.mu.Unlock()
+
+	go func() {
+		defer func() {
+			c.mu.Lock()
+			delete(c.pending, key)
+			c.mu.Unlock()
+		}()
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), c.opts.RefreshTimeout)
+		defer cancel()
+		value, err := c.opts.Loader(ctx, key)
+		if err == nil {
+			err = c.store(ctx, key, value, hardTTL)
+		}
+		if err != nil {
+			c.count(ctx, "refresh", attribute.String("result", resultError))
+			if c.opts.Logger != nil {
+				c.opts.Logger.Error().Err(err).
+					Str("mod", "cache").
+					Str("provider", "swr").
+					Str("op", "refresh").
+					Int("key_len", len(key)).
+					Msg("")
+			}
+			return
+		}
+		c.count(ctx, "refresh", attribute.String("result", resultOK))
+	}()
+}
+
+func (c *Cache) Del(ctx context.Context, keys ...string) error {
+	return c.next.Del(ctx, keys...)
+}
+
+func (c *Cache) Exists(ctx context.Context, key string) (bool, error) {
+	return c.next.Exists(ctx, key)
+}
+
+func (c *Cache) TTL(ctx context.Context, key string) (time.Duration, error) {
+	return c.next.TTL(ctx, key)
+}
+
+func (c *Cache) Expire(ctx context.Context, key string, ttl time.Duration) error {
+	return c.next.Expire(ctx, key, ttl)
+}
+
+func (c *Cache) Persist(ctx context.Context, key string) error {
+	return c.next.Persist(ctx, key)
+}
+
+func (c *Cache) Touch(ctx context.Context, key string) error {
+	return c.next.Touch(ctx, key)
+}
+
+var _ cache.Cache = (*Cache)(nil)
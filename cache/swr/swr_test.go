@@ -0,0 +1,108 @@
+package swr
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/carlosealves2/go-infrakit/cache"
+	"github.com/carlosealves2/go-infrakit/cache/memory"
+	"github.com/carlosealves2/go-infrakit/cache/redis"
+	"github.com/carlosealves2/go-infrakit/internal/redistest"
+)
+
+func newDrivers(t *testing.T) map[string]cache.Cache {
+	r, err := redis.New(cache.Options{Addr: redistest.Serve(t, &redistest.Server{})})
+	if err != nil {
+		t.Fatalf("new redis: %v", err)
+	}
+	m := memory.New(cache.Options{})
+	t.Cleanup(func() { m.Close() })
+	return map[string]cache.Cache{"memory": m, "redis": r}
+}
+
+func TestStaleWhileRevalidate(t *testing.T) {
+	ctx := context.Background()
+	for name, next := range newDrivers(t) {
+		t.Run(name, func(t *testing.T) {
+			var loads atomic.Int32
+			c := New(next, Options{
+				SoftTTL: 20 * time.Millisecond,
+				Loader: func(ctx context.Context, key string) ([]byte, error) {
+					loads.Add(1)
+					return []byte("fresh"), nil
+				},
+			})
+			if err := c.SetWithTTL(ctx, "foo", "old", time.Minute); err != nil {
+				t.Fatalf("set: %v", err)
+			}
+			if v, err := c.Get(ctx, "foo"); err != nil || v != "old" {
+				t.Fatalf("fresh get: %v %s", err, v)
+			}
+			if loads.Load() != 0 {
+				t.Fatalf("fresh value must not refresh")
+			}
+			time.Sleep(30 * time.Millisecond)
+			if v, err := c.Get(ctx, "foo"); err != nil || v != "old" {
+				t.Fatalf("stale get should serve old value: %v %s", err, v)
+			}
+			deadline := time.Now().Add(time.Second)
+			for {
+				v, err := c.Get(ctx, "foo")
+				if err == nil && v == "fresh" {
+					break
+				}
+				if time.Now().After(deadline) {
+					t.Fatalf("value was not refreshed: %v %s", err, v)
+				}
+				time.Sleep(5 * time.Millisecond)
+			}
+			if n := loads.Load(); n != 1 {
+				t.Fatalf("expected one refresh, got %d", n)
+			}
+		})
+	}
+}
+
+func TestPlainValuesAreFresh(t *testing.T) {
+	ctx := context.Background()
+	m := memory.New(cache.Options{})
+	defer m.Close()
+	m.Set(ctx, "foo", "raw")
+	c := New(m, Options{SoftTTL: time.Nanosecond})
+	if v, err := c.Get(ctx, "foo"); err != nil || v != "raw" {
+		t.Fatalf("get: %v %s", err, v)
+	}
+	if _, err := c.Get(ctx, "missing"); err != cache.ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	// A plain value starting like an envelope is not mistaken for one.
+	lookalike := string(magic[:]) + strings.Repeat("x", 2*headerLen)
+	m.Set(ctx, "lookalike", lookalike)
+	if v, err := c.Get(ctx, "lookalike"); err != nil || v != lookalike {
+		t.Fatalf("get lookalike: %v %q", err, v)
+	}
+}
+
+func TestNoSoftTTL(t *testing.T) {
+	ctx := context.Background()
+	m := memory.New(cache.Options{})
+	defer m.Close()
+	var loads atomic.Int32
+	c := New(m, Options{Loader: func(ctx context.Context, key string) ([]byte, error) {
+		loads.Add(1)
+		return []byte("fresh"), nil
+	}})
+	c.Set(ctx, "foo", "v")
+	time.Sleep(5 * time.Millisecond)
+	if v, err := c.Get(ctx, "foo"); err != nil || v != "v" {
+		t.Fatalf("get: %v %s", err, v)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if n := loads.Load(); n != 0 {
+		t.Fatalf("without a soft TTL values must stay fresh, got %d refreshes", n)
+	}
+}
@@ -0,0 +1,286 @@
+// Package tiered composes a bounded in-process memory cache (L1) over a
+// shared cache such as Redis (L2).
+//
+// Reads are served from L1 when possible and otherwise fall through to L2,
+// populating L1 on a hit. Writes and deletes go to L2 first and then to L1.
+// L1 entries live for at most L1TTL, which bounds how long an instance can
+// serve a value another instance has since changed.
+package tiered
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/carlosealves2/go-infrakit/cache"
+	"github.com/carlosealves2/go-infrakit/cache/memory"
+	redisdrv "github.com/carlosealves2/go-infrakit/cache/redis"
+)
+
+const (
+	defaultL1TTL      = 10 * time.Second
+	defaultMaxEntries = 10000
+)
+
+// Cache is a two-tier cache.
+type Cache struct {
+	l1    *memory.Cache
+	l2    cache.Cache
+	l1TTL time.Duration
+	// ownL2 is the L2 New created, which Close closes.
+	ownL2 io.Closer
+
+	l1Hits, l1Misses atomic.Int64
+	l2Hits, l2Misses atomic.Int64
+	counter          metric.Int64Counter
+}
+
+// Stats reports lookups per tier. L2 only sees the lookups L1 missed.
+type Stats struct {
+	L1Hits, L1Misses int64
+	L2Hits, L2Misses int64
+}
+
+// L1HitRatio is the share of reads served from memory.
+func (s Stats) L1HitRatio() float64 { return ratio(s.L1Hits, s.L1Misses) }
+
+// L2HitRatio is the share of L1 misses served from L2.
+func (s Stats) L2HitRatio() float64 { return ratio(s.L2Hits, s.L2Misses) }
+
+func ratio(hits, misses int64) float64 {
+	if hits+misses == 0 {
+		return 0
+	}
+	return float64(hits) / float64(hits+misses)
+}
+
+// New creates the memory L1 from the memory fields of opts and the Redis
+// L2 from its Redis fields. An unbounded L1 is capped at 10000 entries.
+func New(opts cache.Options) (*Cache, error) {
+	l2, err := redisdrv.New(opts)
+	if err != nil {
+		return nil, err
+	}
+	if opts.MaxEntries == 0 && opts.MaxBytes == 0 {
+		opts.MaxEntries = defaultMaxEntries
+	}
+	c := NewWithTiers(memory.New(opts), l2, opts)
+	c.ownL2 = l2
+	return c, nil
+}
+
+// NewWithTiers composes existing caches. Only L1TTL and Meter are read from
+// opts.
+func NewWithTiers(l1 *memory.Cache, l2 cache.Cache, opts cache.Options) *Cache {
+	if opts.L1TTL <= 0 {
+		opts.L1TTL = defaultL1TTL
+	}
+	c := &Cache{l1: l1, l2: l2, l1TTL: opts.L1TTL}
+	if opts.Meter != (metric.Meter{}) {
+		c.counter, _ = opts.Meter.Int64Counter("cache_ops_total")
+	}
+	return c
+}
+
+// L1 returns the memory tier, for example to evict keys when another
+// instance reports a change.
+func (c *Cache) L1() *memory.Cache { return c.l1 }
+
+// Stats returns the lookups counted since the cache was created.
+func (c *Cache) Stats() Stats {
+	return Stats{
+		L1Hits:   c.l1Hits.Load(),
+		L1Misses: c.l1Misses.Load(),
+		L2Hits:   c.l2Hits.Load(),
+		L2Misses: c.l2Misses.Load(),
+	}
+}
+
+// Close stops the memory tier and closes L2 when New created it. An L2
+// passed to NewWithTiers is left to its owner.
+func (c *Cache) Close() error {
+	err := c.l1.Close()
+	if c.ownL2 != nil {
+		err = errors.Join(err, c.ownL2.Close())
+	}
+	return err
+}
+
+func (c *Cache) record(ctx context.Context, tier string, hit bool) {
+	switch {
+	case tier == "l1" && hit:
+		c.l1Hits.Add(1)
+	case tier == "l1":
+		c.l1Misses.Add(1)
+	case hit:
+		c.l2Hits.Add(1)
+	default:
+		c.l2Misses.Add(1)
+	}
+	if c.counter != (metric.Int64Counter{}) {
+		result := "miss"
+		if hit {
+			result = "hit"
+		}
+		c.counter.Add(ctx, 1, metric.WithAttributes(
+			attribute.String("provider", "tiered"),
+			attribute.String("op", "get"),
+			attribute.String("tier", tier),
+			attribute.String("result", result),
+		))
+	}
+}
+
+// l1TTLFor caps ttl to the L1 lifetime.
+func (c *Cache) l1TTLFor(ttl time.Duration) time.Duration {
+	if ttl > 0 && ttl < c.l1TTL {
+		return ttl
+	}
+	return c.l1TTL
+}
+
+func (c *Cache) Set(ctx context.Context, key, value string) error {
+	if err := c.l2.Set(ctx, key, value); err != nil {
+		c.l1.Del(ctx, key)
+		return err
+	}
+	return c.l1.SetWithTTL(ctx, key, value, c.l1TTL)
+}
+
+func (c *Cache) SetBytes(ctx context.Context, key string, value []byte) error {
+	if err := c.l2.SetBytes(ctx, key, value); err != nil {
+		c.l1.Del(ctx, key)
+		return err
+	}
+	return c.l1.SetWithTTL(ctx, key, string(value), c.l1TTL)
+}
+
+func (c *Cache) SetWithTTL(ctx context.Context, key, value string, ttl time.Duration) error {
+	if err := c.l2.SetWithTTL(ctx, key, value, ttl); err != nil {
+		c.l1.Del(ctx, key)
+		return err
+	}
+	return c.l1.SetWithTTL(ctx, key, value, c.l1TTLFor(ttl))
+}
+
+func (c *Cache) Get(ctx context.Context, key string) (string, error) {
+	b, err := c.GetBytes(ctx, key)
+	return string(b), err
+}
+
+// GetBytes reads from L1 and falls back to L2, copying L2 hits and absent
+// markers into L1. A marker is counted as a miss of the tier that held it;
+// one in L1 spares the L2 read.
+func (c *Cache) GetBytes(ctx context.Context, key string) ([]byte, error) {
+	v, err := c.l1.GetBytes(ctx, key)
+	if err != cache.ErrNotFound {
+		if err == nil {
+			c.record(ctx, "l1", true)
+		}
+		return v, err
+	}
+	c.record(ctx, "l1", false)
+	if absent, _ := c.l1.IsAbsent(ctx, key); absent {
+		return nil, err
+	}
+	v, err = c.l2.GetBytes(ctx, key)
+	if err != nil {
+		if errors.Is(err, cache.ErrNotFound) {
+			c.record(ctx, "l2", false)
+			if neg, ok := c.l2.(cache.NegativeCache); ok {
+				if absent, _ := neg.IsAbsent(ctx, key); absent {
+					_ = c.l1.SetAbsent(ctx, key, c.l1TTL)
+				}
+			}
+		}
+		return nil, err
+	}
+	c.record(ctx, "l2", true)
+	// Failing to populate L1 only costs a later L2 read.
+	_ = c.l1.SetWithTTL(ctx, key, string(v), c.l1TTL)
+	return v, nil
+}
+
+// SetAbsent marks key absent in L2, when it supports markers, and in L1
+// for at most L1TTL.
+func (c *Cache) SetAbsent(ctx context.Context, key string, ttl time.Duration) error {
+	if neg, ok := c.l2.(cache.NegativeCache); ok {
+		if err := neg.SetAbsent(ctx, key, ttl); err != nil {
+			c.l1.Del(ctx, key)
+			return err
+		}
+	}
+	return c.l1.SetAbsent(ctx, key, c.l1TTLFor(ttl))
+}
+
+// IsAbsent reports a marker held by L1 or, failing that, by L2.
+func (c *Cache) IsAbsent(ctx context.Context, key string) (bool, error) {
+	if absent, err := c.l1.IsAbsent(ctx, key); err != nil || absent {
+		return absent, err
+	}
+	if neg, ok := c.l2.(cache.NegativeCache); ok {
+		return neg.IsAbsent(ctx, key)
+	}
+	return false, nil
+}
+
+// Del removes keys from both tiers. L1 is cleared even when L2 fails so
+// this instance does not keep serving the old value.
+func (c *Cache) Del(ctx context.Context, keys ...string) error {
+	err := c.l2.Del(ctx, keys...)
+	if err1 := c.l1.Del(ctx, keys...); err == nil {
+		err = err1
+	}
+	return err
+}
+
+func (c *Cache) Exists(ctx context.Context, key string) (bool, error) {
+	if ok, err := c.l1.Exists(ctx, key); err != nil || ok {
+		return ok, err
+	}
+	return c.l2.Exists(ctx, key)
+}
+
+// TTL reports the L2 deadline, which is authoritative.
+func (c *Cache) TTL(ctx context.Context, key string) (time.Duration, error) {
+	return c.l2.TTL(ctx, key)
+}
+
+func (c *Cache) Expire(ctx context.Context, key string, ttl time.Duration) error {
+	if err := c.l2.Expire(ctx, key, ttl); err != nil {
+		c.l1.Del(ctx, key)
+		return err
+	}
+	if ttl <= 0 {
+		return c.l1.Del(ctx, key)
+	}
+	if err := c.l1.Expire(ctx, key, c.l1TTLFor(ttl)); err != cache.ErrNotFound {
+		return err
+	}
+	return nil
+}
+
+// Persist removes the L2 deadline. L1 keeps its own short lifetime.
+func (c *Cache) Persist(ctx context.Context, key string) error {
+	return c.l2.Persist(ctx, key)
+}
+
+func (c *Cache) Touch(ctx context.Context, key string) error {
+	if err := c.l2.Touch(ctx, key); err != nil {
+		return err
+	}
+	if err := c.l1.Touch(ctx, key); err != cache.ErrNotFound {
+		return err
+	}
+	return nil
+}
+
+var (
+	_ cache.Cache         = (*Cache)(nil)
+	_ cache.NegativeCache = (*Cache)(nil)
+)
@@ -0,0 +1,141 @@
+package tiered
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/carlosealves2/go-infrakit/cache"
+	"github.com/carlosealves2/go-infrakit/cache/memory"
+	redisdrv "github.com/carlosealves2/go-infrakit/cache/redis"
+	"github.com/carlosealves2/go-infrakit/internal/redistest"
+)
+
+func newTestCache(t *testing.T, l1TTL time.Duration) (*Cache, cache.Cache) {
+	l2, err := redisdrv.New(cache.Options{Addr: redistest.Serve(t, &redistest.Server{})})
+	if err != nil {
+		t.Fatalf("new redis: %v", err)
+	}
+	c := NewWithTiers(memory.New(cache.Options{MaxEntries: 100}), l2, cache.Options{L1TTL: l1TTL})
+	t.Cleanup(func() { c.Close() })
+	return c, l2
+}
+
+func TestTieredReadThrough(t *testing.T) {
+	ctx := context.Background()
+	c, l2 := newTestCache(t, 20*time.Millisecond)
+	l2.Set(ctx, "foo", "bar")
+
+	if v, err := c.Get(ctx, "foo"); err != nil || v != "bar" {
+		t.Fatalf("get: %v %s", err, v)
+	}
+	if v, err := c.L1().Get(ctx, "foo"); err != nil || v != "bar" {
+		t.Fatalf("expected L2 hit to populate L1: %v %s", err, v)
+	}
+	if ttl, _ := c.L1().TTL(ctx, "foo"); ttl <= 0 || ttl > 20*time.Millisecond {
+		t.Fatalf("expected L1 ttl to be bounded, got %v", ttl)
+	}
+	c.Get(ctx, "foo")
+	if _, err := c.Get(ctx, "missing"); err != cache.ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	want := Stats{L1Hits: 1, L1Misses: 2, L2Hits: 1, L2Misses: 1}
+	if s := c.Stats(); s != want {
+		t.Fatalf("unexpected stats %+v", s)
+	}
+	if r := c.Stats().L1HitRatio(); r < 0.33 || r > 0.34 {
+		t.Fatalf("unexpected L1 hit ratio %v", r)
+	}
+
+	// Once L1 expires, reads fall through again.
+	l2.Set(ctx, "foo", "changed")
+	time.Sleep(30 * time.Millisecond)
+	if v, _ := c.Get(ctx, "foo"); v != "changed" {
+		t.Fatalf("expected L1 to expire, got %s", v)
+	}
+}
+
+func TestTieredWrites(t *testing.T) {
+	ctx := context.Background()
+	c, l2 := newTestCache(t, time.Minute)
+	if err := c.SetWithTTL(ctx, "foo", "bar", 50*time.Millisecond); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	for name, tier := range map[string]cache.Cache{"l1": c.L1(), "l2": l2} {
+		if v, err := tier.Get(ctx, "foo"); err != nil || v != "bar" {
+			t.Fatalf("%s: %v %s", name, err, v)
+		}
+		if ttl, _ := tier.TTL(ctx, "foo"); ttl <= 0 || ttl > 50*time.Millisecond {
+			t.Fatalf("%s: expected ttl from SetWithTTL, got %v", name, ttl)
+		}
+	}
+
+	c.SetBytes(ctx, "a", []byte("1"))
+	if err := c.Del(ctx, "a", "foo"); err != nil {
+		t.Fatalf("del: %v", err)
+	}
+	for name, tier := range map[string]cache.Cache{"l1": c.L1(), "l2": l2} {
+		if ok, _ := tier.Exists(ctx, "a"); ok {
+			t.Fatalf("%s: expected delete to reach every tier", name)
+		}
+	}
+
+	c.Set(ctx, "b", "1")
+	if err := c.Expire(ctx, "b", 0); err != nil {
+		t.Fatalf("expire: %v", err)
+	}
+	if ok, _ := c.L1().Exists(ctx, "b"); ok {
+		t.Fatalf("expire with zero ttl must clear L1")
+	}
+	if err := c.Touch(ctx, "b"); err != cache.ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestTieredNegative(t *testing.T) {
+	ctx := context.Background()
+	c, l2 := newTestCache(t, time.Minute)
+	if err := c.SetAbsent(ctx, "gone", 50*time.Millisecond); err != nil {
+		t.Fatalf("set absent: %v", err)
+	}
+	for name, tier := range map[string]cache.Cache{"l1": c.L1(), "l2": l2} {
+		if _, err := tier.Get(ctx, "gone"); err != cache.ErrNotFound {
+			t.Fatalf("%s: expected ErrNotFound, got %v", name, err)
+		}
+		if absent, err := tier.(cache.NegativeCache).IsAbsent(ctx, "gone"); err != nil || !absent {
+			t.Fatalf("%s: expected a marker, got %v %v", name, absent, err)
+		}
+	}
+
+	// A marker found in L2 is copied into L1.
+	l2.(cache.NegativeCache).SetAbsent(ctx, "other", time.Minute)
+	if _, err := c.Get(ctx, "other"); err != cache.ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if absent, _ := c.L1().IsAbsent(ctx, "other"); !absent {
+		t.Fatalf("expected L1 to hold the marker")
+	}
+	if s := c.Stats(); s.L1Hits != 0 || s.L2Misses != 1 {
+		t.Fatalf("markers must count as misses, got %+v", s)
+	}
+}
+
+func TestTieredClose(t *testing.T) {
+	ctx := context.Background()
+	c, l2 := newTestCache(t, time.Minute)
+	c.Close()
+	if err := l2.Set(ctx, "foo", "bar"); err != nil {
+		t.Fatalf("an L2 passed in must stay open: %v", err)
+	}
+
+	owned, err := New(cache.Options{Addr: redistest.Serve(t, &redistest.Server{})})
+	if err != nil {
+		t.Fatalf("new tiered: %v", err)
+	}
+	if err := owned.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if err := owned.l2.Set(ctx, "foo", "bar"); err != cache.ErrClosed {
+		t.Fatalf("expected the L2 created by New to be closed, got %v", err)
+	}
+}
@@ -0,0 +1,85 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Typed stores values of type T in a Cache through a Codec. Every entry is
+// prefixed with a codec version byte; entries written with another version
+// read as misses, so bumping the version after a schema change makes old
+// and new instances ignore each other's values instead of decoding them.
+type Typed[T any] struct {
+	cache   Cache
+	codec   Codec
+	version byte
+}
+
+// TypedOption configures a Typed cache.
+type TypedOption func(*typedOptions)
+
+type typedOptions struct {
+	version byte
+}
+
+// WithCodecVersion sets the version byte written with every entry. It
+// defaults to 1.
+func WithCodecVersion(v byte) TypedOption {
+	return func(o *typedOptions) { o.version = v }
+}
+
+// NewTyped wraps c, encoding values with codec.
+func NewTyped[T any](c Cache, codec Codec, opts ...TypedOption) *Typed[T] {
+	o := typedOptions{version: 1}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &Typed[T]{cache: c, codec: codec, version: o.version}
+}
+
+// Get returns the value stored under key. It returns ErrNotFound for
+// missing keys and entries of another codec version, and an error wrapping
+// ErrDecode when the stored value cannot be decoded.
+func (t *Typed[T]) Get(ctx context.Context, key string) (T, error) {
+	var v T
+	data, err := t.cache.GetBytes(ctx, key)
+	if err != nil {
+		return v, err
+	}
+	if len(data) == 0 || data[0] != t.version {
+		return v, ErrNotFound
+	}
+	if err := t.codec.Unmarshal(data[1:], &v); err != nil {
+		return v, fmt.Errorf("%w: %w", ErrDecode, err)
+	}
+	return v, nil
+}
+
+func (t *Typed[T]) Set(ctx context.Context, key string, v T) error {
+	data, err := t.encode(v)
+	if err != nil {
+		return err
+	}
+	return t.cache.SetBytes(ctx, key, data)
+}
+
+func (t *Typed[T]) SetWithTTL(ctx context.Context, key string, v T, ttl time.Duration) error {
+	data, err := t.encode(v)
+	if err != nil {
+		return err
+	}
+	return t.cache.SetWithTTL(ctx, key, string(data), ttl)
+}
+
+func (t *Typed[T]) Del(ctx context.Context, keys ...string) error {
+	return t.cache.Del(ctx, keys...)
+}
+
+func (t *Typed[T]) encode(v T) ([]byte, error) {
+	data, err := t.codec.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("cache: encode: %w", err)
+	}
+	return append([]byte{t.version}, data...), nil
+}
@@ -0,0 +1,85 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/carlosealves2/go-infrakit/cache"
+	"github.com/carlosealves2/go-infrakit/cache/memory"
+)
+
+type profile struct {
+	Name    string
+	Age     int
+	Score   float64
+	Tags    []string
+	Attrs   map[string]string
+	Raw     []byte
+	Created time.Time
+	Parent  *profile
+	secret  string
+}
+
+func TestTypedCodecs(t *testing.T) {
+	ctx := context.Background()
+	m := memory.New(cache.Options{})
+	defer m.Close()
+	want := profile{
+		Name:    "ana",
+		Age:     -42,
+		Score:   1.5,
+		Tags:    []string{"a", "b"},
+		Attrs:   map[string]string{"k": "v"},
+		Raw:     []byte{0, 1, 2},
+		Created: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		Parent:  &profile{Name: "root", Age: 70000},
+	}
+	for name, codec := range map[string]cache.Codec{
+		"json": cache.JSONCodec{},
+		"gob":  cache.GobCodec{},
+	} {
+		t.Run(name, func(t *testing.T) {
+			tc := cache.NewTyped[profile](m, codec)
+			if err := tc.SetWithTTL(ctx, name, want, time.Minute); err != nil {
+				t.Fatalf("set: %v", err)
+			}
+			got, err := tc.Get(ctx, name)
+			if err != nil {
+				t.Fatalf("get: %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("got %+v, want %+v", got, want)
+			}
+		})
+	}
+
+}
+
+func TestTypedErrors(t *testing.T) {
+	ctx := context.Background()
+	m := memory.New(cache.Options{})
+	defer m.Close()
+	tc := cache.NewTyped[profile](m, cache.JSONCodec{})
+	if _, err := tc.Get(ctx, "missing"); err != cache.ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	m.SetBytes(ctx, "broken", []byte("\x01{not json"))
+	_, err := tc.Get(ctx, "broken")
+	if !errors.Is(err, cache.ErrDecode) || errors.Is(err, cache.ErrNotFound) {
+		t.Fatalf("expected ErrDecode, got %v", err)
+	}
+
+	tc.Set(ctx, "versioned", profile{Name: "v1"})
+	v2 := cache.NewTyped[profile](m, cache.JSONCodec{}, cache.WithCodecVersion(2))
+	if _, err := v2.Get(ctx, "versioned"); err != cache.ErrNotFound {
+		t.Fatalf("expected other versions to read as misses, got %v", err)
+	}
+	v2.Set(ctx, "versioned", profile{Name: "v2"})
+	if got, err := v2.Get(ctx, "versioned"); err != nil || got.Name != "v2" {
+		t.Fatalf("get v2: %v %+v", err, got)
+	}
+}
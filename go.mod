@@ -6,6 +6,7 @@ require (
 	github.com/phuslu/log v0.0.0
 	github.com/redis/go-redis/v9 v9.0.0
 	go.opentelemetry.io/otel v0.0.0
+	google.golang.org/protobuf v1.36.11
 )
 
 replace github.com/phuslu/log => ./third_party/phuslu/log
@@ -0,0 +1,89 @@
+// Package glob matches keys against Redis glob patterns.
+package glob
+
+// Match reports whether s matches the Redis glob pattern, which supports
+// *, ?, [...] classes with ranges and ^ negation, and backslash escapes.
+//
+// On a mismatch the last star takes one more byte of s and matching
+// resumes after it. Every other element consumes exactly one byte, so
+// whatever an earlier star could take the last one can take as well, and
+// there is nothing else to retry: matching takes at most
+// len(pattern)*len(s) steps however many stars the pattern has.
+func Match(pattern, s string) bool {
+	p, i := 0, 0
+	// star is the pattern position after the last star, -1 before any, and
+	// next is where s resumes when matching falls back to it.
+	star, next := -1, 0
+	for i < len(s) {
+		if p < len(pattern) {
+			switch pattern[p] {
+			case '*':
+				for p < len(pattern) && pattern[p] == '*' {
+					p++
+				}
+				star, next = p, i
+				continue
+			case '?':
+				p, i = p+1, i+1
+				continue
+			case '[':
+				if rest, ok := matchClass(pattern[p+1:], s[i]); ok {
+					p, i = len(pattern)-len(rest), i+1
+					continue
+				}
+			case '\\':
+				if p+1 < len(pattern) {
+					p++
+				}
+				fallthrough
+			default:
+				if pattern[p] == s[i] {
+					p, i = p+1, i+1
+					continue
+				}
+			}
+		}
+		if star < 0 {
+			return false
+		}
+		next++
+		p, i = star, next
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
+
+// matchClass matches c against the class starting after '[' and returns the
+// pattern following the closing ']'.
+func matchClass(pattern string, c byte) (string, bool) {
+	negate := len(pattern) > 0 && pattern[0] == '^'
+	if negate {
+		pattern = pattern[1:]
+	}
+	matched := false
+	for len(pattern) > 0 && pattern[0] != ']' {
+		lo := pattern[0]
+		if lo == '\\' && len(pattern) > 1 {
+			pattern = pattern[1:]
+			lo = pattern[0]
+		}
+		pattern = pattern[1:]
+		hi := lo
+		if len(pattern) > 1 && pattern[0] == '-' && pattern[1] != ']' {
+			hi = pattern[1]
+			pattern = pattern[2:]
+			if lo > hi {
+				lo, hi = hi, lo
+			}
+		}
+		if lo <= c && c <= hi {
+			matched = true
+		}
+	}
+	if len(pattern) > 0 {
+		pattern = pattern[1:]
+	}
+	return pattern, matched != negate
+}
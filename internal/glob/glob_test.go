@@ -0,0 +1,52 @@
+package glob
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMatch(t *testing.T) {
+	cases := []struct {
+		pattern, s string
+		want       bool
+	}{
+		{"*", "", true},
+		{"user:*", "user:1", true},
+		{"user:*", "order:1", false},
+		{"h?llo", "hello", true},
+		{"h?llo", "hllo", false},
+		{"h[ae]llo", "hallo", true},
+		{"h[^e]llo", "hello", false},
+		{"h[a-c]llo", "hbllo", true},
+		{"a\\*b", "a*b", true},
+		{"a\\*b", "axb", false},
+		{"*:*:end", "a:b:end", true},
+		{"*:*:end", "a:b:en", false},
+		{"**a", "bba", true},
+		{"\\", "\\", true},
+		{"a[", "a", false},
+	}
+	for _, tc := range cases {
+		if got := Match(tc.pattern, tc.s); got != tc.want {
+			t.Errorf("Match(%q, %q) = %v, want %v", tc.pattern, tc.s, got, tc.want)
+		}
+	}
+}
+
+// TestMatchManyStars would not finish if every star were retried at
+// every position of s.
+func TestMatchManyStars(t *testing.T) {
+	pattern := strings.Repeat("*a", 30) + "*b"
+	s := strings.Repeat("a", 1000)
+	start := time.Now()
+	if Match(pattern, s) {
+		t.Fatalf("expected %q not to match", pattern)
+	}
+	if !Match(pattern, s+"b") {
+		t.Fatalf("expected %q to match", pattern)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("matching took %v", d)
+	}
+}
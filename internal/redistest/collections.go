@@ -0,0 +1,544 @@
+package redistest
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// lookupSet returns the set at key, creating it when create is set. A nil
+// item with a nil reply means the key does not exist.
+func (e *engine) lookupSet(key string, create bool) (*item, interface{}) {
+	it, ok := e.lookup(key)
+	switch {
+	case !ok && create:
+		it = &item{set: make(map[string]struct{})}
+		e.db[key] = it
+	case !ok:
+		return nil, nil
+	case it.set == nil:
+		return nil, errWrongType
+	}
+	return it, nil
+}
+
+func (e *engine) lookupZSet(key string, create bool) (*item, interface{}) {
+	it, ok := e.lookup(key)
+	switch {
+	case !ok && create:
+		it = &item{zset: make(map[string]float64)}
+		e.db[key] = it
+	case !ok:
+		return nil, nil
+	case it.zset == nil:
+		return nil, errWrongType
+	}
+	return it, nil
+}
+
+func (e *engine) lookupHash(key string, create bool) (*item, interface{}) {
+	it, ok := e.lookup(key)
+	switch {
+	case !ok && create:
+		it = &item{hash: make(map[string]string)}
+		e.db[key] = it
+	case !ok:
+		return nil, nil
+	case it.hash == nil:
+		return nil, errWrongType
+	}
+	return it, nil
+}
+
+// dropIfEmpty deletes a collection that lost its last element, as Redis
+// does.
+func (e *engine) dropIfEmpty(key string, it *item) {
+	if len(it.set) == 0 && len(it.zset) == 0 && len(it.hash) == 0 {
+		delete(e.db, key)
+	}
+}
+
+func cmdSAdd(e *engine, args []string) interface{} {
+	it, errReply := e.lookupSet(args[1], true)
+	if errReply != nil {
+		return errReply
+	}
+	var n int64
+	for _, m := range args[2:] {
+		if _, ok := it.set[m]; !ok {
+			it.set[m] = struct{}{}
+			n++
+		}
+	}
+	e.touch(args[1])
+	return n
+}
+
+func cmdSRem(e *engine, args []string) interface{} {
+	it, errReply := e.lookupSet(args[1], false)
+	if it == nil {
+		return orZero(errReply)
+	}
+	var n int64
+	for _, m := range args[2:] {
+		if _, ok := it.set[m]; ok {
+			delete(it.set, m)
+			n++
+		}
+	}
+	e.dropIfEmpty(args[1], it)
+	e.touch(args[1])
+	return n
+}
+
+func cmdSMembers(e *engine, args []string) interface{} {
+	it, errReply := e.lookupSet(args[1], false)
+	out := []interface{}{}
+	if it == nil {
+		if errReply != nil {
+			return errReply
+		}
+		return out
+	}
+	for m := range it.set {
+		out = append(out, m)
+	}
+	return out
+}
+
+// cmdSRandMember supports the positive count form, returning distinct
+// members in map order.
+func cmdSRandMember(e *engine, args []string) interface{} {
+	count, err := strconv.Atoi(args[2])
+	if err != nil || count < 0 {
+		return errNotInt
+	}
+	it, errReply := e.lookupSet(args[1], false)
+	out := []interface{}{}
+	if it == nil {
+		if errReply != nil {
+			return errReply
+		}
+		return out
+	}
+	for m := range it.set {
+		if len(out) == count {
+			break
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+func cmdSCard(e *engine, args []string) interface{} {
+	it, errReply := e.lookupSet(args[1], false)
+	if it == nil {
+		return orZero(errReply)
+	}
+	return int64(len(it.set))
+}
+
+func cmdHSet(e *engine, args []string) interface{} {
+	pairs := args[2:]
+	if len(pairs)%2 != 0 {
+		return errWrongArgs(args[0])
+	}
+	it, errReply := e.lookupHash(args[1], true)
+	if errReply != nil {
+		return errReply
+	}
+	var n int64
+	for i := 0; i < len(pairs); i += 2 {
+		if _, ok := it.hash[pairs[i]]; !ok {
+			n++
+		}
+		it.hash[pairs[i]] = pairs[i+1]
+	}
+	e.touch(args[1])
+	return n
+}
+
+func cmdHGet(e *engine, args []string) interface{} {
+	it, errReply := e.lookupHash(args[1], false)
+	if it == nil {
+		return errReply
+	}
+	v, ok := it.hash[args[2]]
+	if !ok {
+		return nil
+	}
+	return v
+}
+
+func cmdHGetAll(e *engine, args []string) interface{} {
+	it, errReply := e.lookupHash(args[1], false)
+	out := []interface{}{}
+	if it == nil {
+		if errReply != nil {
+			return errReply
+		}
+		return out
+	}
+	for f, v := range it.hash {
+		out = append(out, f, v)
+	}
+	return out
+}
+
+func cmdHDel(e *engine, args []string) interface{} {
+	it, errReply := e.lookupHash(args[1], false)
+	if it == nil {
+		return orZero(errReply)
+	}
+	var n int64
+	for _, f := range args[2:] {
+		if _, ok := it.hash[f]; ok {
+			delete(it.hash, f)
+			n++
+		}
+	}
+	e.dropIfEmpty(args[1], it)
+	e.touch(args[1])
+	return n
+}
+
+func cmdHIncrBy(e *engine, args []string) interface{} {
+	delta, err := strconv.ParseInt(args[3], 10, 64)
+	if err != nil {
+		return errNotInt
+	}
+	it, errReply := e.lookupHash(args[1], true)
+	if errReply != nil {
+		return errReply
+	}
+	var cur int64
+	if v, ok := it.hash[args[2]]; ok {
+		if cur, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return redisError("ERR hash value is not an integer")
+		}
+	}
+	if (delta > 0 && cur > math.MaxInt64-delta) || (delta < 0 && cur < math.MinInt64-delta) {
+		return errOverflow
+	}
+	cur += delta
+	it.hash[args[2]] = strconv.FormatInt(cur, 10)
+	e.touch(args[1])
+	return cur
+}
+
+// orZero returns errReply, or zero when the key simply does not exist.
+func orZero(errReply interface{}) interface{} {
+	if errReply != nil {
+		return errReply
+	}
+	return int64(0)
+}
+
+// scoreBound is a ZRANGEBYSCORE bound such as "1.5", "(1.5" or "-inf".
+type scoreBound struct {
+	v         float64
+	exclusive bool
+}
+
+func parseBound(s string) (scoreBound, bool) {
+	var b scoreBound
+	if strings.HasPrefix(s, "(") {
+		b.exclusive = true
+		s = s[1:]
+	}
+	v, ok := parseScore(s)
+	b.v = v
+	return b, ok
+}
+
+func parseScore(s string) (float64, bool) {
+	switch strings.ToLower(s) {
+	case "-inf":
+		return math.Inf(-1), true
+	case "+inf", "inf":
+		return math.Inf(1), true
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	return v, err == nil && !math.IsNaN(v)
+}
+
+// belowOrAt reports whether v is within b taken as a lower bound.
+func (b scoreBound) belowOrAt(v float64) bool {
+	if b.exclusive {
+		return b.v < v
+	}
+	return b.v <= v
+}
+
+// aboveOrAt reports whether v is within b taken as an upper bound.
+func (b scoreBound) aboveOrAt(v float64) bool {
+	if b.exclusive {
+		return b.v > v
+	}
+	return b.v >= v
+}
+
+func formatScore(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "inf"
+	case math.IsInf(v, -1):
+		return "-inf"
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+type zmember struct {
+	member string
+	score  float64
+}
+
+// sortedZSet returns the members ordered by score, then lexicographically.
+func sortedZSet(z map[string]float64) []zmember {
+	out := make([]zmember, 0, len(z))
+	for m, s := range z {
+		out = append(out, zmember{m, s})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].score != out[j].score {
+			return out[i].score < out[j].score
+		}
+		return out[i].member < out[j].member
+	})
+	return out
+}
+
+// cmdZAdd supports the NX, XX, GT, LT and CH flags.
+func cmdZAdd(e *engine, args []string) interface{} {
+	var nx, xx, gt, lt, ch bool
+	i := 2
+flags:
+	for ; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "NX":
+			nx = true
+		case "XX":
+			xx = true
+		case "GT":
+			gt = true
+		case "LT":
+			lt = true
+		case "CH":
+			ch = true
+		default:
+			break flags
+		}
+	}
+	pairs := args[i:]
+	if len(pairs) == 0 || len(pairs)%2 != 0 || (nx && xx) || (gt && lt) || (nx && (gt || lt)) {
+		return errSyntax
+	}
+	scores := make([]float64, len(pairs)/2)
+	for j := range scores {
+		v, ok := parseScore(pairs[2*j])
+		if !ok {
+			return errNotFloat
+		}
+		scores[j] = v
+	}
+	it, errReply := e.lookupZSet(args[1], !xx)
+	if it == nil {
+		return orZero(errReply)
+	}
+	var added, changed int64
+	for j, v := range scores {
+		m := pairs[2*j+1]
+		cur, exists := it.zset[m]
+		switch {
+		case exists && nx, !exists && xx:
+			continue
+		case exists && ((gt && v <= cur) || (lt && v >= cur)):
+			continue
+		}
+		if !exists {
+			added++
+		} else if cur != v {
+			changed++
+		}
+		it.zset[m] = v
+	}
+	e.dropIfEmpty(args[1], it)
+	e.touch(args[1])
+	if ch {
+		return added + changed
+	}
+	return added
+}
+
+func cmdZRem(e *engine, args []string) interface{} {
+	it, errReply := e.lookupZSet(args[1], false)
+	if it == nil {
+		return orZero(errReply)
+	}
+	var n int64
+	for _, m := range args[2:] {
+		if _, ok := it.zset[m]; ok {
+			delete(it.zset, m)
+			n++
+		}
+	}
+	e.dropIfEmpty(args[1], it)
+	e.touch(args[1])
+	return n
+}
+
+func cmdZScore(e *engine, args []string) interface{} {
+	it, errReply := e.lookupZSet(args[1], false)
+	if it == nil {
+		return errReply
+	}
+	v, ok := it.zset[args[2]]
+	if !ok {
+		return nil
+	}
+	return formatScore(v)
+}
+
+func cmdZCard(e *engine, args []string) interface{} {
+	it, errReply := e.lookupZSet(args[1], false)
+	if it == nil {
+		return orZero(errReply)
+	}
+	return int64(len(it.zset))
+}
+
+func cmdZRank(e *engine, args []string) interface{} {
+	it, errReply := e.lookupZSet(args[1], false)
+	if it == nil {
+		return errReply
+	}
+	if _, ok := it.zset[args[2]]; !ok {
+		return nil
+	}
+	for i, z := range sortedZSet(it.zset) {
+		if z.member == args[2] {
+			return int64(i)
+		}
+	}
+	return nil
+}
+
+// cmdZRange supports the rank form with WITHSCORES.
+func cmdZRange(e *engine, args []string) interface{} {
+	start, err1 := strconv.Atoi(args[2])
+	stop, err2 := strconv.Atoi(args[3])
+	if err1 != nil || err2 != nil {
+		return errNotInt
+	}
+	withScores := false
+	for _, a := range args[4:] {
+		if !strings.EqualFold(a, "WITHSCORES") {
+			return errSyntax
+		}
+		withScores = true
+	}
+	it, errReply := e.lookupZSet(args[1], false)
+	out := []interface{}{}
+	if it == nil {
+		if errReply != nil {
+			return errReply
+		}
+		return out
+	}
+	members := sortedZSet(it.zset)
+	n := len(members)
+	if start < 0 {
+		start = max(n+start, 0)
+	}
+	if stop < 0 {
+		stop = n + stop
+	}
+	stop = min(stop, n-1)
+	for i := start; i <= stop; i++ {
+		out = append(out, members[i].member)
+		if withScores {
+			out = append(out, formatScore(members[i].score))
+		}
+	}
+	return out
+}
+
+// cmdZRangeByScore supports WITHSCORES and LIMIT offset count.
+func cmdZRangeByScore(e *engine, args []string) interface{} {
+	lo, ok1 := parseBound(args[2])
+	hi, ok2 := parseBound(args[3])
+	if !ok1 || !ok2 {
+		return redisError("ERR min or max is not a float")
+	}
+	withScores, offset, count := false, 0, -1
+	for i := 4; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "WITHSCORES":
+			withScores = true
+		case "LIMIT":
+			if i+2 >= len(args) {
+				return errSyntax
+			}
+			var err1, err2 error
+			offset, err1 = strconv.Atoi(args[i+1])
+			count, err2 = strconv.Atoi(args[i+2])
+			if err1 != nil || err2 != nil {
+				return errNotInt
+			}
+			i += 2
+		default:
+			return errSyntax
+		}
+	}
+	it, errReply := e.lookupZSet(args[1], false)
+	out := []interface{}{}
+	if it == nil {
+		if errReply != nil {
+			return errReply
+		}
+		return out
+	}
+	for _, z := range sortedZSet(it.zset) {
+		if !lo.belowOrAt(z.score) || !hi.aboveOrAt(z.score) {
+			continue
+		}
+		if offset > 0 {
+			offset--
+			continue
+		}
+		if count == 0 {
+			break
+		}
+		count--
+		out = append(out, z.member)
+		if withScores {
+			out = append(out, formatScore(z.score))
+		}
+	}
+	return out
+}
+
+func cmdZRemRangeByScore(e *engine, args []string) interface{} {
+	lo, ok1 := parseBound(args[2])
+	hi, ok2 := parseBound(args[3])
+	if !ok1 || !ok2 {
+		return redisError("ERR min or max is not a float")
+	}
+	it, errReply := e.lookupZSet(args[1], false)
+	if it == nil {
+		return orZero(errReply)
+	}
+	var n int64
+	for m, v := range it.zset {
+		if lo.belowOrAt(v) && hi.aboveOrAt(v) {
+			delete(it.zset, m)
+			n++
+		}
+	}
+	e.dropIfEmpty(args[1], it)
+	if n > 0 {
+		e.touch(args[1])
+	}
+	return n
+}
@@ -0,0 +1,599 @@
+package redistest
+
+import (
+	"hash/fnv"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/carlosealves2/go-infrakit/internal/glob"
+)
+
+// engine is the keyspace of one database of a Server. Every command runs
+// under a single lock, which gives MULTI/EXEC its atomicity.
+type engine struct {
+	mu       sync.Mutex
+	db       map[string]*item
+	versions map[string]uint64
+	subs     map[string]map[*subscription]struct{}
+	// pushed is closed and replaced whenever a list grows, waking blocked
+	// pops.
+	pushed chan struct{}
+}
+
+// item is a value in the keyspace. Exactly one of the value fields is in
+// use, according to the type the key was created with.
+type item struct {
+	str  string
+	set  map[string]struct{}
+	zset map[string]float64
+	hash map[string]string
+	list []string
+	exp  time.Time
+}
+
+func (it *item) isString() bool {
+	return it.set == nil && it.zset == nil && it.hash == nil && it.list == nil
+}
+
+func (it *item) expired(now time.Time) bool {
+	return !it.exp.IsZero() && !now.Before(it.exp)
+}
+
+// session holds per-connection transaction state.
+type session struct {
+	watched map[string]uint64
+	multi   bool
+	queued  [][]string
+}
+
+func newSession() *session {
+	return &session{watched: make(map[string]uint64)}
+}
+
+func newEngine() *engine {
+	return &engine{
+		db:       make(map[string]*item),
+		versions: make(map[string]uint64),
+		pushed:   make(chan struct{}),
+	}
+}
+
+func (e *engine) exec(sess *session, args []string) interface{} {
+	if len(args) == 0 {
+		return redisError("ERR empty command")
+	}
+	name := strings.ToUpper(args[0])
+	if sess != nil {
+		switch name {
+		case "WATCH":
+			e.mu.Lock()
+			defer e.mu.Unlock()
+			for _, k := range args[1:] {
+				sess.watched[k] = e.versions[k]
+			}
+			return "OK"
+		case "UNWATCH":
+			clear(sess.watched)
+			return "OK"
+		case "MULTI":
+			sess.multi = true
+			sess.queued = nil
+			return "OK"
+		case "DISCARD":
+			sess.multi = false
+			sess.queued = nil
+			clear(sess.watched)
+			return "OK"
+		case "EXEC":
+			return e.execMulti(sess)
+		}
+		if sess.multi {
+			sess.queued = append(sess.queued, args)
+			return "QUEUED"
+		}
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.run(name, args)
+}
+
+func (e *engine) execMulti(sess *session) interface{} {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	defer func() {
+		sess.multi = false
+		sess.queued = nil
+		clear(sess.watched)
+	}()
+	if !sess.multi {
+		return redisError("ERR EXEC without MULTI")
+	}
+	now := time.Now()
+	for k, v := range sess.watched {
+		e.expireIfNeeded(k, now)
+		if e.versions[k] != v {
+			return nil
+		}
+	}
+	replies := make([]interface{}, len(sess.queued))
+	for i, args := range sess.queued {
+		replies[i] = e.run(strings.ToUpper(args[0]), args)
+	}
+	return replies
+}
+
+func (e *engine) run(name string, args []string) interface{} {
+	h, ok := commands[name]
+	if !ok {
+		return redisError("ERR unknown command '" + strings.ToLower(name) + "'")
+	}
+	if len(args) < h.arity {
+		return errWrongArgs(name)
+	}
+	return h.fn(e, args)
+}
+
+type handler struct {
+	arity int
+	fn    func(e *engine, args []string) interface{}
+}
+
+var commands map[string]handler
+
+func init() {
+	commands = map[string]handler{
+		"PING":     {1, cmdPing},
+		"GET":      {2, cmdGet},
+		"GETRANGE": {4, cmdGetRange},
+		"SET":      {3, cmdSet},
+		"DEL":      {2, cmdDel},
+		"EXISTS":   {2, cmdExists},
+		"MGET":     {2, cmdMGet},
+		"MSET":     {3, cmdMSet},
+
+		"INCR":        {2, cmdIncr},
+		"DECR":        {2, cmdIncr},
+		"INCRBY":      {3, cmdIncr},
+		"DECRBY":      {3, cmdIncr},
+		"INCRBYFLOAT": {3, cmdIncrByFloat},
+
+		"PTTL":      {2, cmdPTTL},
+		"PEXPIRE":   {3, cmdPExpire},
+		"PEXPIREAT": {3, cmdPExpireAt},
+		"PERSIST":   {2, cmdPersist},
+		"TOUCH":     {2, cmdExists},
+
+		"SCAN":   {2, cmdScan},
+		"UNLINK": {2, cmdDel},
+
+		"PUBLISH": {3, cmdPublish},
+
+		"SADD":        {3, cmdSAdd},
+		"SREM":        {3, cmdSRem},
+		"SMEMBERS":    {2, cmdSMembers},
+		"SRANDMEMBER": {3, cmdSRandMember},
+		"SCARD":       {2, cmdSCard},
+
+		"HSET":    {4, cmdHSet},
+		"HGET":    {3, cmdHGet},
+		"HGETALL": {2, cmdHGetAll},
+		"HDEL":    {3, cmdHDel},
+		"HINCRBY": {4, cmdHIncrBy},
+
+		"LPUSH":  {3, cmdPush},
+		"RPUSH":  {3, cmdPush},
+		"LPOP":   {2, cmdPop},
+		"RPOP":   {2, cmdPop},
+		"BLPOP":  {3, cmdBPop},
+		"BRPOP":  {3, cmdBPop},
+		"LRANGE": {4, cmdLRange},
+		"LTRIM":  {4, cmdLTrim},
+		"LLEN":   {2, cmdLLen},
+
+		"ZADD":             {4, cmdZAdd},
+		"ZREM":             {3, cmdZRem},
+		"ZSCORE":           {3, cmdZScore},
+		"ZCARD":            {2, cmdZCard},
+		"ZRANK":            {3, cmdZRank},
+		"ZRANGE":           {4, cmdZRange},
+		"ZRANGEBYSCORE":    {4, cmdZRangeByScore},
+		"ZREMRANGEBYSCORE": {4, cmdZRemRangeByScore},
+	}
+}
+
+func errWrongArgs(name string) error {
+	return redisError("ERR wrong number of arguments for '" + strings.ToLower(name) + "' command")
+}
+
+var (
+	errSyntax    = redisError("ERR syntax error")
+	errNotInt    = redisError("ERR value is not an integer or out of range")
+	errWrongType = redisError("WRONGTYPE Operation against a key holding the wrong kind of value")
+	errNotFloat  = redisError("ERR value is not a valid float")
+	errOverflow  = redisError("ERR increment or decrement would overflow")
+)
+
+// touch records a modification of key so watching transactions abort.
+func (e *engine) touch(key string) {
+	e.versions[key]++
+}
+
+// expireIfNeeded removes key when its deadline has passed.
+func (e *engine) expireIfNeeded(key string, now time.Time) {
+	if it, ok := e.db[key]; ok && it.expired(now) {
+		delete(e.db, key)
+		e.touch(key)
+	}
+}
+
+func (e *engine) lookup(key string) (*item, bool) {
+	e.expireIfNeeded(key, time.Now())
+	it, ok := e.db[key]
+	return it, ok
+}
+
+func cmdPing(e *engine, args []string) interface{} {
+	if len(args) > 1 {
+		return args[1]
+	}
+	return "PONG"
+}
+
+func cmdGet(e *engine, args []string) interface{} {
+	it, ok := e.lookup(args[1])
+	if !ok {
+		return nil
+	}
+	if !it.isString() {
+		return errWrongType
+	}
+	return it.str
+}
+
+// cmdGetRange returns the substring between the inclusive offsets start
+// and end, where negative offsets count from the end.
+func cmdGetRange(e *engine, args []string) interface{} {
+	start, err1 := strconv.Atoi(args[2])
+	end, err2 := strconv.Atoi(args[3])
+	if err1 != nil || err2 != nil {
+		return errNotInt
+	}
+	it, ok := e.lookup(args[1])
+	if !ok {
+		return ""
+	}
+	if !it.isString() {
+		return errWrongType
+	}
+	n := len(it.str)
+	if start < 0 {
+		start = max(n+start, 0)
+	}
+	if end < 0 {
+		end = n + end
+	}
+	end = min(end, n-1)
+	if start > end || n == 0 {
+		return ""
+	}
+	return it.str[start : end+1]
+}
+
+func cmdSet(e *engine, args []string) interface{} {
+	key, val := args[1], args[2]
+	var nx, xx, get, keepTTL bool
+	var exp time.Time
+	now := time.Now()
+	for i := 3; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "NX":
+			nx = true
+		case "XX":
+			xx = true
+		case "GET":
+			get = true
+		case "KEEPTTL":
+			keepTTL = true
+		case "EX", "PX", "EXAT", "PXAT":
+			if i+1 >= len(args) {
+				return errSyntax
+			}
+			n, err := strconv.ParseInt(args[i+1], 10, 64)
+			if err != nil {
+				return errNotInt
+			}
+			if n <= 0 {
+				return redisError("ERR invalid expire time in 'set' command")
+			}
+			switch strings.ToUpper(args[i]) {
+			case "EX":
+				exp = now.Add(time.Duration(n) * time.Second)
+			case "PX":
+				exp = now.Add(time.Duration(n) * time.Millisecond)
+			case "EXAT":
+				exp = time.Unix(n, 0)
+			case "PXAT":
+				exp = time.UnixMilli(n)
+			}
+			i++
+		default:
+			return errSyntax
+		}
+	}
+	if nx && xx || keepTTL && !exp.IsZero() {
+		return errSyntax
+	}
+	old, exists := e.lookup(key)
+	var prev interface{}
+	if exists {
+		if get && !old.isString() {
+			return errWrongType
+		}
+		prev = old.str
+	}
+	if (nx && exists) || (xx && !exists) {
+		if get {
+			return prev
+		}
+		return nil
+	}
+	it := &item{str: val, exp: exp}
+	if keepTTL && exists {
+		it.exp = old.exp
+	}
+	e.db[key] = it
+	e.touch(key)
+	if get {
+		return prev
+	}
+	return "OK"
+}
+
+func cmdDel(e *engine, args []string) interface{} {
+	var n int64
+	for _, k := range args[1:] {
+		if _, ok := e.lookup(k); ok {
+			delete(e.db, k)
+			e.touch(k)
+			n++
+		}
+	}
+	return n
+}
+
+func cmdExists(e *engine, args []string) interface{} {
+	var n int64
+	for _, k := range args[1:] {
+		if _, ok := e.lookup(k); ok {
+			n++
+		}
+	}
+	return n
+}
+
+func cmdMGet(e *engine, args []string) interface{} {
+	out := make([]interface{}, len(args)-1)
+	for i, k := range args[1:] {
+		if it, ok := e.lookup(k); ok && it.isString() {
+			out[i] = it.str
+		}
+	}
+	return out
+}
+
+func cmdMSet(e *engine, args []string) interface{} {
+	if len(args)%2 != 1 {
+		return errWrongArgs(args[0])
+	}
+	for i := 1; i < len(args); i += 2 {
+		e.db[args[i]] = &item{str: args[i+1]}
+		e.touch(args[i])
+	}
+	return "OK"
+}
+
+// setKeepTTL stores val under key, keeping the deadline of an existing key.
+func (e *engine) setKeepTTL(key, val string) {
+	if it, ok := e.lookup(key); ok {
+		it.str = val
+	} else {
+		e.db[key] = &item{str: val}
+	}
+	e.touch(key)
+}
+
+func cmdIncr(e *engine, args []string) interface{} {
+	delta := int64(1)
+	if len(args) > 2 {
+		n, err := strconv.ParseInt(args[2], 10, 64)
+		if err != nil {
+			return errNotInt
+		}
+		delta = n
+	}
+	if name := strings.ToUpper(args[0]); name == "DECR" || name == "DECRBY" {
+		delta = -delta
+	}
+	var cur int64
+	if it, ok := e.lookup(args[1]); ok {
+		if !it.isString() {
+			return errWrongType
+		}
+		n, err := strconv.ParseInt(it.str, 10, 64)
+		if err != nil {
+			return errNotInt
+		}
+		cur = n
+	}
+	if (delta > 0 && cur > math.MaxInt64-delta) || (delta < 0 && cur < math.MinInt64-delta) {
+		return errOverflow
+	}
+	cur += delta
+	e.setKeepTTL(args[1], strconv.FormatInt(cur, 10))
+	return cur
+}
+
+func cmdIncrByFloat(e *engine, args []string) interface{} {
+	delta, err := strconv.ParseFloat(args[2], 64)
+	if err != nil {
+		return errNotFloat
+	}
+	var cur float64
+	if it, ok := e.lookup(args[1]); ok {
+		if !it.isString() {
+			return errWrongType
+		}
+		if cur, err = strconv.ParseFloat(it.str, 64); err != nil {
+			return errNotFloat
+		}
+	}
+	cur += delta
+	if math.IsNaN(cur) || math.IsInf(cur, 0) {
+		return redisError("ERR increment would produce NaN or Infinity")
+	}
+	s := strconv.FormatFloat(cur, 'f', -1, 64)
+	e.setKeepTTL(args[1], s)
+	return s
+}
+
+func cmdPTTL(e *engine, args []string) interface{} {
+	it, ok := e.lookup(args[1])
+	switch {
+	case !ok:
+		return int64(-2)
+	case it.exp.IsZero():
+		return int64(-1)
+	}
+	return max(time.Until(it.exp).Milliseconds(), 0)
+}
+
+// cmdPExpire supports the NX, XX, GT and LT flags of Redis 7.
+func cmdPExpire(e *engine, args []string) interface{} {
+	ms, err := strconv.ParseInt(args[2], 10, 64)
+	if err != nil {
+		return errNotInt
+	}
+	return e.expireAt(args[1], time.Now().Add(time.Duration(ms)*time.Millisecond), args[3:])
+}
+
+func cmdPExpireAt(e *engine, args []string) interface{} {
+	ms, err := strconv.ParseInt(args[2], 10, 64)
+	if err != nil {
+		return errNotInt
+	}
+	return e.expireAt(args[1], time.UnixMilli(ms), args[3:])
+}
+
+// expireAt sets the deadline of key subject to the NX, XX, GT and LT flags.
+// A deadline in the past deletes the key.
+func (e *engine) expireAt(key string, exp time.Time, flags []string) interface{} {
+	it, ok := e.lookup(key)
+	if !ok {
+		return int64(0)
+	}
+	for _, flag := range flags {
+		var skip bool
+		switch strings.ToUpper(flag) {
+		case "NX":
+			skip = !it.exp.IsZero()
+		case "XX":
+			skip = it.exp.IsZero()
+		case "GT":
+			skip = it.exp.IsZero() || !exp.After(it.exp)
+		case "LT":
+			skip = !it.exp.IsZero() && !exp.Before(it.exp)
+		default:
+			return errSyntax
+		}
+		if skip {
+			return int64(0)
+		}
+	}
+	if !exp.After(time.Now()) {
+		delete(e.db, key)
+	} else {
+		it.exp = exp
+	}
+	e.touch(key)
+	return int64(1)
+}
+
+func cmdPersist(e *engine, args []string) interface{} {
+	it, ok := e.lookup(args[1])
+	if !ok || it.exp.IsZero() {
+		return int64(0)
+	}
+	it.exp = time.Time{}
+	e.touch(args[1])
+	return int64(1)
+}
+
+// cmdScan orders keys by a 64-bit hash and uses the hash of the next key as
+// cursor, so keys present for the whole iteration are returned exactly
+// once even while others are added or removed.
+func cmdScan(e *engine, args []string) interface{} {
+	cursor, err := strconv.ParseUint(args[1], 10, 64)
+	if err != nil {
+		return redisError("ERR invalid cursor")
+	}
+	match, count := "*", 10
+	for i := 2; i+1 < len(args); i += 2 {
+		switch strings.ToUpper(args[i]) {
+		case "MATCH":
+			match = args[i+1]
+		case "COUNT":
+			if count, err = strconv.Atoi(args[i+1]); err != nil || count < 1 {
+				return errSyntax
+			}
+		default:
+			return errSyntax
+		}
+	}
+	type hashed struct {
+		h   uint64
+		key string
+	}
+	now := time.Now()
+	var keys []hashed
+	for k, it := range e.db {
+		if it.expired(now) {
+			continue
+		}
+		if h := keyHash(k); h >= cursor {
+			keys = append(keys, hashed{h, k})
+		}
+	}
+	slices.SortFunc(keys, func(a, b hashed) int {
+		if a.h != b.h {
+			if a.h < b.h {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a.key, b.key)
+	})
+	page := []interface{}{}
+	next := uint64(0)
+	for i, k := range keys {
+		// Only stop between distinct hashes so colliding keys share a page.
+		if i >= count && k.h != keys[i-1].h {
+			next = k.h
+			break
+		}
+		if glob.Match(match, k.key) {
+			page = append(page, k.key)
+		}
+	}
+	return []interface{}{strconv.FormatUint(next, 10), page}
+}
+
+func keyHash(key string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	// Zero is reserved for the start and end of an iteration.
+	return max(h.Sum64(), 1)
+}
@@ -0,0 +1,185 @@
+package redistest
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// blocking lists the commands that wait for data outside transactions.
+// Inside MULTI they behave like their non-blocking forms.
+var blocking = map[string]bool{"BLPOP": true, "BRPOP": true}
+
+func (e *engine) lookupList(key string, create bool) (*item, interface{}) {
+	it, ok := e.lookup(key)
+	switch {
+	case !ok && create:
+		it = &item{list: []string{}}
+		e.db[key] = it
+	case !ok:
+		return nil, nil
+	case it.list == nil:
+		return nil, errWrongType
+	}
+	return it, nil
+}
+
+// listIndex resolves a possibly negative LRANGE/LTRIM range to [start,
+// stop) over n elements.
+func listIndex(n, start, stop int) (int, int) {
+	if start < 0 {
+		start = max(n+start, 0)
+	}
+	if stop < 0 {
+		stop = n + stop
+	}
+	stop = min(stop, n-1)
+	if start > stop {
+		return 0, 0
+	}
+	return start, stop + 1
+}
+
+func cmdPush(e *engine, args []string) interface{} {
+	it, errReply := e.lookupList(args[1], true)
+	if errReply != nil {
+		return errReply
+	}
+	for _, v := range args[2:] {
+		if strings.EqualFold(args[0], "LPUSH") {
+			it.list = append([]string{v}, it.list...)
+		} else {
+			it.list = append(it.list, v)
+		}
+	}
+	e.touch(args[1])
+	close(e.pushed)
+	e.pushed = make(chan struct{})
+	return int64(len(it.list))
+}
+
+func (e *engine) pop(key string, left bool) (string, bool, interface{}) {
+	it, errReply := e.lookupList(key, false)
+	if it == nil {
+		return "", false, errReply
+	}
+	var v string
+	if left {
+		v, it.list = it.list[0], it.list[1:]
+	} else {
+		v, it.list = it.list[len(it.list)-1], it.list[:len(it.list)-1]
+	}
+	if len(it.list) == 0 {
+		delete(e.db, key)
+	}
+	e.touch(key)
+	return v, true, nil
+}
+
+func cmdPop(e *engine, args []string) interface{} {
+	v, ok, errReply := e.pop(args[1], strings.EqualFold(args[0], "LPOP"))
+	if !ok {
+		return errReply
+	}
+	return v
+}
+
+// cmdBPop pops from the first non-empty key, replying with the key and the
+// value, or nil when every list is empty.
+func cmdBPop(e *engine, args []string) interface{} {
+	left := strings.EqualFold(args[0], "BLPOP")
+	for _, key := range args[1 : len(args)-1] {
+		v, ok, errReply := e.pop(key, left)
+		if errReply != nil {
+			return errReply
+		}
+		if ok {
+			return []interface{}{key, v}
+		}
+	}
+	return nil
+}
+
+// block retries a blocking pop each time a list grows until it succeeds,
+// the timeout in seconds passes or ctx is done. A zero timeout waits
+// indefinitely.
+func (e *engine) block(ctx context.Context, args []string) interface{} {
+	timeout, err := strconv.ParseFloat(args[len(args)-1], 64)
+	if err != nil || timeout < 0 {
+		return redisError("ERR timeout is not a float or out of range")
+	}
+	var expired <-chan time.Time
+	if timeout > 0 {
+		t := time.NewTimer(time.Duration(timeout * float64(time.Second)))
+		defer t.Stop()
+		expired = t.C
+	}
+	name := strings.ToUpper(args[0])
+	for {
+		e.mu.Lock()
+		reply := e.run(name, args)
+		pushed := e.pushed
+		e.mu.Unlock()
+		if reply != nil {
+			return reply
+		}
+		select {
+		case <-pushed:
+		case <-expired:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func cmdLRange(e *engine, args []string) interface{} {
+	start, err1 := strconv.Atoi(args[2])
+	stop, err2 := strconv.Atoi(args[3])
+	if err1 != nil || err2 != nil {
+		return errNotInt
+	}
+	it, errReply := e.lookupList(args[1], false)
+	out := []interface{}{}
+	if it == nil {
+		if errReply != nil {
+			return errReply
+		}
+		return out
+	}
+	lo, hi := listIndex(len(it.list), start, stop)
+	for _, v := range it.list[lo:hi] {
+		out = append(out, v)
+	}
+	return out
+}
+
+func cmdLTrim(e *engine, args []string) interface{} {
+	start, err1 := strconv.Atoi(args[2])
+	stop, err2 := strconv.Atoi(args[3])
+	if err1 != nil || err2 != nil {
+		return errNotInt
+	}
+	it, errReply := e.lookupList(args[1], false)
+	if errReply != nil {
+		return errReply
+	}
+	if it != nil {
+		lo, hi := listIndex(len(it.list), start, stop)
+		it.list = append([]string{}, it.list[lo:hi]...)
+		if len(it.list) == 0 {
+			delete(e.db, args[1])
+		}
+		e.touch(args[1])
+	}
+	return "OK"
+}
+
+func cmdLLen(e *engine, args []string) interface{} {
+	it, errReply := e.lookupList(args[1], false)
+	if it == nil {
+		return orZero(errReply)
+	}
+	return int64(len(it.list))
+}
@@ -0,0 +1,158 @@
+package redistest
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// maxBulkLen bounds bulk strings and aggregates read from the wire, like
+// the proto-max-bulk-len default of the server.
+const maxBulkLen = 512 << 20
+
+// reader decodes commands sent by clients.
+type reader struct {
+	*bufio.Reader
+}
+
+func newReader(r io.Reader) *reader {
+	return &reader{bufio.NewReader(r)}
+}
+
+func (r *reader) readLine() (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return "", fmt.Errorf("redistest: invalid line %q", line)
+	}
+	return line[:len(line)-2], nil
+}
+
+// readLen parses the length of an array or bulk string.
+func readLen(line string) (int, error) {
+	n, err := strconv.Atoi(line[1:])
+	if err != nil || n < -1 || n > maxBulkLen {
+		return 0, fmt.Errorf("redistest: invalid length %q", line)
+	}
+	return n, nil
+}
+
+func (r *reader) readBulk(n int) (string, error) {
+	buf := make([]byte, n+2)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return "", err
+	}
+	if buf[n] != '\r' || buf[n+1] != '\n' {
+		return "", fmt.Errorf("redistest: invalid bulk string terminator")
+	}
+	return string(buf[:n]), nil
+}
+
+// readCommand reads a command sent as an array of bulk strings, or as an
+// inline command separated by spaces.
+func (r *reader) readCommand() ([]string, error) {
+	line, err := r.readLine()
+	if err != nil {
+		return nil, err
+	}
+	if line[0] != '*' {
+		return strings.Fields(line), nil
+	}
+	n, err := readLen(line)
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, max(n, 0))
+	for i := range args {
+		line, err := r.readLine()
+		if err != nil {
+			return nil, err
+		}
+		if line[0] != '$' {
+			return nil, fmt.Errorf("redistest: expected bulk string, got %q", line)
+		}
+		size, err := readLen(line)
+		if err != nil || size < 0 {
+			return nil, fmt.Errorf("redistest: invalid bulk length %q", line)
+		}
+		if args[i], err = r.readBulk(size); err != nil {
+			return nil, err
+		}
+	}
+	return args, nil
+}
+
+// respMap, respPush and respDouble mark server replies that have their own
+// RESP3 type and fall back to arrays and bulk strings in RESP2.
+type (
+	respMap    []interface{}
+	respPush   []interface{}
+	respDouble float64
+)
+
+// writeReply encodes v for a connection speaking protocol proto.
+func writeReply(w *bufio.Writer, v interface{}, proto int) {
+	switch v := v.(type) {
+	case nil:
+		if proto == 3 {
+			w.WriteString("_\r\n")
+		} else {
+			w.WriteString("$-1\r\n")
+		}
+	case status:
+		w.WriteString("+" + string(v) + "\r\n")
+	case string:
+		w.WriteString("$" + strconv.Itoa(len(v)) + "\r\n" + v + "\r\n")
+	case int64:
+		w.WriteString(":" + strconv.FormatInt(v, 10) + "\r\n")
+	case respDouble:
+		f := float64(v)
+		if proto != 3 {
+			writeReply(w, strconv.FormatFloat(f, 'g', -1, 64), proto)
+			return
+		}
+		var s string
+		switch {
+		case math.IsInf(f, 1):
+			s = "inf"
+		case math.IsInf(f, -1):
+			s = "-inf"
+		default:
+			s = strconv.FormatFloat(f, 'g', -1, 64)
+		}
+		w.WriteString("," + s + "\r\n")
+	case error:
+		w.WriteString("-" + strings.ReplaceAll(v.Error(), "\r\n", " ") + "\r\n")
+	case respMap:
+		if proto != 3 {
+			writeReply(w, []interface{}(v), proto)
+			return
+		}
+		w.WriteString("%" + strconv.Itoa(len(v)/2) + "\r\n")
+		for _, el := range v {
+			writeReply(w, el, proto)
+		}
+	case respPush:
+		if proto == 3 {
+			w.WriteString(">")
+		} else {
+			w.WriteString("*")
+		}
+		w.WriteString(strconv.Itoa(len(v)) + "\r\n")
+		for _, el := range v {
+			writeReply(w, el, proto)
+		}
+	case []interface{}:
+		w.WriteString("*" + strconv.Itoa(len(v)) + "\r\n")
+		for _, el := range v {
+			writeReply(w, el, proto)
+		}
+	default:
+		writeReply(w, fmt.Sprint(v), proto)
+	}
+}
@@ -0,0 +1,51 @@
+package redistest
+
+// subscription is a client connection subscribed to channels. Like Redis,
+// it buffers a bounded number of pushes; those published while the buffer
+// is full are dropped.
+type subscription struct {
+	channels []string
+	ch       chan respPush
+}
+
+func newSubscription(channels []string) *subscription {
+	return &subscription{channels: channels, ch: make(chan respPush, 100)}
+}
+
+func (e *engine) subscribe(sub *subscription) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.subs == nil {
+		e.subs = make(map[string]map[*subscription]struct{})
+	}
+	for i, channel := range sub.channels {
+		if e.subs[channel] == nil {
+			e.subs[channel] = make(map[*subscription]struct{})
+		}
+		e.subs[channel][sub] = struct{}{}
+		sub.ch <- respPush{"subscribe", channel, int64(i + 1)}
+	}
+}
+
+// unsubscribe detaches sub and closes its channel. Publishing happens under
+// the engine lock, so nothing is sent after the channel is closed.
+func (e *engine) unsubscribe(sub *subscription) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, channel := range sub.channels {
+		delete(e.subs[channel], sub)
+	}
+	close(sub.ch)
+}
+
+func cmdPublish(e *engine, args []string) interface{} {
+	var n int64
+	for sub := range e.subs[args[1]] {
+		select {
+		case sub.ch <- respPush{"message", args[1], args[2]}:
+			n++
+		default:
+		}
+	}
+	return n
+}
@@ -0,0 +1,461 @@
+// Package redistest runs fake Redis servers for the tests of go-infrakit,
+// so they need neither a Redis installation nor the network beyond the
+// loopback interface.
+package redistest
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// Serve starts srv on a loopback port for the duration of the test and
+// returns its address.
+func Serve(t testing.TB, srv *Server) string {
+	t.Helper()
+	if err := srv.Listen("127.0.0.1:0"); err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { srv.Close() })
+	return srv.Addr()
+}
+
+// redisError is an error reply.
+type redisError string
+
+func (e redisError) Error() string { return string(e) }
+
+// Server is a fake Redis server speaking RESP2 and RESP3 on a TCP
+// listener. It stands in for Redis in tests, with HELLO, AUTH, SELECT over
+// 16 databases, transactions, blocking pops and pub/sub, and implements
+// the commands go-infrakit sends. With Cluster or Sentinel set it acts as
+// a cluster node or as a sentinel.
+type Server struct {
+	// Password, when set, must be presented for Username with AUTH or
+	// HELLO before other commands. Username defaults to "default".
+	Username string
+	Password string
+
+	// TLSConfig makes the server accept TLS connections only.
+	TLSConfig *tls.Config
+
+	// DisableHello rejects HELLO like servers older than Redis 6, so
+	// clients stay on RESP2.
+	DisableHello bool
+
+	// Cluster makes the server a node of a cluster, serving the slots the
+	// cluster assigns to it.
+	Cluster *Cluster
+
+	// Sentinel makes the server answer SENTINEL commands.
+	Sentinel *Sentinel
+
+	readOnly atomic.Bool
+
+	ln     net.Listener
+	dbs    [16]*engine
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu    sync.Mutex
+	conns map[net.Conn]struct{}
+}
+
+// Listen starts serving on addr, such as "127.0.0.1:0".
+func (s *Server) Listen(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	if s.TLSConfig != nil {
+		ln = tls.NewListener(ln, s.TLSConfig)
+	}
+	s.ln = ln
+	for i := range s.dbs {
+		s.dbs[i] = newEngine()
+	}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	s.conns = make(map[net.Conn]struct{})
+	s.wg.Add(1)
+	go s.accept()
+	return nil
+}
+
+// Addr returns the address the server listens on.
+func (s *Server) Addr() string { return s.ln.Addr().String() }
+
+// Close stops listening, closes every connection and waits for them to
+// finish.
+func (s *Server) Close() error {
+	err := s.ln.Close()
+	s.cancel()
+	s.mu.Lock()
+	for nc := range s.conns {
+		nc.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+	return err
+}
+
+// SetReadOnly makes the server reject writes like a replica, as a demoted
+// master does after a failover.
+func (s *Server) SetReadOnly(readOnly bool) { s.readOnly.Store(readOnly) }
+
+func (s *Server) accept() {
+	defer s.wg.Done()
+	for {
+		nc, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns[nc] = struct{}{}
+		s.mu.Unlock()
+		s.wg.Add(1)
+		go s.serve(nc)
+	}
+}
+
+// serverConn is the state of one client connection.
+type serverConn struct {
+	s      *Server
+	proto  int
+	authed bool
+	db     int
+	sess   *session
+	subs   []*subscription
+	// asking lets a node importing a slot serve the next command, or the
+	// next transaction, for it.
+	asking bool
+	// txDirty records a command refused while queueing, which aborts the
+	// transaction.
+	txDirty bool
+
+	// mu serializes replies with messages pushed to subscribers.
+	mu sync.Mutex
+	bw *bufio.Writer
+}
+
+// noReply is returned for commands whose replies are pushed separately.
+type noReply struct{}
+
+// status marks replies sent as simple strings.
+type status string
+
+// serve reads commands on a goroutine of their own so a client hanging up
+// cancels the blocking pop it was waiting on.
+func (s *Server) serve(nc net.Conn) {
+	defer s.wg.Done()
+	ctx, cancel := context.WithCancel(s.ctx)
+	sc := &serverConn{s: s, proto: 2, authed: s.Password == "", sess: newSession(), bw: bufio.NewWriter(nc)}
+	defer func() {
+		cancel()
+		nc.Close()
+		for _, sub := range sc.subs {
+			s.dbs[0].unsubscribe(sub)
+		}
+		s.mu.Lock()
+		delete(s.conns, nc)
+		s.mu.Unlock()
+	}()
+
+	cmds := make(chan []string)
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer close(cmds)
+		defer cancel()
+		rd := newReader(nc)
+		for {
+			args, err := rd.readCommand()
+			if err != nil {
+				return
+			}
+			select {
+			case cmds <- args:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	for args := range cmds {
+		if len(args) == 0 {
+			continue
+		}
+		reply := sc.exec(ctx, args)
+		if _, ok := reply.(noReply); ok {
+			continue
+		}
+		if sc.write(reply) != nil || strings.EqualFold(args[0], "QUIT") {
+			return
+		}
+	}
+}
+
+func (sc *serverConn) write(v interface{}) error {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	writeReply(sc.bw, v, sc.proto)
+	return sc.bw.Flush()
+}
+
+func (sc *serverConn) exec(ctx context.Context, args []string) interface{} {
+	name := strings.ToUpper(args[0])
+	switch {
+	case name == "HELLO":
+		return sc.hello(args)
+	case name == "AUTH":
+		return sc.auth(args)
+	case name == "QUIT":
+		return status("OK")
+	case !sc.authed:
+		return redisError("NOAUTH Authentication required.")
+	}
+	defer func() {
+		if name != "ASKING" && !sc.sess.multi {
+			sc.asking = false
+		}
+	}()
+	switch name {
+	case "ASKING":
+		if sc.s.Cluster == nil {
+			return redisError("ERR This instance has cluster support disabled")
+		}
+		sc.asking = true
+		return status("OK")
+	case "CLUSTER":
+		if sc.s.Cluster == nil {
+			return redisError("ERR This instance has cluster support disabled")
+		}
+		return sc.s.Cluster.command(args)
+	case "SENTINEL":
+		if sc.s.Sentinel == nil {
+			return redisError("ERR unknown command 'sentinel'")
+		}
+		return sc.s.Sentinel.command(args)
+	case "SELECT":
+		if sc.s.Cluster != nil {
+			return redisError("ERR SELECT is not allowed in cluster mode")
+		}
+		if len(args) != 2 {
+			return errWrongArgs(name)
+		}
+		db, err := strconv.Atoi(args[1])
+		if err != nil {
+			return errNotInt
+		}
+		if db < 0 || db >= len(sc.s.dbs) {
+			return redisError("ERR DB index is out of range")
+		}
+		sc.db = db
+		return status("OK")
+	case "SUBSCRIBE":
+		if len(args) < 2 {
+			return errWrongArgs(name)
+		}
+		// Pub/sub is shared by all databases.
+		sub := newSubscription(args[1:])
+		sc.s.dbs[0].subscribe(sub)
+		sc.subs = append(sc.subs, sub)
+		go sc.forward(sub)
+		return noReply{}
+	case "PUBLISH":
+		if sc.s.Cluster != nil {
+			return sc.s.Cluster.publish(args)
+		}
+		return sc.s.dbs[0].exec(nil, args)
+	}
+	e := sc.s.dbs[sc.db]
+	switch name {
+	case "MULTI", "DISCARD":
+		sc.txDirty = false
+	case "EXEC":
+		if sc.txDirty {
+			sc.txDirty = false
+			e.exec(sc.sess, []string{"DISCARD"})
+			return redisError("EXECABORT Transaction discarded because of previous errors.")
+		}
+	}
+	if err := sc.check(name, args); err != nil {
+		if sc.sess.multi {
+			sc.txDirty = true
+		}
+		return err
+	}
+	if blocking[name] && !sc.sess.multi {
+		return e.block(ctx, args)
+	}
+	var queued [][]string
+	if name == "EXEC" {
+		queued = sc.sess.queued
+	}
+	reply := e.exec(sc.sess, args)
+	if sc.sess.multi && reply == "QUEUED" {
+		return status("QUEUED")
+	}
+	return sc.shape(name, args, reply, queued)
+}
+
+// writes lists the commands a read-only server rejects.
+var writes = map[string]bool{
+	"SET": true, "DEL": true, "UNLINK": true, "MSET": true,
+	"INCR": true, "DECR": true, "INCRBY": true, "DECRBY": true, "INCRBYFLOAT": true,
+	"PEXPIRE": true, "PEXPIREAT": true, "PERSIST": true,
+	"SADD": true, "SREM": true,
+	"HSET": true, "HDEL": true, "HINCRBY": true,
+	"LPUSH": true, "RPUSH": true, "LPOP": true, "RPOP": true, "BLPOP": true, "BRPOP": true, "LTRIM": true,
+	"ZADD": true, "ZREM": true, "ZREMRANGEBYSCORE": true,
+}
+
+// check refuses writes on a read-only server and, on a cluster node, keys
+// of slots the node does not serve.
+func (sc *serverConn) check(name string, args []string) error {
+	if writes[name] && sc.s.readOnly.Load() {
+		return redisError("READONLY You can't write against a read only replica.")
+	}
+	if sc.s.Cluster != nil {
+		return sc.s.Cluster.route(sc.s, sc.asking, args)
+	}
+	return nil
+}
+
+// shape gives engine replies the types a server sends: simple strings for
+// status replies and, in RESP3, maps and doubles. queued holds the
+// commands an EXEC reply answers.
+func (sc *serverConn) shape(name string, args []string, reply interface{}, queued [][]string) interface{} {
+	switch r := reply.(type) {
+	case string:
+		switch name {
+		case "SET", "MSET", "LTRIM", "WATCH", "UNWATCH", "MULTI", "DISCARD":
+			if r == "OK" {
+				return status(r)
+			}
+		case "PING":
+			if len(args) == 1 {
+				return status(r)
+			}
+		case "ZSCORE":
+			if f, err := strconv.ParseFloat(r, 64); err == nil {
+				return respDouble(f)
+			}
+		}
+	case []interface{}:
+		switch name {
+		case "HGETALL":
+			return respMap(r)
+		case "ZRANGE", "ZRANGEBYSCORE":
+			if sc.proto != 3 || !strings.EqualFold(args[len(args)-1], "WITHSCORES") {
+				break
+			}
+			pairs := make([]interface{}, 0, len(r)/2)
+			for i := 0; i+1 < len(r); i += 2 {
+				f, _ := strconv.ParseFloat(r[i+1].(string), 64)
+				pairs = append(pairs, []interface{}{r[i], respDouble(f)})
+			}
+			return pairs
+		case "EXEC":
+			for i := range min(len(r), len(queued)) {
+				r[i] = sc.shape(strings.ToUpper(queued[i][0]), queued[i], r[i], nil)
+			}
+		}
+	}
+	return reply
+}
+
+// hello switches the protocol, authenticating first when AUTH is given.
+func (sc *serverConn) hello(args []string) interface{} {
+	if sc.s.DisableHello {
+		return redisError("ERR unknown command 'hello'")
+	}
+	proto := sc.proto
+	i := 1
+	if len(args) > 1 {
+		v, err := strconv.Atoi(args[1])
+		if err != nil {
+			return redisError("ERR Protocol version is not an integer or out of range")
+		}
+		if v != 2 && v != 3 {
+			return redisError("NOPROTO unsupported protocol version")
+		}
+		proto, i = v, 2
+	}
+	for ; i < len(args); i++ {
+		switch opt := strings.ToUpper(args[i]); {
+		case opt == "AUTH" && i+2 < len(args):
+			if err := sc.login(args[i+1], args[i+2]); err != nil {
+				return err
+			}
+			i += 2
+		case opt == "SETNAME" && i+1 < len(args):
+			i++
+		default:
+			return errSyntax
+		}
+	}
+	if !sc.authed {
+		return redisError("NOAUTH HELLO must be called with the client already authenticated, otherwise the HELLO <proto> AUTH <user> <pass> option can be used to authenticate the client and select the RESP protocol version at the same time")
+	}
+	sc.proto = proto
+	mode := "standalone"
+	switch {
+	case sc.s.Cluster != nil:
+		mode = "cluster"
+	case sc.s.Sentinel != nil:
+		mode = "sentinel"
+	}
+	return respMap{
+		"server", "redis",
+		"version", "7.2.0",
+		"proto", int64(proto),
+		"mode", mode,
+		"role", "master",
+		"modules", []interface{}{},
+	}
+}
+
+func (sc *serverConn) auth(args []string) interface{} {
+	var err error
+	switch len(args) {
+	case 2:
+		if sc.s.Password == "" {
+			return redisError("ERR AUTH <password> called without any password configured for the default user. Are you sure your configuration is correct?")
+		}
+		err = sc.login("default", args[1])
+	case 3:
+		err = sc.login(args[1], args[2])
+	default:
+		return errWrongArgs("AUTH")
+	}
+	if err != nil {
+		return err
+	}
+	return status("OK")
+}
+
+func (sc *serverConn) login(user, password string) error {
+	want := sc.s.Username
+	if want == "" {
+		want = "default"
+	}
+	if user != want || (sc.s.Password != "" && password != sc.s.Password) {
+		return redisError("WRONGPASS invalid username-password pair or user is disabled.")
+	}
+	sc.authed = true
+	return nil
+}
+
+// forward pushes the confirmations and messages of sub to the client until
+// the subscription is closed.
+func (sc *serverConn) forward(sub *subscription) {
+	for push := range sub.ch {
+		_ = sc.write(push)
+	}
+}
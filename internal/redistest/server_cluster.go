@@ -0,0 +1,205 @@
+package redistest
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Cluster is the slot table shared by the servers of a fake Redis Cluster.
+// A server with Cluster set serves the keys of the slots it owns, answers
+// MOVED for the others and CROSSSLOT for commands whose keys span slots,
+// and reports the table with CLUSTER SLOTS. Slots are assigned to servers
+// that are listening.
+type Cluster struct {
+	mu        sync.Mutex
+	owners    [slotCount]*Server
+	importing map[int]*Server
+}
+
+// Assign makes srv the owner of the slots from through to, inclusive,
+// moving the keys they hold to it and completing any migration.
+func (cl *Cluster) Assign(srv *Server, from, to int) {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	sources := make(map[*Server]bool)
+	for slot := from; slot <= to; slot++ {
+		if owner := cl.owners[slot]; owner != nil && owner != srv {
+			sources[owner] = true
+		}
+		if importer := cl.importing[slot]; importer != nil && importer != srv {
+			sources[importer] = true
+		}
+		cl.owners[slot] = srv
+		delete(cl.importing, slot)
+	}
+	for src := range sources {
+		moveKeys(src, srv, func(slot int) bool { return slot >= from && slot <= to })
+	}
+}
+
+// Migrate starts moving slot to srv. Its keys move at once, and until
+// Assign completes the migration the owner answers ASK for them while srv
+// serves them to clients that sent ASKING.
+func (cl *Cluster) Migrate(slot int, srv *Server) {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	if cl.importing == nil {
+		cl.importing = make(map[int]*Server)
+	}
+	cl.importing[slot] = srv
+	if owner := cl.owners[slot]; owner != nil && owner != srv {
+		moveKeys(owner, srv, func(s int) bool { return s == slot })
+	}
+}
+
+// moveKeys moves the keys of the slots selected by in from one server to
+// another.
+func moveKeys(from, to *Server, in func(slot int) bool) {
+	src, dst := from.dbs[0], to.dbs[0]
+	moved := make(map[string]*item)
+	src.mu.Lock()
+	for key, it := range src.db {
+		if in(hashSlot(key)) {
+			moved[key] = it
+			delete(src.db, key)
+			src.touch(key)
+		}
+	}
+	src.mu.Unlock()
+	dst.mu.Lock()
+	for key, it := range moved {
+		dst.db[key] = it
+		dst.touch(key)
+	}
+	dst.mu.Unlock()
+}
+
+// route checks that srv serves the keys of args.
+func (cl *Cluster) route(srv *Server, asking bool, args []string) error {
+	keys := commandKeys(args)
+	if len(keys) == 0 {
+		return nil
+	}
+	slot := hashSlot(keys[0])
+	for _, key := range keys[1:] {
+		if hashSlot(key) != slot {
+			return redisError("CROSSSLOT Keys in request don't hash to the same slot")
+		}
+	}
+	cl.mu.Lock()
+	owner, importer := cl.owners[slot], cl.importing[slot]
+	cl.mu.Unlock()
+	switch {
+	case owner == nil:
+		return redisError("CLUSTERDOWN Hash slot not served")
+	case owner == srv && importer == nil, importer == srv && asking:
+		return nil
+	case owner == srv:
+		return redisError(fmt.Sprintf("ASK %d %s", slot, importer.Addr()))
+	}
+	return redisError(fmt.Sprintf("MOVED %d %s", slot, owner.Addr()))
+}
+
+func (cl *Cluster) command(args []string) interface{} {
+	if len(args) < 2 {
+		return errWrongArgs("CLUSTER")
+	}
+	switch strings.ToUpper(args[1]) {
+	case "SLOTS":
+		return cl.slots()
+	case "KEYSLOT":
+		if len(args) != 3 {
+			return errWrongArgs("CLUSTER|KEYSLOT")
+		}
+		return int64(hashSlot(args[2]))
+	}
+	return redisError("ERR unknown subcommand '" + args[1] + "'")
+}
+
+// slots describes each range of consecutive slots owned by one server as
+// start, end and the host, port and ID of the server.
+func (cl *Cluster) slots() []interface{} {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	ranges := []interface{}{}
+	for start := 0; start < slotCount; {
+		owner, end := cl.owners[start], start
+		for end+1 < slotCount && cl.owners[end+1] == owner {
+			end++
+		}
+		if owner != nil {
+			host, port, _ := net.SplitHostPort(owner.Addr())
+			p, _ := strconv.ParseInt(port, 10, 64)
+			id := fmt.Sprintf("%x", sha1.Sum([]byte(owner.Addr())))
+			ranges = append(ranges, []interface{}{int64(start), int64(end), []interface{}{host, p, id}})
+		}
+		start = end + 1
+	}
+	return ranges
+}
+
+// publish delivers a message to the subscribers of every server, as
+// cluster nodes propagate PUBLISH over the bus.
+func (cl *Cluster) publish(args []string) interface{} {
+	cl.mu.Lock()
+	servers := make(map[*Server]bool)
+	for _, s := range cl.owners {
+		if s != nil {
+			servers[s] = true
+		}
+	}
+	for _, s := range cl.importing {
+		servers[s] = true
+	}
+	cl.mu.Unlock()
+	var n int64
+	for s := range servers {
+		if r, ok := s.dbs[0].exec(nil, args).(int64); ok {
+			n += r
+		}
+	}
+	return n
+}
+
+// Sentinel is the state of a fake Redis Sentinel. A server with Sentinel
+// set answers SENTINEL get-master-addr-by-name with the masters registered
+// by SetMaster.
+type Sentinel struct {
+	mu      sync.Mutex
+	masters map[string]string
+}
+
+// SetMaster reports addr as the master monitored under name, as after a
+// failover.
+func (st *Sentinel) SetMaster(name, addr string) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.masters == nil {
+		st.masters = make(map[string]string)
+	}
+	st.masters[name] = addr
+}
+
+func (st *Sentinel) command(args []string) interface{} {
+	if len(args) < 2 {
+		return errWrongArgs("SENTINEL")
+	}
+	if !strings.EqualFold(args[1], "get-master-addr-by-name") {
+		return redisError("ERR unknown subcommand '" + args[1] + "'")
+	}
+	if len(args) != 3 {
+		return errWrongArgs("SENTINEL|GET-MASTER-ADDR-BY-NAME")
+	}
+	st.mu.Lock()
+	addr, ok := st.masters[args[2]]
+	st.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	host, port, _ := net.SplitHostPort(addr)
+	return []interface{}{host, port}
+}
@@ -1,7 +1,19 @@
 package attribute
 
-type KeyValue struct{}
+import "strconv"
 
-func String(k, v string) KeyValue { return KeyValue{} }
-func Int(k string, v int) KeyValue { return KeyValue{} }
-func Bool(k string, v bool) KeyValue { return KeyValue{} }
+type Key string
+
+type Value struct{ s string }
+
+// Emit returns the value formatted as a string.
+func (v Value) Emit() string { return v.s }
+
+type KeyValue struct {
+    Key   Key
+    Value Value
+}
+
+func String(k, v string) KeyValue { return KeyValue{Key: Key(k), Value: Value{s: v}} }
+func Int(k string, v int) KeyValue { return KeyValue{Key: Key(k), Value: Value{s: strconv.Itoa(v)}} }
+func Bool(k string, v bool) KeyValue { return KeyValue{Key: Key(k), Value: Value{s: strconv.FormatBool(v)}} }
@@ -24,3 +24,25 @@ func (c Int64Counter) Add(ctx context.Context, value int64, opts ...interface{})
 func (h Float64Histogram) Record(ctx context.Context, value float64, opts ...interface{}) {}
 
 func WithAttributes(attrs ...attribute.KeyValue) interface{} { return nil }
+
+type Int64ObservableGauge struct{}
+
+type Int64ObservableCounter struct{}
+
+// Int64Observer records the value of an observable instrument.
+type Int64Observer interface {
+    Observe(value int64, opts ...interface{})
+}
+
+// Int64Callback reports observable values each time metrics are collected.
+type Int64Callback func(ctx context.Context, o Int64Observer) error
+
+func WithInt64Callback(callback Int64Callback) interface{} { return nil }
+
+func (m Meter) Int64ObservableGauge(name string, opts ...interface{}) (Int64ObservableGauge, error) {
+    return Int64ObservableGauge{}, nil
+}
+
+func (m Meter) Int64ObservableCounter(name string, opts ...interface{}) (Int64ObservableCounter, error) {
+    return Int64ObservableCounter{}, nil
+}
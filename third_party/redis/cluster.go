@@ -0,0 +1,533 @@
+package redis
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ClusterOptions configure a client of a Redis Cluster. The fields shared
+// with Options apply to the connections of every node.
+type ClusterOptions struct {
+	// Addrs are the seed nodes the slot table is first loaded from.
+	Addrs []string
+
+	// MaxRedirects bounds the MOVED and ASK redirections followed, and the
+	// retries after a node failed, for one command. It defaults to 3 and
+	// -1 disables them.
+	MaxRedirects int
+
+	Username  string
+	Password  string
+	TLSConfig *tls.Config
+	Dialer    func(ctx context.Context, network, addr string) (net.Conn, error)
+	Protocol  int
+
+	DialTimeout     time.Duration
+	ReadTimeout     time.Duration
+	WriteTimeout    time.Duration
+	PoolSize        int
+	PoolTimeout     time.Duration
+	MinIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+}
+
+func (opt *ClusterOptions) clientOptions(addr string) *Options {
+	return &Options{
+		Addr:            addr,
+		Username:        opt.Username,
+		Password:        opt.Password,
+		TLSConfig:       opt.TLSConfig,
+		Dialer:          opt.Dialer,
+		Protocol:        opt.Protocol,
+		DialTimeout:     opt.DialTimeout,
+		ReadTimeout:     opt.ReadTimeout,
+		WriteTimeout:    opt.WriteTimeout,
+		PoolSize:        opt.PoolSize,
+		PoolTimeout:     opt.PoolTimeout,
+		MinIdleConns:    opt.MinIdleConns,
+		ConnMaxLifetime: opt.ConnMaxLifetime,
+		ConnMaxIdleTime: opt.ConnMaxIdleTime,
+	}
+}
+
+// ClusterClient routes every command to the node serving the slot of its
+// keys, with a Client per node. The slot table is loaded with CLUSTER
+// SLOTS and reloaded after a MOVED redirection or a node failure, which is
+// how a failover is discovered; ASK redirections during a slot migration
+// are followed for the command at hand only.
+//
+// Multi-key commands must keep to one slot, as the server rejects others
+// with CROSSSLOT. Pipelines are split by node and transactions by slot.
+type ClusterClient struct {
+	cmdable
+	opt *ClusterOptions
+
+	mu    sync.Mutex
+	nodes map[string]*Client
+	// slots maps each slot to its node; masters lists the nodes serving
+	// slots. Both are nil until the table is loaded.
+	slots   []*Client
+	masters []*Client
+
+	// stale asks for the slot table to be reloaded before the next
+	// command.
+	stale  atomic.Bool
+	closed atomic.Bool
+}
+
+func NewClusterClient(opts *ClusterOptions) *ClusterClient {
+	opt := *opts
+	switch opt.MaxRedirects {
+	case -1:
+		opt.MaxRedirects = 0
+	case 0:
+		opt.MaxRedirects = 3
+	}
+	c := &ClusterClient{opt: &opt, nodes: make(map[string]*Client)}
+	c.stale.Store(true)
+	c.cmdable = c.process
+	return c
+}
+
+// node returns the client of the node at addr, creating it on first use.
+func (c *ClusterClient) node(addr string) *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.nodeLocked(addr)
+}
+
+func (c *ClusterClient) nodeLocked(addr string) *Client {
+	n, ok := c.nodes[addr]
+	if !ok {
+		n = NewClient(c.opt.clientOptions(addr))
+		c.nodes[addr] = n
+	}
+	return n
+}
+
+// reload loads the slot table from the first node answering CLUSTER
+// SLOTS, trying the nodes serving slots before the seeds.
+func (c *ClusterClient) reload(ctx context.Context) error {
+	c.mu.Lock()
+	var addrs []string
+	for _, n := range c.masters {
+		addrs = append(addrs, n.opt.Addr)
+	}
+	c.mu.Unlock()
+	var errs []error
+	for _, addr := range append(addrs, c.opt.Addrs...) {
+		cmd := NewSliceCmd(ctx, "cluster", "slots")
+		_ = c.node(addr).process(ctx, cmd)
+		ranges, err := cmd.Result()
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		c.mu.Lock()
+		c.slots, c.masters = make([]*Client, slotCount), nil
+		for _, r := range ranges {
+			node := c.slotRangeLocked(r, addr)
+			if node != nil && !containsNode(c.masters, node) {
+				c.masters = append(c.masters, node)
+			}
+		}
+		c.mu.Unlock()
+		return nil
+	}
+	return fmt.Errorf("redis: cannot load cluster slots: %w", errors.Join(errs...))
+}
+
+// slotRangeLocked assigns one range of a CLUSTER SLOTS reply, sent by the
+// node at from, to its master and returns it.
+func (c *ClusterClient) slotRangeLocked(r interface{}, from string) *Client {
+	fields, ok := r.([]interface{})
+	if !ok || len(fields) < 3 {
+		return nil
+	}
+	master, ok := fields[2].([]interface{})
+	if !ok || len(master) < 2 {
+		return nil
+	}
+	start, err1 := toInt64(fields[0], nil)
+	end, err2 := toInt64(fields[1], nil)
+	host, err3 := toString(master[0], nil)
+	port, err4 := toInt64(master[1], nil)
+	if err := errors.Join(err1, err2, err3, err4); err != nil || start < 0 || end >= slotCount {
+		return nil
+	}
+	if host == "" {
+		// An unknown endpoint means the node that sent the reply.
+		host, _, _ = net.SplitHostPort(from)
+	}
+	node := c.nodeLocked(net.JoinHostPort(host, strconv.FormatInt(port, 10)))
+	for slot := start; slot <= end; slot++ {
+		c.slots[slot] = node
+	}
+	return node
+}
+
+func containsNode(nodes []*Client, n *Client) bool {
+	for _, m := range nodes {
+		if m == n {
+			return true
+		}
+	}
+	return false
+}
+
+// slotNode returns the node serving slot, reloading a stale slot table
+// first. Commands without keys, and slots missing from the table, go to any
+// node that serves slots, or to the first seed before the table is loaded.
+func (c *ClusterClient) slotNode(ctx context.Context, slot int) (*Client, error) {
+	if c.stale.CompareAndSwap(true, false) {
+		if err := c.reload(ctx); err != nil {
+			c.stale.Store(true)
+			return nil, err
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch {
+	case slot >= 0 && c.slots != nil && c.slots[slot] != nil:
+		return c.slots[slot], nil
+	case len(c.masters) > 0:
+		return c.masters[rand.IntN(len(c.masters))], nil
+	case len(c.opt.Addrs) > 0:
+		return c.nodeLocked(c.opt.Addrs[0]), nil
+	}
+	return nil, errors.New("redis: cluster has no nodes")
+}
+
+// redirect returns the node named by a MOVED or ASK error, and whether it
+// was ASK. MOVED also updates the slot and schedules a reload of the table,
+// since other slots have likely moved with it.
+func (c *ClusterClient) redirect(err error) (node *Client, ask bool) {
+	var rerr RedisError
+	if !errors.As(err, &rerr) {
+		return nil, false
+	}
+	f := strings.Fields(string(rerr))
+	if len(f) != 3 || (f[0] != "MOVED" && f[0] != "ASK") {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	node = c.nodeLocked(f[2])
+	if f[0] == "ASK" {
+		return node, true
+	}
+	if slot, err := strconv.Atoi(f[1]); err == nil && slot >= 0 && slot < slotCount && c.slots != nil {
+		c.slots[slot] = node
+	}
+	c.stale.Store(true)
+	return node, false
+}
+
+// retryBackoff is the wait before retrying after a failed node, giving a
+// failover time to complete.
+func retryBackoff(attempt int) time.Duration {
+	return min(8*time.Millisecond<<attempt, 512*time.Millisecond)
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+func (c *ClusterClient) process(ctx context.Context, cmd Cmder) error {
+	if c.closed.Load() {
+		cmd.SetErr(ErrClosed)
+		return ErrClosed
+	}
+	slot := cmdSlot(cmd)
+	var node *Client
+	ask := false
+	for attempt := 0; ; attempt++ {
+		var err error
+		if node == nil {
+			node, err = c.slotNode(ctx, slot)
+		}
+		switch {
+		case err != nil:
+			cmd.SetErr(err)
+		case ask:
+			_ = node.pipelineExec(ctx, []Cmder{NewStatusCmd(ctx, "asking"), cmd})
+		default:
+			_ = node.process(ctx, cmd)
+		}
+		err = cmd.Err()
+		if err == nil || attempt >= c.opt.MaxRedirects {
+			return err
+		}
+		if node, ask = c.redirect(err); node == nil {
+			if !retryable(err) {
+				return err
+			}
+			c.stale.Store(true)
+			if sleep(ctx, retryBackoff(attempt)) != nil {
+				return err
+			}
+		}
+		cmd.SetErr(nil)
+	}
+}
+
+// pipelineExec sends the commands of each node in one batch, the nodes
+// concurrently, then retries the redirected commands and those that hit a
+// failed node one at a time.
+func (c *ClusterClient) pipelineExec(ctx context.Context, cmds []Cmder) error {
+	if c.closed.Load() {
+		setCmdsErr(cmds, ErrClosed)
+		return ErrClosed
+	}
+	groups := make(map[*Client][]Cmder)
+	for _, cmd := range cmds {
+		node, err := c.slotNode(ctx, cmdSlot(cmd))
+		if err != nil {
+			cmd.SetErr(err)
+			continue
+		}
+		groups[node] = append(groups[node], cmd)
+	}
+	var wg sync.WaitGroup
+	for node, group := range groups {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = node.pipelineExec(ctx, group)
+		}()
+	}
+	wg.Wait()
+	for _, cmd := range cmds {
+		err := cmd.Err()
+		if node, _ := c.redirect(err); node != nil || retryable(err) {
+			cmd.SetErr(nil)
+			_ = c.process(ctx, cmd)
+		}
+	}
+	return nil
+}
+
+// txPipelineExec runs one transaction per slot the commands touch, in the
+// order the slots first appear. Each transaction is atomic on its own but
+// not with the others.
+func (c *ClusterClient) txPipelineExec(ctx context.Context, cmds []Cmder) error {
+	if c.closed.Load() {
+		setCmdsErr(cmds, ErrClosed)
+		return ErrClosed
+	}
+	var order []int
+	groups := make(map[int][]Cmder)
+	for _, cmd := range cmds {
+		slot := cmdSlot(cmd)
+		if _, ok := groups[slot]; !ok {
+			order = append(order, slot)
+		}
+		groups[slot] = append(groups[slot], cmd)
+	}
+	var first error
+	for _, slot := range order {
+		if err := c.txSlot(ctx, slot, groups[slot]); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// txSlot runs a transaction on the node serving slot. A redirection or a
+// failed node aborts it before any command runs, so it is retried whole:
+// on the new owner after MOVED, and preceded by ASKING on the importing
+// node after ASK.
+func (c *ClusterClient) txSlot(ctx context.Context, slot int, cmds []Cmder) error {
+	var node *Client
+	ask := false
+	for attempt := 0; ; attempt++ {
+		var err error
+		if node == nil {
+			node, err = c.slotNode(ctx, slot)
+		}
+		switch {
+		case err != nil:
+			setCmdsErr(cmds, err)
+		case ask:
+			err = node.askingTx(ctx, cmds)
+		default:
+			err = node.txPipelineExec(ctx, cmds)
+		}
+		if err == nil || attempt >= c.opt.MaxRedirects {
+			return err
+		}
+		// The cause of an EXECABORT is on the command that failed to queue.
+		cause := firstCmdsErr(cmds)
+		if cause == nil {
+			cause = err
+		}
+		if node, ask = c.redirect(cause); node == nil {
+			if !retryable(cause) {
+				return err
+			}
+			c.stale.Store(true)
+			if sleep(ctx, retryBackoff(attempt)) != nil {
+				return err
+			}
+		}
+		setCmdsErr(cmds, nil)
+	}
+}
+
+// askingTx runs a transaction after ASKING, which lets a node importing a
+// slot serve it for the whole transaction.
+func (c *Client) askingTx(ctx context.Context, cmds []Cmder) error {
+	return c.pool.withConn(ctx, cmds, func(cn *conn) error {
+		if err := cn.roundTrip(ctx, NewStatusCmd(ctx, "asking")); err != nil {
+			setCmdsErr(cmds, err)
+			return err
+		}
+		return cn.txRoundTrip(ctx, cmds)
+	})
+}
+
+// Watch runs fn in a transaction on the node serving keys, which must all
+// hash to one slot. It is run again on the new owner when the slot moved.
+func (c *ClusterClient) Watch(ctx context.Context, fn func(*Tx) error, keys ...string) error {
+	if len(keys) == 0 {
+		return errors.New("redis: Watch requires at least one key")
+	}
+	slot := hashSlot(keys[0])
+	for _, key := range keys[1:] {
+		if hashSlot(key) != slot {
+			return errors.New("redis: Watch requires all keys to be in the same slot")
+		}
+	}
+	for attempt := 0; ; attempt++ {
+		node, err := c.slotNode(ctx, slot)
+		if err == nil {
+			err = node.Watch(ctx, fn, keys...)
+		}
+		if err == nil || attempt >= c.opt.MaxRedirects {
+			return err
+		}
+		if moved, _ := c.redirect(err); moved == nil {
+			if !retryable(err) {
+				return err
+			}
+			c.stale.Store(true)
+			if sleep(ctx, retryBackoff(attempt)) != nil {
+				return err
+			}
+		}
+	}
+}
+
+// ForEachMaster calls fn concurrently with the client of each node serving
+// slots and returns the first error.
+func (c *ClusterClient) ForEachMaster(ctx context.Context, fn func(ctx context.Context, client *Client) error) error {
+	if _, err := c.slotNode(ctx, -1); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	masters := c.masters
+	c.mu.Unlock()
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		first error
+	)
+	for _, node := range masters {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := fn(ctx, node); err != nil {
+				mu.Lock()
+				if first == nil {
+					first = err
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return first
+}
+
+// Subscribe subscribes on the node serving the slot of the first channel.
+// The node is looked up again whenever the subscription reconnects.
+func (c *ClusterClient) Subscribe(ctx context.Context, channels ...string) *PubSub {
+	slot := -1
+	if len(channels) > 0 {
+		slot = hashSlot(channels[0])
+	}
+	return newNetPubSub(func(ctx context.Context) (*conn, error) {
+		node, err := c.slotNode(ctx, slot)
+		if err != nil {
+			return nil, err
+		}
+		cn, err := node.dialConn(ctx)
+		if err != nil {
+			c.stale.Store(true)
+		}
+		return cn, err
+	}, channels)
+}
+
+// PoolStats sums the pool statistics of every node.
+func (c *ClusterClient) PoolStats() *PoolStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var total PoolStats
+	for _, n := range c.nodes {
+		s := n.PoolStats()
+		total.Hits += s.Hits
+		total.Misses += s.Misses
+		total.Timeouts += s.Timeouts
+		total.WaitCount += s.WaitCount
+		total.WaitDurationNs += s.WaitDurationNs
+		total.TotalConns += s.TotalConns
+		total.IdleConns += s.IdleConns
+		total.StaleConns += s.StaleConns
+	}
+	return &total
+}
+
+// Close closes the clients of every node.
+func (c *ClusterClient) Close() error {
+	c.closed.Store(true)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, n := range c.nodes {
+		n.Close()
+	}
+	return nil
+}
+
+func (c *ClusterClient) Pipeline() Pipeliner {
+	return newPipeline(c.pipelineExec)
+}
+
+func (c *ClusterClient) Pipelined(ctx context.Context, fn func(Pipeliner) error) ([]Cmder, error) {
+	return newPipeline(c.pipelineExec).Pipelined(ctx, fn)
+}
+
+func (c *ClusterClient) TxPipeline() Pipeliner {
+	return newPipeline(c.txPipelineExec)
+}
+
+func (c *ClusterClient) TxPipelined(ctx context.Context, fn func(Pipeliner) error) ([]Cmder, error) {
+	return newPipeline(c.txPipelineExec).Pipelined(ctx, fn)
+}
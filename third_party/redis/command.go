@@ -0,0 +1,517 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cmder is implemented by every command.
+type Cmder interface {
+	Name() string
+	Args() []interface{}
+	Err() error
+	SetErr(error)
+
+	setReply(v interface{})
+}
+
+type baseCmd struct {
+	ctx  context.Context
+	args []interface{}
+	err  error
+}
+
+func (c *baseCmd) Name() string {
+	if len(c.args) == 0 {
+		return ""
+	}
+	return strings.ToLower(fmt.Sprint(c.args[0]))
+}
+
+func (c *baseCmd) Args() []interface{} { return c.args }
+func (c *baseCmd) Err() error          { return c.err }
+func (c *baseCmd) SetErr(err error)    { c.err = err }
+
+// replyErr records reply errors and nil replies, reporting whether the
+// reply carries a value.
+func (c *baseCmd) replyErr(v interface{}) bool {
+	switch v := v.(type) {
+	case nil:
+		c.err = Nil
+		return false
+	case error:
+		c.err = v
+		return false
+	}
+	return true
+}
+
+// Cmd is a command with an untyped reply, as returned by Do.
+type Cmd struct {
+	baseCmd
+	val interface{}
+}
+
+func NewCmd(ctx context.Context, args ...interface{}) *Cmd {
+	return &Cmd{baseCmd: baseCmd{ctx: ctx, args: args}}
+}
+
+func (c *Cmd) setReply(v interface{}) {
+	if c.replyErr(v) {
+		c.val = v
+	}
+}
+
+func (c *Cmd) Val() interface{}               { return c.val }
+func (c *Cmd) Result() (interface{}, error)   { return c.val, c.err }
+func (c *Cmd) Text() (string, error)          { return toString(c.val, c.err) }
+func (c *Cmd) Int64() (int64, error)          { return toInt64(c.val, c.err) }
+func (c *Cmd) Slice() ([]interface{}, error)  { s, _ := c.val.([]interface{}); return s, c.err }
+func (c *Cmd) StringSlice() ([]string, error) { return toStrings(c.val, c.err) }
+
+type StatusCmd struct {
+	baseCmd
+	val string
+}
+
+func NewStatusCmd(ctx context.Context, args ...interface{}) *StatusCmd {
+	return &StatusCmd{baseCmd: baseCmd{ctx: ctx, args: args}}
+}
+
+func (c *StatusCmd) setReply(v interface{}) {
+	if c.replyErr(v) {
+		c.val, c.err = toString(v, nil)
+	}
+}
+
+func (c *StatusCmd) Val() string             { return c.val }
+func (c *StatusCmd) Result() (string, error) { return c.val, c.err }
+
+type StringCmd struct {
+	baseCmd
+	val string
+}
+
+func NewStringCmd(ctx context.Context, args ...interface{}) *StringCmd {
+	return &StringCmd{baseCmd: baseCmd{ctx: ctx, args: args}}
+}
+
+func (c *StringCmd) setReply(v interface{}) {
+	if c.replyErr(v) {
+		c.val, c.err = toString(v, nil)
+	}
+}
+
+func (c *StringCmd) Val() string             { return c.val }
+func (c *StringCmd) Result() (string, error) { return c.val, c.err }
+
+func (c *StringCmd) Bytes() ([]byte, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	return []byte(c.val), nil
+}
+
+func (c *StringCmd) Int64() (int64, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	return strconv.ParseInt(c.val, 10, 64)
+}
+
+func (c *StringCmd) Float64() (float64, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	return strconv.ParseFloat(c.val, 64)
+}
+
+type IntCmd struct {
+	baseCmd
+	val int64
+}
+
+func NewIntCmd(ctx context.Context, args ...interface{}) *IntCmd {
+	return &IntCmd{baseCmd: baseCmd{ctx: ctx, args: args}}
+}
+
+func (c *IntCmd) setReply(v interface{}) {
+	if c.replyErr(v) {
+		c.val, c.err = toInt64(v, nil)
+	}
+}
+
+func (c *IntCmd) Val() int64             { return c.val }
+func (c *IntCmd) Result() (int64, error) { return c.val, c.err }
+
+type FloatCmd struct {
+	baseCmd
+	val float64
+}
+
+func NewFloatCmd(ctx context.Context, args ...interface{}) *FloatCmd {
+	return &FloatCmd{baseCmd: baseCmd{ctx: ctx, args: args}}
+}
+
+func (c *FloatCmd) setReply(v interface{}) {
+	if c.replyErr(v) {
+		c.val, c.err = toFloat64(v, nil)
+	}
+}
+
+func (c *FloatCmd) Val() float64             { return c.val }
+func (c *FloatCmd) Result() (float64, error) { return c.val, c.err }
+
+// BoolCmd treats OK and non-zero integers as true and nil replies as false.
+type BoolCmd struct {
+	baseCmd
+	val bool
+}
+
+func NewBoolCmd(ctx context.Context, args ...interface{}) *BoolCmd {
+	return &BoolCmd{baseCmd: baseCmd{ctx: ctx, args: args}}
+}
+
+func (c *BoolCmd) setReply(v interface{}) {
+	if v == nil {
+		c.val = false
+		return
+	}
+	if !c.replyErr(v) {
+		return
+	}
+	switch v := v.(type) {
+	case int64:
+		c.val = v != 0
+	case string:
+		c.val = v == "OK"
+	default:
+		c.err = fmt.Errorf("redis: unexpected reply %T for bool", v)
+	}
+}
+
+func (c *BoolCmd) Val() bool             { return c.val }
+func (c *BoolCmd) Result() (bool, error) { return c.val, c.err }
+
+type DurationCmd struct {
+	baseCmd
+	val       time.Duration
+	precision time.Duration
+}
+
+func NewDurationCmd(ctx context.Context, precision time.Duration, args ...interface{}) *DurationCmd {
+	return &DurationCmd{baseCmd: baseCmd{ctx: ctx, args: args}, precision: precision}
+}
+
+// setReply keeps the negative -1 and -2 markers as raw durations, like
+// go-redis does.
+func (c *DurationCmd) setReply(v interface{}) {
+	if !c.replyErr(v) {
+		return
+	}
+	n, err := toInt64(v, nil)
+	if err != nil {
+		c.err = err
+		return
+	}
+	if n < 0 {
+		c.val = time.Duration(n)
+		return
+	}
+	c.val = time.Duration(n) * c.precision
+}
+
+func (c *DurationCmd) Val() time.Duration             { return c.val }
+func (c *DurationCmd) Result() (time.Duration, error) { return c.val, c.err }
+
+// SliceCmd holds array replies whose nil elements stay nil, as MGET returns.
+type SliceCmd struct {
+	baseCmd
+	val []interface{}
+}
+
+func NewSliceCmd(ctx context.Context, args ...interface{}) *SliceCmd {
+	return &SliceCmd{baseCmd: baseCmd{ctx: ctx, args: args}}
+}
+
+func (c *SliceCmd) setReply(v interface{}) {
+	if !c.replyErr(v) {
+		return
+	}
+	arr, ok := v.([]interface{})
+	if !ok {
+		c.err = fmt.Errorf("redis: unexpected reply %T for slice", v)
+		return
+	}
+	c.val = arr
+}
+
+func (c *SliceCmd) Val() []interface{}             { return c.val }
+func (c *SliceCmd) Result() ([]interface{}, error) { return c.val, c.err }
+
+type StringSliceCmd struct {
+	baseCmd
+	val []string
+}
+
+func NewStringSliceCmd(ctx context.Context, args ...interface{}) *StringSliceCmd {
+	return &StringSliceCmd{baseCmd: baseCmd{ctx: ctx, args: args}}
+}
+
+func (c *StringSliceCmd) setReply(v interface{}) {
+	if c.replyErr(v) {
+		c.val, c.err = toStrings(v, nil)
+	}
+}
+
+func (c *StringSliceCmd) Val() []string             { return c.val }
+func (c *StringSliceCmd) Result() ([]string, error) { return c.val, c.err }
+
+type MapStringStringCmd struct {
+	baseCmd
+	val map[string]string
+}
+
+func NewMapStringStringCmd(ctx context.Context, args ...interface{}) *MapStringStringCmd {
+	return &MapStringStringCmd{baseCmd: baseCmd{ctx: ctx, args: args}}
+}
+
+func (c *MapStringStringCmd) setReply(v interface{}) {
+	if !c.replyErr(v) {
+		return
+	}
+	var pairs []string
+	if pairs, c.err = toStrings(v, nil); c.err != nil {
+		return
+	}
+	c.val = make(map[string]string, len(pairs)/2)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		c.val[pairs[i]] = pairs[i+1]
+	}
+}
+
+func (c *MapStringStringCmd) Val() map[string]string             { return c.val }
+func (c *MapStringStringCmd) Result() (map[string]string, error) { return c.val, c.err }
+
+// ZSliceCmd holds members and scores from a WITHSCORES reply, which is
+// flat in RESP2 and an array of member, score pairs in RESP3.
+type ZSliceCmd struct {
+	baseCmd
+	val []Z
+}
+
+func NewZSliceCmd(ctx context.Context, args ...interface{}) *ZSliceCmd {
+	return &ZSliceCmd{baseCmd: baseCmd{ctx: ctx, args: args}}
+}
+
+func (c *ZSliceCmd) setReply(v interface{}) {
+	if !c.replyErr(v) {
+		return
+	}
+	if arr, ok := v.([]interface{}); ok {
+		var flat []interface{}
+		for _, el := range arr {
+			if pair, ok := el.([]interface{}); ok {
+				flat = append(flat, pair...)
+			} else {
+				flat = append(flat, el)
+			}
+		}
+		v = flat
+	}
+	var pairs []string
+	if pairs, c.err = toStrings(v, nil); c.err != nil {
+		return
+	}
+	c.val = make([]Z, 0, len(pairs)/2)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		score, err := toFloat64(pairs[i+1], nil)
+		if err != nil {
+			c.err = err
+			return
+		}
+		c.val = append(c.val, Z{Score: score, Member: pairs[i]})
+	}
+}
+
+func (c *ZSliceCmd) Val() []Z             { return c.val }
+func (c *ZSliceCmd) Result() ([]Z, error) { return c.val, c.err }
+
+func toString(v interface{}, err error) (string, error) {
+	if err != nil {
+		return "", err
+	}
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case []byte:
+		return string(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	}
+	return "", fmt.Errorf("redis: unexpected reply %T for string", v)
+}
+
+func toInt64(v interface{}, err error) (int64, error) {
+	if err != nil {
+		return 0, err
+	}
+	switch v := v.(type) {
+	case int64:
+		return v, nil
+	case string:
+		return strconv.ParseInt(v, 10, 64)
+	}
+	return 0, fmt.Errorf("redis: unexpected reply %T for int", v)
+}
+
+func toFloat64(v interface{}, err error) (float64, error) {
+	if err != nil {
+		return 0, err
+	}
+	switch v := v.(type) {
+	case float64:
+		return v, nil
+	case int64:
+		return float64(v), nil
+	case string:
+		return strconv.ParseFloat(v, 64)
+	}
+	return 0, fmt.Errorf("redis: unexpected reply %T for float", v)
+}
+
+func toStrings(v interface{}, err error) ([]string, error) {
+	if err != nil {
+		return nil, err
+	}
+	arr, ok := v.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("redis: unexpected reply %T for string slice", v)
+	}
+	out := make([]string, len(arr))
+	for i, el := range arr {
+		s, err := toString(el, nil)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = s
+	}
+	return out, nil
+}
+
+func stringArgs(args []interface{}) []string {
+	out := make([]string, len(args))
+	for i, a := range args {
+		out[i] = formatArg(a)
+	}
+	return out
+}
+
+// formatArg renders a command argument the way go-redis writes it.
+func formatArg(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case uint64:
+		return strconv.FormatUint(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		if v {
+			return "1"
+		}
+		return "0"
+	case time.Duration:
+		return strconv.FormatInt(int64(v), 10)
+	case nil:
+		return ""
+	}
+	return fmt.Sprint(v)
+}
+
+// ScanCmd holds a page of keys and the cursor of the next page.
+type ScanCmd struct {
+	baseCmd
+	page   []string
+	cursor uint64
+	// process re-runs SCAN for the iterator.
+	process cmdable
+}
+
+func NewScanCmd(ctx context.Context, process cmdable, args ...interface{}) *ScanCmd {
+	return &ScanCmd{baseCmd: baseCmd{ctx: ctx, args: args}, process: process}
+}
+
+func (c *ScanCmd) setReply(v interface{}) {
+	if !c.replyErr(v) {
+		return
+	}
+	arr, ok := v.([]interface{})
+	if !ok || len(arr) != 2 {
+		c.err = fmt.Errorf("redis: unexpected reply %T for scan", v)
+		return
+	}
+	cursor, err := toString(arr[0], nil)
+	if err == nil {
+		c.cursor, err = strconv.ParseUint(cursor, 10, 64)
+	}
+	if err == nil {
+		c.page, err = toStrings(arr[1], nil)
+	}
+	c.err = err
+}
+
+func (c *ScanCmd) Val() (keys []string, cursor uint64)               { return c.page, c.cursor }
+func (c *ScanCmd) Result() (keys []string, cursor uint64, err error) { return c.page, c.cursor, c.err }
+
+// Iterator walks all pages of the scan.
+func (c *ScanCmd) Iterator() *ScanIterator {
+	return &ScanIterator{cmd: c}
+}
+
+// ScanIterator yields keys across SCAN pages.
+type ScanIterator struct {
+	cmd *ScanCmd
+	pos int
+}
+
+func (it *ScanIterator) Err() error { return it.cmd.Err() }
+
+// Next advances the iterator, fetching the next page when needed.
+func (it *ScanIterator) Next(ctx context.Context) bool {
+	for {
+		if it.cmd.Err() != nil {
+			return false
+		}
+		if it.pos < len(it.cmd.page) {
+			it.pos++
+			return true
+		}
+		if it.cmd.cursor == 0 {
+			return false
+		}
+		args := append([]interface{}(nil), it.cmd.args...)
+		args[1] = it.cmd.cursor
+		next := NewScanCmd(ctx, it.cmd.process, args...)
+		_ = it.cmd.process(ctx, next)
+		it.cmd, it.pos = next, 0
+	}
+}
+
+func (it *ScanIterator) Val() string {
+	if it.pos == 0 {
+		return ""
+	}
+	return it.cmd.page[it.pos-1]
+}
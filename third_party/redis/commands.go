@@ -0,0 +1,540 @@
+package redis
+
+import (
+	"context"
+	"time"
+)
+
+// KeepTTL retains the existing TTL of a key when passed as expiration.
+const KeepTTL = -1
+
+// Cmdable lists the commands supported by clients, transactions and
+// pipelines.
+type Cmdable interface {
+	Do(ctx context.Context, args ...interface{}) *Cmd
+	Ping(ctx context.Context) *StatusCmd
+	Get(ctx context.Context, key string) *StringCmd
+	GetRange(ctx context.Context, key string, start, end int64) *StringCmd
+	Set(ctx context.Context, key string, value interface{}, expiration time.Duration) *StatusCmd
+	SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) *BoolCmd
+	SetXX(ctx context.Context, key string, value interface{}, expiration time.Duration) *BoolCmd
+	SetArgs(ctx context.Context, key string, value interface{}, a SetArgs) *StatusCmd
+	Del(ctx context.Context, keys ...string) *IntCmd
+	Exists(ctx context.Context, keys ...string) *IntCmd
+	MGet(ctx context.Context, keys ...string) *SliceCmd
+	MSet(ctx context.Context, values ...interface{}) *StatusCmd
+	Incr(ctx context.Context, key string) *IntCmd
+	Decr(ctx context.Context, key string) *IntCmd
+	IncrBy(ctx context.Context, key string, value int64) *IntCmd
+	DecrBy(ctx context.Context, key string, decrement int64) *IntCmd
+	IncrByFloat(ctx context.Context, key string, value float64) *FloatCmd
+	PTTL(ctx context.Context, key string) *DurationCmd
+	PExpire(ctx context.Context, key string, expiration time.Duration) *BoolCmd
+	Persist(ctx context.Context, key string) *BoolCmd
+	Touch(ctx context.Context, keys ...string) *IntCmd
+	Scan(ctx context.Context, cursor uint64, match string, count int64) *ScanCmd
+	Unlink(ctx context.Context, keys ...string) *IntCmd
+	Publish(ctx context.Context, channel string, message interface{}) *IntCmd
+
+	PExpireAt(ctx context.Context, key string, tm time.Time) *BoolCmd
+
+	SAdd(ctx context.Context, key string, members ...interface{}) *IntCmd
+	SRem(ctx context.Context, key string, members ...interface{}) *IntCmd
+	SMembers(ctx context.Context, key string) *StringSliceCmd
+	SRandMemberN(ctx context.Context, key string, count int64) *StringSliceCmd
+	SCard(ctx context.Context, key string) *IntCmd
+
+	HSet(ctx context.Context, key string, values ...interface{}) *IntCmd
+	HGet(ctx context.Context, key, field string) *StringCmd
+	HGetAll(ctx context.Context, key string) *MapStringStringCmd
+	HDel(ctx context.Context, key string, fields ...string) *IntCmd
+	HIncrBy(ctx context.Context, key, field string, incr int64) *IntCmd
+
+	LPush(ctx context.Context, key string, values ...interface{}) *IntCmd
+	RPush(ctx context.Context, key string, values ...interface{}) *IntCmd
+	LPop(ctx context.Context, key string) *StringCmd
+	RPop(ctx context.Context, key string) *StringCmd
+	BLPop(ctx context.Context, timeout time.Duration, keys ...string) *StringSliceCmd
+	BRPop(ctx context.Context, timeout time.Duration, keys ...string) *StringSliceCmd
+	LRange(ctx context.Context, key string, start, stop int64) *StringSliceCmd
+	LTrim(ctx context.Context, key string, start, stop int64) *StatusCmd
+	LLen(ctx context.Context, key string) *IntCmd
+
+	ZAdd(ctx context.Context, key string, members ...Z) *IntCmd
+	ZRem(ctx context.Context, key string, members ...interface{}) *IntCmd
+	ZScore(ctx context.Context, key, member string) *FloatCmd
+	ZCard(ctx context.Context, key string) *IntCmd
+	ZRank(ctx context.Context, key, member string) *IntCmd
+	ZRangeWithScores(ctx context.Context, key string, start, stop int64) *ZSliceCmd
+	ZRangeByScore(ctx context.Context, key string, opt *ZRangeBy) *StringSliceCmd
+	ZRangeByScoreWithScores(ctx context.Context, key string, opt *ZRangeBy) *ZSliceCmd
+	ZRemRangeByScore(ctx context.Context, key, min, max string) *IntCmd
+}
+
+type cmdable func(ctx context.Context, cmd Cmder) error
+
+func (c cmdable) Do(ctx context.Context, args ...interface{}) *Cmd {
+	cmd := NewCmd(ctx, args...)
+	_ = c(ctx, cmd)
+	return cmd
+}
+
+func (c cmdable) Ping(ctx context.Context) *StatusCmd {
+	cmd := NewStatusCmd(ctx, "ping")
+	_ = c(ctx, cmd)
+	return cmd
+}
+
+func (c cmdable) Get(ctx context.Context, key string) *StringCmd {
+	cmd := NewStringCmd(ctx, "get", key)
+	_ = c(ctx, cmd)
+	return cmd
+}
+
+func (c cmdable) GetRange(ctx context.Context, key string, start, end int64) *StringCmd {
+	cmd := NewStringCmd(ctx, "getrange", key, start, end)
+	_ = c(ctx, cmd)
+	return cmd
+}
+
+// appendExpiration adds the expiration arguments used by SET.
+func appendExpiration(args []interface{}, expiration time.Duration) []interface{} {
+	if expiration > 0 {
+		return append(args, "px", int64(expiration/time.Millisecond))
+	}
+	if expiration == KeepTTL {
+		return append(args, "keepttl")
+	}
+	return args
+}
+
+func (c cmdable) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) *StatusCmd {
+	args := appendExpiration([]interface{}{"set", key, value}, expiration)
+	cmd := NewStatusCmd(ctx, args...)
+	_ = c(ctx, cmd)
+	return cmd
+}
+
+func (c cmdable) SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) *BoolCmd {
+	args := appendExpiration([]interface{}{"set", key, value}, expiration)
+	cmd := NewBoolCmd(ctx, append(args, "nx")...)
+	_ = c(ctx, cmd)
+	return cmd
+}
+
+func (c cmdable) SetXX(ctx context.Context, key string, value interface{}, expiration time.Duration) *BoolCmd {
+	args := appendExpiration([]interface{}{"set", key, value}, expiration)
+	cmd := NewBoolCmd(ctx, append(args, "xx")...)
+	_ = c(ctx, cmd)
+	return cmd
+}
+
+// SetArgs provides arguments for the SetArgs function.
+type SetArgs struct {
+	// Mode can be `NX` or `XX` or empty.
+	Mode string
+	// Zero `TTL` or `Expiration` means that the key has no expiration time.
+	TTL      time.Duration
+	ExpireAt time.Time
+	// When Get is true, the command returns the old value stored at key,
+	// or nil when key did not exist.
+	Get bool
+	// KeepTTL is a Redis KEEPTTL option to keep existing TTL.
+	KeepTTL bool
+}
+
+func (c cmdable) SetArgs(ctx context.Context, key string, value interface{}, a SetArgs) *StatusCmd {
+	args := []interface{}{"set", key, value}
+	if a.KeepTTL {
+		args = append(args, "keepttl")
+	}
+	switch {
+	case !a.ExpireAt.IsZero():
+		args = append(args, "pxat", a.ExpireAt.UnixMilli())
+	case a.TTL > 0:
+		args = append(args, "px", int64(a.TTL/time.Millisecond))
+	}
+	if a.Mode != "" {
+		args = append(args, a.Mode)
+	}
+	if a.Get {
+		args = append(args, "get")
+	}
+	cmd := NewStatusCmd(ctx, args...)
+	_ = c(ctx, cmd)
+	return cmd
+}
+
+func (c cmdable) Del(ctx context.Context, keys ...string) *IntCmd {
+	args := make([]interface{}, 1, 1+len(keys))
+	args[0] = "del"
+	for _, k := range keys {
+		args = append(args, k)
+	}
+	cmd := NewIntCmd(ctx, args...)
+	_ = c(ctx, cmd)
+	return cmd
+}
+
+func (c cmdable) Exists(ctx context.Context, keys ...string) *IntCmd {
+	args := make([]interface{}, 1, 1+len(keys))
+	args[0] = "exists"
+	for _, k := range keys {
+		args = append(args, k)
+	}
+	cmd := NewIntCmd(ctx, args...)
+	_ = c(ctx, cmd)
+	return cmd
+}
+
+func (c cmdable) MGet(ctx context.Context, keys ...string) *SliceCmd {
+	args := make([]interface{}, 1, 1+len(keys))
+	args[0] = "mget"
+	for _, k := range keys {
+		args = append(args, k)
+	}
+	cmd := NewSliceCmd(ctx, args...)
+	_ = c(ctx, cmd)
+	return cmd
+}
+
+// MSet accepts key/value pairs or a single map[string]interface{}.
+func (c cmdable) MSet(ctx context.Context, values ...interface{}) *StatusCmd {
+	args := []interface{}{"mset"}
+	if len(values) == 1 {
+		if m, ok := values[0].(map[string]interface{}); ok {
+			for k, v := range m {
+				args = append(args, k, v)
+			}
+			values = nil
+		}
+	}
+	args = append(args, values...)
+	cmd := NewStatusCmd(ctx, args...)
+	_ = c(ctx, cmd)
+	return cmd
+}
+
+func (c cmdable) Incr(ctx context.Context, key string) *IntCmd {
+	cmd := NewIntCmd(ctx, "incr", key)
+	_ = c(ctx, cmd)
+	return cmd
+}
+
+func (c cmdable) Decr(ctx context.Context, key string) *IntCmd {
+	cmd := NewIntCmd(ctx, "decr", key)
+	_ = c(ctx, cmd)
+	return cmd
+}
+
+func (c cmdable) IncrBy(ctx context.Context, key string, value int64) *IntCmd {
+	cmd := NewIntCmd(ctx, "incrby", key, value)
+	_ = c(ctx, cmd)
+	return cmd
+}
+
+func (c cmdable) DecrBy(ctx context.Context, key string, decrement int64) *IntCmd {
+	cmd := NewIntCmd(ctx, "decrby", key, decrement)
+	_ = c(ctx, cmd)
+	return cmd
+}
+
+func (c cmdable) IncrByFloat(ctx context.Context, key string, value float64) *FloatCmd {
+	cmd := NewFloatCmd(ctx, "incrbyfloat", key, value)
+	_ = c(ctx, cmd)
+	return cmd
+}
+
+func (c cmdable) PTTL(ctx context.Context, key string) *DurationCmd {
+	cmd := NewDurationCmd(ctx, time.Millisecond, "pttl", key)
+	_ = c(ctx, cmd)
+	return cmd
+}
+
+func (c cmdable) PExpire(ctx context.Context, key string, expiration time.Duration) *BoolCmd {
+	cmd := NewBoolCmd(ctx, "pexpire", key, int64(expiration/time.Millisecond))
+	_ = c(ctx, cmd)
+	return cmd
+}
+
+func (c cmdable) Persist(ctx context.Context, key string) *BoolCmd {
+	cmd := NewBoolCmd(ctx, "persist", key)
+	_ = c(ctx, cmd)
+	return cmd
+}
+
+func (c cmdable) Touch(ctx context.Context, keys ...string) *IntCmd {
+	args := make([]interface{}, 1, 1+len(keys))
+	args[0] = "touch"
+	for _, k := range keys {
+		args = append(args, k)
+	}
+	cmd := NewIntCmd(ctx, args...)
+	_ = c(ctx, cmd)
+	return cmd
+}
+
+func (c cmdable) Scan(ctx context.Context, cursor uint64, match string, count int64) *ScanCmd {
+	args := []interface{}{"scan", cursor}
+	if match != "" {
+		args = append(args, "match", match)
+	}
+	if count > 0 {
+		args = append(args, "count", count)
+	}
+	cmd := NewScanCmd(ctx, c, args...)
+	_ = c(ctx, cmd)
+	return cmd
+}
+
+func (c cmdable) Unlink(ctx context.Context, keys ...string) *IntCmd {
+	args := make([]interface{}, 1, 1+len(keys))
+	args[0] = "unlink"
+	for _, k := range keys {
+		args = append(args, k)
+	}
+	cmd := NewIntCmd(ctx, args...)
+	_ = c(ctx, cmd)
+	return cmd
+}
+
+func (c cmdable) Publish(ctx context.Context, channel string, message interface{}) *IntCmd {
+	cmd := NewIntCmd(ctx, "publish", channel, message)
+	_ = c(ctx, cmd)
+	return cmd
+}
+
+func (c cmdable) PExpireAt(ctx context.Context, key string, tm time.Time) *BoolCmd {
+	cmd := NewBoolCmd(ctx, "pexpireat", key, tm.UnixMilli())
+	_ = c(ctx, cmd)
+	return cmd
+}
+
+// keyArgs builds the arguments of a command taking a key and members.
+func keyArgs(name, key string, members []interface{}) []interface{} {
+	args := make([]interface{}, 2, 2+len(members))
+	args[0], args[1] = name, key
+	return append(args, members...)
+}
+
+// HSet accepts field-value pairs or a single map[string]interface{}.
+func (c cmdable) HSet(ctx context.Context, key string, values ...interface{}) *IntCmd {
+	if len(values) == 1 {
+		if m, ok := values[0].(map[string]interface{}); ok {
+			values = make([]interface{}, 0, 2*len(m))
+			for f, v := range m {
+				values = append(values, f, v)
+			}
+		}
+	}
+	cmd := NewIntCmd(ctx, keyArgs("hset", key, values)...)
+	_ = c(ctx, cmd)
+	return cmd
+}
+
+func (c cmdable) HGet(ctx context.Context, key, field string) *StringCmd {
+	cmd := NewStringCmd(ctx, "hget", key, field)
+	_ = c(ctx, cmd)
+	return cmd
+}
+
+func (c cmdable) HGetAll(ctx context.Context, key string) *MapStringStringCmd {
+	cmd := NewMapStringStringCmd(ctx, "hgetall", key)
+	_ = c(ctx, cmd)
+	return cmd
+}
+
+func (c cmdable) HDel(ctx context.Context, key string, fields ...string) *IntCmd {
+	args := make([]interface{}, 2, 2+len(fields))
+	args[0], args[1] = "hdel", key
+	for _, f := range fields {
+		args = append(args, f)
+	}
+	cmd := NewIntCmd(ctx, args...)
+	_ = c(ctx, cmd)
+	return cmd
+}
+
+func (c cmdable) HIncrBy(ctx context.Context, key, field string, incr int64) *IntCmd {
+	cmd := NewIntCmd(ctx, "hincrby", key, field, incr)
+	_ = c(ctx, cmd)
+	return cmd
+}
+
+func (c cmdable) LPush(ctx context.Context, key string, values ...interface{}) *IntCmd {
+	cmd := NewIntCmd(ctx, keyArgs("lpush", key, values)...)
+	_ = c(ctx, cmd)
+	return cmd
+}
+
+func (c cmdable) RPush(ctx context.Context, key string, values ...interface{}) *IntCmd {
+	cmd := NewIntCmd(ctx, keyArgs("rpush", key, values)...)
+	_ = c(ctx, cmd)
+	return cmd
+}
+
+func (c cmdable) LPop(ctx context.Context, key string) *StringCmd {
+	cmd := NewStringCmd(ctx, "lpop", key)
+	_ = c(ctx, cmd)
+	return cmd
+}
+
+func (c cmdable) RPop(ctx context.Context, key string) *StringCmd {
+	cmd := NewStringCmd(ctx, "rpop", key)
+	_ = c(ctx, cmd)
+	return cmd
+}
+
+// blockingPopArgs appends the timeout in whole seconds, truncated like
+// go-redis, where zero blocks indefinitely.
+func blockingPopArgs(name string, timeout time.Duration, keys []string) []interface{} {
+	args := make([]interface{}, 1, 2+len(keys))
+	args[0] = name
+	for _, k := range keys {
+		args = append(args, k)
+	}
+	return append(args, int64(timeout/time.Second))
+}
+
+func (c cmdable) BLPop(ctx context.Context, timeout time.Duration, keys ...string) *StringSliceCmd {
+	cmd := NewStringSliceCmd(ctx, blockingPopArgs("blpop", timeout, keys)...)
+	_ = c(ctx, cmd)
+	return cmd
+}
+
+func (c cmdable) BRPop(ctx context.Context, timeout time.Duration, keys ...string) *StringSliceCmd {
+	cmd := NewStringSliceCmd(ctx, blockingPopArgs("brpop", timeout, keys)...)
+	_ = c(ctx, cmd)
+	return cmd
+}
+
+func (c cmdable) LRange(ctx context.Context, key string, start, stop int64) *StringSliceCmd {
+	cmd := NewStringSliceCmd(ctx, "lrange", key, start, stop)
+	_ = c(ctx, cmd)
+	return cmd
+}
+
+func (c cmdable) LTrim(ctx context.Context, key string, start, stop int64) *StatusCmd {
+	cmd := NewStatusCmd(ctx, "ltrim", key, start, stop)
+	_ = c(ctx, cmd)
+	return cmd
+}
+
+func (c cmdable) LLen(ctx context.Context, key string) *IntCmd {
+	cmd := NewIntCmd(ctx, "llen", key)
+	_ = c(ctx, cmd)
+	return cmd
+}
+
+func (c cmdable) SAdd(ctx context.Context, key string, members ...interface{}) *IntCmd {
+	cmd := NewIntCmd(ctx, keyArgs("sadd", key, members)...)
+	_ = c(ctx, cmd)
+	return cmd
+}
+
+func (c cmdable) SRem(ctx context.Context, key string, members ...interface{}) *IntCmd {
+	cmd := NewIntCmd(ctx, keyArgs("srem", key, members)...)
+	_ = c(ctx, cmd)
+	return cmd
+}
+
+func (c cmdable) SMembers(ctx context.Context, key string) *StringSliceCmd {
+	cmd := NewStringSliceCmd(ctx, "smembers", key)
+	_ = c(ctx, cmd)
+	return cmd
+}
+
+func (c cmdable) SRandMemberN(ctx context.Context, key string, count int64) *StringSliceCmd {
+	cmd := NewStringSliceCmd(ctx, "srandmember", key, count)
+	_ = c(ctx, cmd)
+	return cmd
+}
+
+func (c cmdable) SCard(ctx context.Context, key string) *IntCmd {
+	cmd := NewIntCmd(ctx, "scard", key)
+	_ = c(ctx, cmd)
+	return cmd
+}
+
+// Z is a sorted set member with its score.
+type Z struct {
+	Score  float64
+	Member interface{}
+}
+
+// ZRangeBy selects a score range. Min and Max accept "-inf", "+inf" and a
+// "(" prefix for exclusive bounds; a zero Count means no limit.
+type ZRangeBy struct {
+	Min, Max      string
+	Offset, Count int64
+}
+
+func (c cmdable) ZAdd(ctx context.Context, key string, members ...Z) *IntCmd {
+	args := make([]interface{}, 2, 2+2*len(members))
+	args[0], args[1] = "zadd", key
+	for _, m := range members {
+		args = append(args, m.Score, m.Member)
+	}
+	cmd := NewIntCmd(ctx, args...)
+	_ = c(ctx, cmd)
+	return cmd
+}
+
+func (c cmdable) ZRem(ctx context.Context, key string, members ...interface{}) *IntCmd {
+	cmd := NewIntCmd(ctx, keyArgs("zrem", key, members)...)
+	_ = c(ctx, cmd)
+	return cmd
+}
+
+func (c cmdable) ZScore(ctx context.Context, key, member string) *FloatCmd {
+	cmd := NewFloatCmd(ctx, "zscore", key, member)
+	_ = c(ctx, cmd)
+	return cmd
+}
+
+func (c cmdable) ZCard(ctx context.Context, key string) *IntCmd {
+	cmd := NewIntCmd(ctx, "zcard", key)
+	_ = c(ctx, cmd)
+	return cmd
+}
+
+func (c cmdable) ZRank(ctx context.Context, key, member string) *IntCmd {
+	cmd := NewIntCmd(ctx, "zrank", key, member)
+	_ = c(ctx, cmd)
+	return cmd
+}
+
+func (c cmdable) ZRangeWithScores(ctx context.Context, key string, start, stop int64) *ZSliceCmd {
+	cmd := NewZSliceCmd(ctx, "zrange", key, start, stop, "withscores")
+	_ = c(ctx, cmd)
+	return cmd
+}
+
+func zRangeByScoreArgs(key string, opt *ZRangeBy, withScores bool) []interface{} {
+	args := []interface{}{"zrangebyscore", key, opt.Min, opt.Max}
+	if withScores {
+		args = append(args, "withscores")
+	}
+	if opt.Offset != 0 || opt.Count != 0 {
+		args = append(args, "limit", opt.Offset, opt.Count)
+	}
+	return args
+}
+
+func (c cmdable) ZRangeByScore(ctx context.Context, key string, opt *ZRangeBy) *StringSliceCmd {
+	cmd := NewStringSliceCmd(ctx, zRangeByScoreArgs(key, opt, false)...)
+	_ = c(ctx, cmd)
+	return cmd
+}
+
+func (c cmdable) ZRangeByScoreWithScores(ctx context.Context, key string, opt *ZRangeBy) *ZSliceCmd {
+	cmd := NewZSliceCmd(ctx, zRangeByScoreArgs(key, opt, true)...)
+	_ = c(ctx, cmd)
+	return cmd
+}
+
+func (c cmdable) ZRemRangeByScore(ctx context.Context, key, min, max string) *IntCmd {
+	cmd := NewIntCmd(ctx, "zremrangebyscore", key, min, max)
+	_ = c(ctx, cmd)
+	return cmd
+}
@@ -0,0 +1,258 @@
+package redis
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// conn is a connection to a server speaking RESP2 or RESP3. It is used by
+// one goroutine at a time.
+type conn struct {
+	nc    net.Conn
+	rd    *reader
+	bw    *bufio.Writer
+	opt   *Options
+	proto int
+
+	createdAt time.Time
+	usedAt    time.Time
+	gen       uint64
+	// broken is set once an I/O failure or an interrupted round trip
+	// leaves the connection in an unknown state.
+	broken bool
+}
+
+// dial connects to opt.Addr, with opt.Dialer or over TLS when
+// opt.TLSConfig is set, and runs the handshake.
+func dial(ctx context.Context, opt *Options) (*conn, error) {
+	ctx, cancel := context.WithTimeout(ctx, opt.DialTimeout)
+	defer cancel()
+	var nc net.Conn
+	var err error
+	if opt.Dialer != nil {
+		nc, err = opt.Dialer(ctx, "tcp", opt.Addr)
+	} else {
+		d := net.Dialer{KeepAlive: 5 * time.Minute}
+		nc, err = d.DialContext(ctx, "tcp", opt.Addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if opt.TLSConfig != nil && opt.Dialer == nil {
+		cfg := opt.TLSConfig
+		if cfg.ServerName == "" {
+			cfg = cfg.Clone()
+			cfg.ServerName, _, _ = net.SplitHostPort(opt.Addr)
+		}
+		tc := tls.Client(nc, cfg)
+		if err := tc.HandshakeContext(ctx); err != nil {
+			nc.Close()
+			return nil, err
+		}
+		nc = tc
+	}
+	now := time.Now()
+	cn := &conn{nc: nc, rd: newReader(nc), bw: bufio.NewWriter(nc), opt: opt, proto: 2, createdAt: now, usedAt: now}
+	if err := cn.init(ctx, opt); err != nil {
+		cn.close()
+		return nil, err
+	}
+	return cn, nil
+}
+
+// init negotiates RESP3 with HELLO, authenticating in the same command.
+// Servers that reject HELLO are spoken to in RESP2 after AUTH. SELECT
+// follows for a non-zero DB.
+func (cn *conn) init(ctx context.Context, opt *Options) error {
+	if opt.Protocol != 2 {
+		args := []interface{}{"hello", 3}
+		if opt.Password != "" {
+			user := opt.Username
+			if user == "" {
+				user = "default"
+			}
+			args = append(args, "auth", user, opt.Password)
+		}
+		hello := NewCmd(ctx, args...)
+		if err := cn.roundTrip(ctx, hello); err != nil {
+			return err
+		}
+		switch err := hello.Err(); err.(type) {
+		case nil:
+			cn.proto = 3
+		case RedisError:
+		default:
+			return err
+		}
+	}
+	var cmds []Cmder
+	if cn.proto == 2 && opt.Password != "" {
+		args := []interface{}{"auth", opt.Password}
+		if opt.Username != "" {
+			args = []interface{}{"auth", opt.Username, opt.Password}
+		}
+		cmds = append(cmds, NewStatusCmd(ctx, args...))
+	}
+	if opt.DB != 0 {
+		cmds = append(cmds, NewStatusCmd(ctx, "select", opt.DB))
+	}
+	if len(cmds) == 0 {
+		return nil
+	}
+	if err := cn.roundTrip(ctx, cmds...); err != nil {
+		return err
+	}
+	return firstCmdsErr(cmds)
+}
+
+// roundTrip writes cmds in one batch and reads their replies in order.
+// Socket deadlines follow the configured timeouts and ctx, and ctx being
+// done interrupts the round trip. An I/O failure breaks the connection and
+// is set on every command left without a reply.
+func (cn *conn) roundTrip(ctx context.Context, cmds ...Cmder) error {
+	if err := ctx.Err(); err != nil {
+		setCmdsErr(cmds, err)
+		return err
+	}
+	if err := cn.nc.SetWriteDeadline(deadline(ctx, cn.opt.WriteTimeout)); err != nil {
+		return cn.fail(ctx, err, cmds)
+	}
+	stop := context.AfterFunc(ctx, func() {
+		cn.nc.SetDeadline(time.Unix(1, 0))
+	})
+	defer func() {
+		if !stop() {
+			cn.broken = true
+		}
+	}()
+	for _, cmd := range cmds {
+		writeArgs(cn.bw, stringArgs(cmd.Args()))
+	}
+	if err := cn.bw.Flush(); err != nil {
+		return cn.fail(ctx, err, cmds)
+	}
+	if err := cn.nc.SetReadDeadline(deadline(ctx, readTimeout(cn.opt, cmds))); err != nil {
+		return cn.fail(ctx, err, cmds)
+	}
+	for i, cmd := range cmds {
+		reply, err := cn.rd.readReply()
+		if err != nil {
+			return cn.fail(ctx, err, cmds[i:])
+		}
+		cmd.setReply(reply)
+	}
+	return nil
+}
+
+// deadline returns the earlier of the ctx deadline and timeout from now,
+// or zero when neither applies.
+func deadline(ctx context.Context, timeout time.Duration) time.Time {
+	var t time.Time
+	if timeout > 0 {
+		t = time.Now().Add(timeout)
+	}
+	if d, ok := ctx.Deadline(); ok && (t.IsZero() || d.Before(t)) {
+		t = d
+	}
+	return t
+}
+
+// blocking lists the commands whose server timeout is their last argument.
+var blocking = map[string]bool{"BLPOP": true, "BRPOP": true}
+
+// readTimeout extends the read timeout for blocking commands past their
+// own timeout, like go-redis, and drops it for those waiting indefinitely.
+func readTimeout(opt *Options, cmds []Cmder) time.Duration {
+	timeout := opt.ReadTimeout
+	for _, cmd := range cmds {
+		if timeout == 0 || !blocking[strings.ToUpper(cmd.Name())] {
+			continue
+		}
+		args := cmd.Args()
+		secs, _ := strconv.ParseFloat(formatArg(args[len(args)-1]), 64)
+		if secs == 0 {
+			return 0
+		}
+		timeout = max(timeout, time.Duration(secs*float64(time.Second))+10*time.Second)
+	}
+	return timeout
+}
+
+// txRoundTrip runs cmds inside MULTI/EXEC. Errors reported while queueing
+// are set on their commands; a nil EXEC reply means a watched key changed.
+func (cn *conn) txRoundTrip(ctx context.Context, cmds []Cmder) error {
+	all := []Cmder{NewStatusCmd(ctx, "multi")}
+	for _, cmd := range cmds {
+		all = append(all, NewStatusCmd(ctx, cmd.Args()...))
+	}
+	exec := NewSliceCmd(ctx, "exec")
+	all = append(all, exec)
+	if err := cn.roundTrip(ctx, all...); err != nil {
+		setCmdsErr(cmds, err)
+		return err
+	}
+	for i, cmd := range cmds {
+		if err := all[i+1].Err(); err != nil {
+			cmd.SetErr(err)
+		}
+	}
+	switch err := exec.Err(); {
+	case err == Nil:
+		setCmdsErr(cmds, TxFailedErr)
+		return TxFailedErr
+	case err != nil:
+		return err
+	case len(exec.Val()) != len(cmds):
+		err := fmt.Errorf("redis: EXEC returned %d replies for %d commands", len(exec.Val()), len(cmds))
+		setCmdsErr(cmds, err)
+		return err
+	}
+	for i, reply := range exec.Val() {
+		cmds[i].setReply(reply)
+	}
+	return nil
+}
+
+// fail closes the connection after err and reports ctx's error instead
+// when ctx caused it.
+func (cn *conn) fail(ctx context.Context, err error, cmds []Cmder) error {
+	cn.broken = true
+	cn.nc.Close()
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		err = ctxErr
+	} else if d, ok := ctx.Deadline(); ok && !time.Now().Before(d) && errors.Is(err, os.ErrDeadlineExceeded) {
+		err = context.DeadlineExceeded
+	}
+	setCmdsErr(cmds, err)
+	return err
+}
+
+// healthy reports whether an idle connection is still usable. The server
+// sends nothing unprompted, so readable data or an error means it closed
+// the connection.
+func (cn *conn) healthy() bool {
+	if cn.broken || cn.nc.SetReadDeadline(time.Now()) != nil {
+		return false
+	}
+	_, err := cn.rd.Peek(1)
+	return errors.Is(err, os.ErrDeadlineExceeded)
+}
+
+func (cn *conn) close() error {
+	cn.broken = true
+	return cn.nc.Close()
+}
+
+func setCmdsErr(cmds []Cmder, err error) {
+	for _, cmd := range cmds {
+		cmd.SetErr(err)
+	}
+}
@@ -0,0 +1,239 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrPoolTimeout is returned when no connection is returned to a full
+// pool within PoolTimeout.
+var ErrPoolTimeout = errors.New("redis: connection pool timeout")
+
+// PoolStats describes the connection pool with the fields of go-redis.
+type PoolStats struct {
+	Hits     uint32 // idle connections reused
+	Misses   uint32 // connections dialed on demand
+	Timeouts uint32 // waits for a connection that exceeded PoolTimeout
+
+	WaitCount      uint32 // gets that waited for a connection
+	WaitDurationNs int64  // time spent waiting
+
+	TotalConns uint32 // open connections, idle or in use
+	IdleConns  uint32
+	StaleConns uint32 // connections closed for age or idleness
+}
+
+// pool hands out connections made by dial. A token in queue is held for
+// every connection in use, so at most PoolSize are.
+type pool struct {
+	opt   *Options
+	dial  func(context.Context) (*conn, error)
+	queue chan struct{}
+
+	mu    sync.Mutex
+	idle  []*conn
+	conns int
+	// dialing counts connections dialed in the background for
+	// MinIdleConns; they are included in conns.
+	dialing int
+	closed  bool
+	// gen is advanced by reset; connections of older generations are
+	// closed instead of reused.
+	gen uint64
+
+	hits, misses, timeouts, waits, stale atomic.Uint32
+	waitNs                               atomic.Int64
+}
+
+func newPool(opt *Options, dial func(context.Context) (*conn, error)) *pool {
+	p := &pool{opt: opt, dial: dial, queue: make(chan struct{}, opt.PoolSize)}
+	p.mu.Lock()
+	p.fillIdleLocked()
+	p.mu.Unlock()
+	return p
+}
+
+// wait takes a token, waiting up to PoolTimeout for one to be released.
+func (p *pool) wait(ctx context.Context) error {
+	select {
+	case p.queue <- struct{}{}:
+		return nil
+	default:
+	}
+	start := time.Now()
+	defer func() {
+		p.waits.Add(1)
+		p.waitNs.Add(int64(time.Since(start)))
+	}()
+	t := time.NewTimer(p.opt.PoolTimeout)
+	defer t.Stop()
+	select {
+	case p.queue <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		p.timeouts.Add(1)
+		return ErrPoolTimeout
+	}
+}
+
+func (p *pool) get(ctx context.Context) (*conn, error) {
+	if err := p.wait(ctx); err != nil {
+		return nil, err
+	}
+	for {
+		p.mu.Lock()
+		if p.closed {
+			p.mu.Unlock()
+			<-p.queue
+			return nil, ErrClosed
+		}
+		n := len(p.idle)
+		if n == 0 {
+			p.conns++
+			gen := p.gen
+			p.mu.Unlock()
+			p.misses.Add(1)
+			cn, err := p.dial(ctx)
+			if err != nil {
+				p.mu.Lock()
+				p.conns--
+				p.mu.Unlock()
+				<-p.queue
+				return nil, err
+			}
+			cn.gen = gen
+			return cn, nil
+		}
+		cn := p.idle[n-1]
+		p.idle = p.idle[:n-1]
+		p.fillIdleLocked()
+		p.mu.Unlock()
+		if p.usable(cn) {
+			p.hits.Add(1)
+			return cn, nil
+		}
+		p.mu.Lock()
+		p.removeLocked(cn)
+		p.mu.Unlock()
+	}
+}
+
+// usable reports whether an idle connection may be reused. Connections
+// past ConnMaxLifetime or ConnMaxIdleTime are counted as stale.
+func (p *pool) usable(cn *conn) bool {
+	now := time.Now()
+	if (p.opt.ConnMaxLifetime > 0 && now.Sub(cn.createdAt) >= p.opt.ConnMaxLifetime) ||
+		(p.opt.ConnMaxIdleTime > 0 && now.Sub(cn.usedAt) >= p.opt.ConnMaxIdleTime) {
+		p.stale.Add(1)
+		return false
+	}
+	return cn.healthy()
+}
+
+// put returns cn to the pool. It is closed instead when it is broken, the
+// pool is closed or was reset since cn was dialed, or a race with the
+// background dials left more than PoolSize connections open.
+func (p *pool) put(cn *conn) {
+	cn.usedAt = time.Now()
+	p.mu.Lock()
+	if cn.broken || p.closed || cn.gen != p.gen || p.conns > p.opt.PoolSize {
+		p.removeLocked(cn)
+	} else {
+		p.idle = append(p.idle, cn)
+	}
+	p.mu.Unlock()
+	<-p.queue
+}
+
+func (p *pool) removeLocked(cn *conn) {
+	cn.close()
+	p.conns--
+	p.fillIdleLocked()
+}
+
+// fillIdleLocked dials connections in the background until MinIdleConns
+// are idle or on their way, without exceeding PoolSize.
+func (p *pool) fillIdleLocked() {
+	for !p.closed && len(p.idle)+p.dialing < p.opt.MinIdleConns && p.conns < p.opt.PoolSize {
+		p.conns++
+		p.dialing++
+		gen := p.gen
+		go func() {
+			cn, err := p.dial(context.Background())
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			p.dialing--
+			switch {
+			case err != nil:
+				p.conns--
+			case p.closed || gen != p.gen:
+				cn.close()
+				p.conns--
+			default:
+				cn.gen = gen
+				p.idle = append(p.idle, cn)
+			}
+		}()
+	}
+}
+
+func (p *pool) stats() *PoolStats {
+	p.mu.Lock()
+	total, idle := p.conns-p.dialing, len(p.idle)
+	p.mu.Unlock()
+	return &PoolStats{
+		Hits:           p.hits.Load(),
+		Misses:         p.misses.Load(),
+		Timeouts:       p.timeouts.Load(),
+		WaitCount:      p.waits.Load(),
+		WaitDurationNs: p.waitNs.Load(),
+		TotalConns:     uint32(total),
+		IdleConns:      uint32(idle),
+		StaleConns:     p.stale.Load(),
+	}
+}
+
+// reset closes the idle connections and retires those in use, which are
+// closed when they are returned. New connections are dialed afresh.
+func (p *pool) reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.gen++
+	p.closeIdleLocked()
+	p.fillIdleLocked()
+}
+
+// close closes the idle connections; those in use are closed when they
+// are returned.
+func (p *pool) close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	p.closeIdleLocked()
+	return nil
+}
+
+func (p *pool) closeIdleLocked() {
+	for _, cn := range p.idle {
+		cn.close()
+	}
+	p.conns -= len(p.idle)
+	p.idle = nil
+}
+
+// withConn runs fn on a pooled connection. A failure to get one is set on
+// cmds.
+func (p *pool) withConn(ctx context.Context, cmds []Cmder, fn func(*conn) error) error {
+	cn, err := p.get(ctx)
+	if err != nil {
+		setCmdsErr(cmds, err)
+		return err
+	}
+	defer p.put(cn)
+	return fn(cn)
+}
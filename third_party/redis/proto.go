@@ -0,0 +1,138 @@
+package redis
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// maxBulkLen bounds bulk strings and aggregates read from the wire, like
+// the proto-max-bulk-len default of the server.
+const maxBulkLen = 512 << 20
+
+// writeArgs writes a command as an array of bulk strings.
+func writeArgs(w *bufio.Writer, args []string) {
+	w.WriteString("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, a := range args {
+		w.WriteString("$" + strconv.Itoa(len(a)) + "\r\n")
+		w.WriteString(a)
+		w.WriteString("\r\n")
+	}
+}
+
+// reader decodes RESP2 and RESP3 replies into the values commands expect:
+// strings, int64, float64, nil, RedisError and []interface{}. Maps are
+// flattened into key, value arrays like their RESP2 form, sets and pushes
+// become arrays and booleans become 1 or 0.
+type reader struct {
+	*bufio.Reader
+}
+
+func newReader(r io.Reader) *reader {
+	return &reader{bufio.NewReader(r)}
+}
+
+func (r *reader) readLine() (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return "", fmt.Errorf("redis: invalid reply %q", line)
+	}
+	return line[:len(line)-2], nil
+}
+
+// readLen parses the length of a bulk or aggregate reply, which is -1 for
+// the RESP2 nil forms.
+func readLen(line string) (int, error) {
+	n, err := strconv.Atoi(line[1:])
+	if err != nil || n < -1 || n > maxBulkLen {
+		return 0, fmt.Errorf("redis: invalid reply %q", line)
+	}
+	return n, nil
+}
+
+func (r *reader) readBulk(n int) (string, error) {
+	buf := make([]byte, n+2)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return "", err
+	}
+	if buf[n] != '\r' || buf[n+1] != '\n' {
+		return "", fmt.Errorf("redis: invalid bulk string terminator")
+	}
+	return string(buf[:n]), nil
+}
+
+// readReply reads one reply. Error replies are returned as RedisError
+// values; the error result reports I/O and protocol failures only.
+func (r *reader) readReply() (interface{}, error) {
+	line, err := r.readLine()
+	if err != nil {
+		return nil, err
+	}
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return RedisError(line[1:]), nil
+	case ':':
+		n, err := strconv.ParseInt(line[1:], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("redis: invalid reply %q", line)
+		}
+		return n, nil
+	case '(':
+		return line[1:], nil
+	case ',':
+		f, err := strconv.ParseFloat(line[1:], 64)
+		if err != nil {
+			return nil, fmt.Errorf("redis: invalid reply %q", line)
+		}
+		return f, nil
+	case '#':
+		if line == "#t" {
+			return int64(1), nil
+		}
+		return int64(0), nil
+	case '_':
+		return nil, nil
+	case '$', '!', '=':
+		n, err := readLen(line)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		s, err := r.readBulk(n)
+		switch {
+		case err != nil:
+			return nil, err
+		case line[0] == '!':
+			return RedisError(s), nil
+		case line[0] == '=' && len(s) >= 4:
+			// Verbatim strings carry a three letter format and a colon.
+			return s[4:], nil
+		}
+		return s, nil
+	case '*', '~', '>', '%', '|':
+		n, err := readLen(line)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		if line[0] == '%' || line[0] == '|' {
+			n *= 2
+		}
+		arr := make([]interface{}, n)
+		for i := range arr {
+			if arr[i], err = r.readReply(); err != nil {
+				return nil, err
+			}
+		}
+		if line[0] == '|' {
+			// Attributes annotate the reply that follows them.
+			return r.readReply()
+		}
+		return arr, nil
+	}
+	return nil, fmt.Errorf("redis: invalid reply %q", line)
+}
@@ -0,0 +1,162 @@
+package redis
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Message is a payload received on a subscribed channel.
+type Message struct {
+	Channel string
+	Pattern string
+	Payload string
+}
+
+// Subscription confirms a change of the subscribed channels.
+type Subscription struct {
+	Kind    string
+	Channel string
+	Count   int
+}
+
+// PubSub is a subscription to one or more channels. Like go-redis, it
+// buffers up to 100 messages; messages published while the buffer is full
+// are dropped.
+//
+// The subscription owns a connection of its own. When that connection
+// fails it is redialed and the channels are subscribed again, which
+// delivers fresh subscription confirmations.
+type PubSub struct {
+	channels []string
+	ch       chan interface{}
+	once     sync.Once
+
+	dial func(context.Context) (*conn, error)
+	mu   sync.Mutex
+	cn   *conn
+	done chan struct{}
+}
+
+// Subscribe subscribes to channels. A subscription confirmation for each
+// channel is the first thing delivered.
+func (c *Client) Subscribe(ctx context.Context, channels ...string) *PubSub {
+	return newNetPubSub(c.dialConn, channels)
+}
+
+// newNetPubSub subscribes to channels on connections made by dial.
+func newNetPubSub(dial func(context.Context) (*conn, error), channels []string) *PubSub {
+	ps := &PubSub{channels: channels, ch: make(chan interface{}, 100), dial: dial, done: make(chan struct{})}
+	go ps.run()
+	return ps
+}
+
+// ChannelWithSubscriptions delivers both *Subscription and *Message values.
+// The channel is closed by Close.
+func (p *PubSub) ChannelWithSubscriptions(opts ...interface{}) <-chan interface{} {
+	return p.ch
+}
+
+func (p *PubSub) Close() error {
+	p.once.Do(func() {
+		p.mu.Lock()
+		close(p.done)
+		if p.cn != nil {
+			p.cn.nc.Close()
+		}
+		p.mu.Unlock()
+	})
+	return nil
+}
+
+// run keeps a connection subscribed until Close, backing off between
+// failed attempts, and closes the channel on the way out.
+func (p *PubSub) run() {
+	defer close(p.ch)
+	const maxBackoff = time.Second
+	backoff := 10 * time.Millisecond
+	for {
+		if p.serve() {
+			backoff = 10 * time.Millisecond
+		}
+		t := time.NewTimer(backoff)
+		select {
+		case <-p.done:
+			t.Stop()
+			return
+		case <-t.C:
+		}
+		backoff = min(2*backoff, maxBackoff)
+	}
+}
+
+// serve subscribes on a new connection and relays what the server pushes
+// until the connection fails. It reports whether the subscription was
+// confirmed.
+func (p *PubSub) serve() bool {
+	cn, err := p.dial(context.Background())
+	if err != nil {
+		return false
+	}
+	defer cn.close()
+	p.mu.Lock()
+	select {
+	case <-p.done:
+		p.mu.Unlock()
+		return false
+	default:
+	}
+	p.cn = cn
+	p.mu.Unlock()
+
+	if cn.nc.SetDeadline(time.Time{}) != nil {
+		return false
+	}
+	writeArgs(cn.bw, append([]string{"subscribe"}, p.channels...))
+	if cn.bw.Flush() != nil {
+		return false
+	}
+	confirmed := false
+	for {
+		reply, err := cn.rd.readReply()
+		if err != nil {
+			return confirmed
+		}
+		msg := pushedMessage(reply)
+		if msg == nil {
+			continue
+		}
+		if _, ok := msg.(*Subscription); ok {
+			confirmed = true
+		}
+		select {
+		case p.ch <- msg:
+		default:
+		}
+	}
+}
+
+// pushedMessage converts a subscribe confirmation or a message pushed by
+// the server, returning nil for anything else.
+func pushedMessage(reply interface{}) interface{} {
+	arr, ok := reply.([]interface{})
+	if !ok || len(arr) < 3 {
+		return nil
+	}
+	s := make([]string, len(arr))
+	for i, el := range arr {
+		s[i], _ = toString(el, nil)
+	}
+	switch s[0] {
+	case "subscribe", "unsubscribe":
+		n, _ := toInt64(arr[2], nil)
+		return &Subscription{Kind: s[0], Channel: s[1], Count: int(n)}
+	case "message":
+		return &Message{Channel: s[1], Payload: s[2]}
+	case "pmessage":
+		if len(s) == 4 {
+			return &Message{Pattern: s[1], Channel: s[2], Payload: s[3]}
+		}
+	}
+	return nil
+}
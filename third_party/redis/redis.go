@@ -1,115 +1,352 @@
 package redis
 
 import (
-    "context"
-    "crypto/tls"
-    "errors"
-    "fmt"
-    "sync"
-    "time"
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"runtime"
+	"sync/atomic"
+	"time"
 )
 
 var (
-    // Nil is returned when a key does not exist.
-    Nil = errors.New("redis: nil")
+	// Nil is returned when a key does not exist.
+	Nil = errors.New("redis: nil")
+
+	// TxFailedErr is returned when a watched key changed before EXEC.
+	TxFailedErr = RedisError("redis: transaction failed")
+
+	// ErrClosed is returned by commands issued on a closed client.
+	ErrClosed = errors.New("redis: client is closed")
 )
 
+// Error is implemented by error replies sent by the server.
+type Error interface {
+	error
+	RedisError()
+}
+
+// RedisError is an error reply sent by the server.
+type RedisError string
+
+func (e RedisError) Error() string { return string(e) }
+func (RedisError) RedisError()     {}
+
 type Options struct {
-    Addr     string
-    DB       int
-    Username string
-    Password string
-    TLSConfig *tls.Config
+	Addr      string
+	DB        int
+	Username  string
+	Password  string
+	TLSConfig *tls.Config
+
+	// Dialer, when set, opens connections in place of the default TCP
+	// dialer and TLSConfig is not applied to them.
+	Dialer func(ctx context.Context, network, addr string) (net.Conn, error)
+
+	// Protocol is the RESP version to speak. It defaults to 3, falling back
+	// to 2 when the server rejects HELLO; 2 skips HELLO altogether.
+	Protocol int
+
+	// DialTimeout bounds connecting, including the TLS and protocol
+	// handshakes. It defaults to 5 seconds.
+	DialTimeout time.Duration
+
+	// ReadTimeout and WriteTimeout bound each socket read and write. They
+	// default to 3 seconds, WriteTimeout following ReadTimeout; -1 disables
+	// them. Blocking commands extend the read timeout by their own.
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+
+	// PoolSize caps the connections in use and defaults to ten per CPU.
+	// Commands wait up to PoolTimeout, ReadTimeout plus a second by
+	// default, for a connection to be returned.
+	PoolSize    int
+	PoolTimeout time.Duration
+
+	// MinIdleConns connections are dialed ahead of demand.
+	MinIdleConns int
+
+	// Connections older than ConnMaxLifetime, or idle for longer than
+	// ConnMaxIdleTime, are closed instead of reused. ConnMaxIdleTime
+	// defaults to 30 minutes and -1 disables either limit.
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
 }
 
-type Client struct {
-    mu    sync.RWMutex
-    store map[string]item
+// init applies the defaults of go-redis.
+func (opt *Options) init() {
+	if opt.Addr == "" {
+		opt.Addr = "localhost:6379"
+	}
+	if opt.DialTimeout == 0 {
+		opt.DialTimeout = 5 * time.Second
+	}
+	switch opt.ReadTimeout {
+	case -1:
+		opt.ReadTimeout = 0
+	case 0:
+		opt.ReadTimeout = 3 * time.Second
+	}
+	switch opt.WriteTimeout {
+	case -1:
+		opt.WriteTimeout = 0
+	case 0:
+		opt.WriteTimeout = opt.ReadTimeout
+	}
+	if opt.PoolSize == 0 {
+		opt.PoolSize = 10 * runtime.GOMAXPROCS(0)
+	}
+	if opt.PoolTimeout == 0 {
+		opt.PoolTimeout = opt.ReadTimeout + time.Second
+	}
+	switch opt.ConnMaxIdleTime {
+	case -1:
+		opt.ConnMaxIdleTime = 0
+	case 0:
+		opt.ConnMaxIdleTime = 30 * time.Minute
+	}
+	if opt.ConnMaxLifetime == -1 {
+		opt.ConnMaxLifetime = 0
+	}
 }
 
-type item struct {
-    val string
-    exp time.Time
+// Client sends commands to the server at Options.Addr over a pool of
+// connections.
+type Client struct {
+	cmdable
+	opt  *Options
+	pool *pool
+	// failover resolves the master through Sentinel for clients made by
+	// NewFailoverClient.
+	failover *failover
+	closed   atomic.Bool
 }
 
 func NewClient(opts *Options) *Client {
-    return &Client{store: make(map[string]item)}
-}
-
-type StatusCmd struct{ err error }
-func (c *Client) Ping(ctx context.Context) *StatusCmd { return &StatusCmd{} }
-func (s *StatusCmd) Err() error { return s.err }
-
-func (c *Client) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) *StatusCmd {
-    c.mu.Lock()
-    defer c.mu.Unlock()
-    var v string
-    switch t := value.(type) {
-    case string:
-        v = t
-    case []byte:
-        v = string(t)
-    default:
-        v = fmt.Sprint(t)
-    }
-    it := item{val: v}
-    if ttl > 0 {
-        it.exp = time.Now().Add(ttl)
-    }
-    c.store[key] = it
-    return &StatusCmd{}
-}
-
-type StringCmd struct {
-    val string
-    err error
-}
-
-func (c *Client) Get(ctx context.Context, key string) *StringCmd {
-    c.mu.RLock()
-    it, ok := c.store[key]
-    c.mu.RUnlock()
-    if !ok || ( !it.exp.IsZero() && time.Now().After(it.exp) ) {
-        if ok {
-            c.mu.Lock(); delete(c.store, key); c.mu.Unlock()
-        }
-        return &StringCmd{err: Nil}
-    }
-    return &StringCmd{val: it.val}
-}
-
-func (s *StringCmd) Result() (string, error) { return s.val, s.err }
-func (s *StringCmd) Bytes() ([]byte, error) {
-    if s.err != nil { return nil, s.err }
-    return []byte(s.val), nil
-}
-
-type IntCmd struct {
-    val int64
-    err error
-}
-
-func (c *Client) Del(ctx context.Context, keys ...string) *IntCmd {
-    c.mu.Lock()
-    defer c.mu.Unlock()
-    for _, k := range keys {
-        delete(c.store, k)
-    }
-    return &IntCmd{val: 0}
-}
-
-func (i *IntCmd) Err() error { return i.err }
-func (i *IntCmd) Result() (int64, error) { return i.val, i.err }
-
-func (c *Client) Exists(ctx context.Context, keys ...string) *IntCmd {
-    c.mu.RLock()
-    defer c.mu.RUnlock()
-    var count int64
-    for _, k := range keys {
-        it, ok := c.store[k]
-        if ok && (it.exp.IsZero() || time.Now().Before(it.exp)) {
-            count++
-        }
-    }
-    return &IntCmd{val: count}
+	opt := *opts
+	opt.init()
+	return newNetClient(&opt, nil)
+}
+
+func newNetClient(opt *Options, f *failover) *Client {
+	c := &Client{opt: opt, failover: f}
+	c.pool = newPool(opt, c.dialConn)
+	c.cmdable = c.process
+	return c
+}
+
+// dialConn connects to Options.Addr, or to the current master of a
+// failover client.
+func (c *Client) dialConn(ctx context.Context) (*conn, error) {
+	if c.failover != nil {
+		return c.failover.dial(ctx, c.opt)
+	}
+	return dial(ctx, c.opt)
+}
+
+// run runs fn on a pooled connection. When a failover client finds the
+// master read-only or unreachable, the master is resolved again, the pool
+// is reset if it moved, and fn is retried once.
+func (c *Client) run(ctx context.Context, cmds []Cmder, fn func(*conn) error) error {
+	err := c.pool.withConn(ctx, cmds, fn)
+	if c.failover == nil || !retryable(err) && !retryable(firstCmdsErr(cmds)) {
+		return err
+	}
+	if _, moved, rerr := c.failover.resolve(ctx); rerr != nil {
+		return err
+	} else if moved {
+		c.pool.reset()
+	}
+	setCmdsErr(cmds, nil)
+	return c.pool.withConn(ctx, cmds, fn)
+}
+
+func (c *Client) Options() *Options { return c.opt }
+
+// PoolStats reports the state of the connection pool.
+func (c *Client) PoolStats() *PoolStats {
+	return c.pool.stats()
+}
+
+func (c *Client) process(ctx context.Context, cmd Cmder) error {
+	if c.closed.Load() {
+		cmd.SetErr(ErrClosed)
+		return ErrClosed
+	}
+	_ = c.run(ctx, []Cmder{cmd}, func(cn *conn) error {
+		return cn.roundTrip(ctx, cmd)
+	})
+	return cmd.Err()
+}
+
+// Close releases client resources, closing idle connections and those in
+// use once they are returned.
+func (c *Client) Close() error {
+	c.closed.Store(true)
+	return c.pool.close()
+}
+
+// Watch runs fn in a transaction that is aborted with TxFailedErr when any
+// of keys changes before the transaction executes. The transaction holds
+// one connection until fn returns.
+func (c *Client) Watch(ctx context.Context, fn func(*Tx) error, keys ...string) error {
+	tx, err := c.newTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Close(ctx)
+	if len(keys) > 0 {
+		if err := tx.Watch(ctx, keys...).Err(); err != nil {
+			return err
+		}
+	}
+	return fn(tx)
+}
+
+func (c *Client) newTx(ctx context.Context) (*Tx, error) {
+	cn, err := c.pool.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tx := &Tx{release: func() { c.pool.put(cn) }}
+	tx.process = func(ctx context.Context, cmd Cmder) error {
+		_ = cn.roundTrip(ctx, cmd)
+		return cmd.Err()
+	}
+	tx.txExec = func(ctx context.Context, cmds []Cmder) error {
+		return cn.txRoundTrip(ctx, cmds)
+	}
+	tx.cmdable = tx.process
+	return tx, nil
+}
+
+func (c *Client) pipelineExec(ctx context.Context, cmds []Cmder) error {
+	return c.run(ctx, cmds, func(cn *conn) error {
+		return cn.roundTrip(ctx, cmds...)
+	})
+}
+
+func (c *Client) txPipelineExec(ctx context.Context, cmds []Cmder) error {
+	return c.run(ctx, cmds, func(cn *conn) error {
+		return cn.txRoundTrip(ctx, cmds)
+	})
+}
+
+// Pipeline queues commands and sends them together on Exec.
+func (c *Client) Pipeline() Pipeliner {
+	return newPipeline(c.pipelineExec)
+}
+
+func (c *Client) Pipelined(ctx context.Context, fn func(Pipeliner) error) ([]Cmder, error) {
+	return newPipeline(c.pipelineExec).Pipelined(ctx, fn)
+}
+
+// TxPipeline queues commands and runs them atomically in MULTI/EXEC.
+func (c *Client) TxPipeline() Pipeliner {
+	return newPipeline(c.txPipelineExec)
+}
+
+func (c *Client) TxPipelined(ctx context.Context, fn func(Pipeliner) error) ([]Cmder, error) {
+	return newPipeline(c.txPipelineExec).Pipelined(ctx, fn)
+}
+
+// Tx is a transaction bound to a single connection, so WATCH state is kept
+// between commands.
+type Tx struct {
+	cmdable
+	process cmdable
+	txExec  func(context.Context, []Cmder) error
+	release func()
+}
+
+// Watch marks keys to be watched for the transaction.
+func (tx *Tx) Watch(ctx context.Context, keys ...string) *StatusCmd {
+	args := []interface{}{"watch"}
+	for _, k := range keys {
+		args = append(args, k)
+	}
+	cmd := NewStatusCmd(ctx, args...)
+	_ = tx.process(ctx, cmd)
+	return cmd
+}
+
+func (tx *Tx) Unwatch(ctx context.Context, keys ...string) *StatusCmd {
+	cmd := NewStatusCmd(ctx, "unwatch")
+	_ = tx.process(ctx, cmd)
+	return cmd
+}
+
+// TxPipeline queues commands that are executed with MULTI/EXEC on the
+// transaction connection.
+func (tx *Tx) TxPipeline() Pipeliner {
+	return newPipeline(tx.txExec)
+}
+
+func (tx *Tx) TxPipelined(ctx context.Context, fn func(Pipeliner) error) ([]Cmder, error) {
+	return newPipeline(tx.txExec).Pipelined(ctx, fn)
+}
+
+// Close unwatches the keys and releases the connection of the transaction.
+func (tx *Tx) Close(ctx context.Context) error {
+	_ = tx.Unwatch(ctx).Err()
+	tx.release()
+	return nil
+}
+
+// Pipeliner is a queue of commands executed by Exec.
+type Pipeliner interface {
+	Cmdable
+	Len() int
+	Discard()
+	Exec(ctx context.Context) ([]Cmder, error)
+}
+
+type Pipeline struct {
+	cmdable
+	cmds []Cmder
+	exec func(ctx context.Context, cmds []Cmder) error
+}
+
+func newPipeline(exec func(ctx context.Context, cmds []Cmder) error) *Pipeline {
+	pipe := &Pipeline{exec: exec}
+	pipe.cmdable = pipe.queue
+	return pipe
+}
+
+func (p *Pipeline) queue(ctx context.Context, cmd Cmder) error {
+	p.cmds = append(p.cmds, cmd)
+	return nil
+}
+
+func (p *Pipeline) Len() int { return len(p.cmds) }
+func (p *Pipeline) Discard() { p.cmds = p.cmds[:0] }
+
+// Exec sends queued commands and returns them with the first error.
+func (p *Pipeline) Exec(ctx context.Context) ([]Cmder, error) {
+	if len(p.cmds) == 0 {
+		return nil, nil
+	}
+	cmds := p.cmds
+	p.cmds = nil
+	if err := p.exec(ctx, cmds); err != nil {
+		return cmds, err
+	}
+	return cmds, firstCmdsErr(cmds)
+}
+
+func (p *Pipeline) Pipelined(ctx context.Context, fn func(Pipeliner) error) ([]Cmder, error) {
+	if err := fn(p); err != nil {
+		return nil, err
+	}
+	return p.Exec(ctx)
+}
+
+func firstCmdsErr(cmds []Cmder) error {
+	for _, cmd := range cmds {
+		if err := cmd.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
 }
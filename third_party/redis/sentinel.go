@@ -0,0 +1,195 @@
+package redis
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FailoverOptions configure a client of the master of a deployment
+// monitored by Sentinel. The fields shared with Options apply to the
+// master connections.
+type FailoverOptions struct {
+	// MasterName is the name the sentinels monitor the master under.
+	MasterName string
+	// SentinelAddrs are asked in turn for the address of the master.
+	SentinelAddrs []string
+
+	SentinelUsername string
+	SentinelPassword string
+
+	DB        int
+	Username  string
+	Password  string
+	TLSConfig *tls.Config
+	Dialer    func(ctx context.Context, network, addr string) (net.Conn, error)
+	Protocol  int
+
+	DialTimeout     time.Duration
+	ReadTimeout     time.Duration
+	WriteTimeout    time.Duration
+	PoolSize        int
+	PoolTimeout     time.Duration
+	MinIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+}
+
+func (opt *FailoverOptions) clientOptions() *Options {
+	return &Options{
+		DB:              opt.DB,
+		Username:        opt.Username,
+		Password:        opt.Password,
+		TLSConfig:       opt.TLSConfig,
+		Dialer:          opt.Dialer,
+		Protocol:        opt.Protocol,
+		DialTimeout:     opt.DialTimeout,
+		ReadTimeout:     opt.ReadTimeout,
+		WriteTimeout:    opt.WriteTimeout,
+		PoolSize:        opt.PoolSize,
+		PoolTimeout:     opt.PoolTimeout,
+		MinIdleConns:    opt.MinIdleConns,
+		ConnMaxLifetime: opt.ConnMaxLifetime,
+		ConnMaxIdleTime: opt.ConnMaxIdleTime,
+	}
+}
+
+// NewFailoverClient returns a client of the master named by
+// opt.MasterName. The master is resolved through the sentinels when the
+// first connection is dialed, and again whenever it stops accepting writes
+// or cannot be reached, which moves the pool to the new master after a
+// failover.
+func NewFailoverClient(opt *FailoverOptions) *Client {
+	copt := opt.clientOptions()
+	copt.init()
+	sopt := Options{
+		Username:    opt.SentinelUsername,
+		Password:    opt.SentinelPassword,
+		TLSConfig:   opt.TLSConfig,
+		Dialer:      opt.Dialer,
+		Protocol:    opt.Protocol,
+		DialTimeout: opt.DialTimeout,
+		ReadTimeout: opt.ReadTimeout,
+	}
+	sopt.init()
+	f := &failover{
+		name:      opt.MasterName,
+		opt:       sopt,
+		sentinels: append([]string(nil), opt.SentinelAddrs...),
+	}
+	return newNetClient(copt, f)
+}
+
+// failover tracks the master of a Sentinel deployment.
+type failover struct {
+	name string
+	// opt is used for sentinel connections, with the address of each
+	// sentinel filled in.
+	opt Options
+
+	mu sync.Mutex
+	// sentinels keeps the one that answered last in front.
+	sentinels []string
+	master    string
+}
+
+// dial connects to the master, resolving it again when the last known
+// address cannot be reached.
+func (f *failover) dial(ctx context.Context, opt *Options) (*conn, error) {
+	f.mu.Lock()
+	addr := f.master
+	f.mu.Unlock()
+	if addr == "" {
+		var err error
+		if addr, _, err = f.resolve(ctx); err != nil {
+			return nil, err
+		}
+	}
+	cn, err := dialAddr(ctx, opt, addr)
+	if err == nil {
+		return cn, nil
+	}
+	fresh, moved, rerr := f.resolve(ctx)
+	if rerr != nil || !moved {
+		return nil, err
+	}
+	return dialAddr(ctx, opt, fresh)
+}
+
+func dialAddr(ctx context.Context, opt *Options, addr string) (*conn, error) {
+	o := *opt
+	o.Addr = addr
+	return dial(ctx, &o)
+}
+
+// resolve asks the sentinels for the master and reports whether it moved
+// since it was last resolved.
+func (f *failover) resolve(ctx context.Context) (addr string, moved bool, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var errs []error
+	for i, sentinel := range f.sentinels {
+		addr, err := f.query(ctx, sentinel)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		f.sentinels[0], f.sentinels[i] = f.sentinels[i], f.sentinels[0]
+		moved = f.master != "" && f.master != addr
+		f.master = addr
+		return addr, moved, nil
+	}
+	return "", false, fmt.Errorf("redis: no sentinel knows master %q: %w", f.name, errors.Join(errs...))
+}
+
+func (f *failover) query(ctx context.Context, sentinel string) (string, error) {
+	opt := f.opt
+	opt.Addr = sentinel
+	cn, err := dial(ctx, &opt)
+	if err != nil {
+		return "", err
+	}
+	defer cn.close()
+	cmd := NewStringSliceCmd(ctx, "sentinel", "get-master-addr-by-name", f.name)
+	_ = cn.roundTrip(ctx, cmd)
+	vals, err := cmd.Result()
+	switch {
+	case err == Nil:
+		return "", fmt.Errorf("redis: sentinel %s does not monitor %q", sentinel, f.name)
+	case err != nil:
+		return "", err
+	case len(vals) != 2:
+		return "", fmt.Errorf("redis: invalid sentinel reply %q", vals)
+	}
+	return net.JoinHostPort(vals[0], vals[1]), nil
+}
+
+// retryable reports whether err means the node failed or no longer serves
+// the command, so it may succeed once the topology is looked up again.
+// Those are I/O failures other than timeouts, and replies telling the
+// client to go elsewhere or try again.
+func retryable(err error) bool {
+	switch err {
+	case nil, Nil, ErrClosed, ErrPoolTimeout, context.Canceled, context.DeadlineExceeded:
+		return false
+	}
+	if rerr, ok := err.(RedisError); ok {
+		for _, prefix := range []string{"READONLY ", "LOADING ", "MASTERDOWN ", "CLUSTERDOWN ", "TRYAGAIN "} {
+			if strings.HasPrefix(string(rerr), prefix) {
+				return true
+			}
+		}
+		return false
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var nerr net.Error
+	return errors.As(err, &nerr) && !nerr.Timeout()
+}
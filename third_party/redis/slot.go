@@ -0,0 +1,72 @@
+package redis
+
+import "strings"
+
+// slotCount is the number of hash slots a cluster divides keys into.
+const slotCount = 16384
+
+// crc16Table is the CRC16/XMODEM table Redis Cluster hashes keys with.
+var crc16Table = func() (t [256]uint16) {
+	for i := range t {
+		crc := uint16(i) << 8
+		for range 8 {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+		t[i] = crc
+	}
+	return t
+}()
+
+// hashSlot returns the cluster slot of key. Only the part between the first
+// { and the following } is hashed when it is not empty, so keys sharing
+// such a hash tag share a slot.
+func hashSlot(key string) int {
+	if i := strings.IndexByte(key, '{'); i >= 0 {
+		if j := strings.IndexByte(key[i+1:], '}'); j > 0 {
+			key = key[i+1 : i+1+j]
+		}
+	}
+	var crc uint16
+	for i := 0; i < len(key); i++ {
+		crc = crc<<8 ^ crc16Table[byte(crc>>8)^key[i]]
+	}
+	return int(crc) % slotCount
+}
+
+// commandKeys returns the keys among the arguments of a command, which
+// decide the slot it is routed to.
+func commandKeys(args []string) []string {
+	if len(args) < 2 {
+		return nil
+	}
+	switch strings.ToUpper(args[0]) {
+	case "PING", "SCAN", "PUBLISH", "SUBSCRIBE", "HELLO", "AUTH", "SELECT",
+		"MULTI", "EXEC", "DISCARD", "UNWATCH", "ASKING", "CLUSTER", "SENTINEL":
+		return nil
+	case "DEL", "UNLINK", "EXISTS", "TOUCH", "MGET", "WATCH":
+		return args[1:]
+	case "MSET":
+		keys := make([]string, 0, len(args)/2)
+		for i := 1; i < len(args); i += 2 {
+			keys = append(keys, args[i])
+		}
+		return keys
+	case "BLPOP", "BRPOP":
+		return args[1 : len(args)-1]
+	}
+	return args[1:2]
+}
+
+// cmdSlot returns the slot of the first key of cmd, or -1 for commands
+// without keys.
+func cmdSlot(cmd Cmder) int {
+	keys := commandKeys(stringArgs(cmd.Args()))
+	if len(keys) == 0 {
+		return -1
+	}
+	return hashSlot(keys[0])
+}
@@ -0,0 +1,140 @@
+package redis
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"time"
+)
+
+// UniversalClient is implemented by Client, including failover clients,
+// and ClusterClient.
+type UniversalClient interface {
+	Cmdable
+	Watch(ctx context.Context, fn func(*Tx) error, keys ...string) error
+	Pipeline() Pipeliner
+	Pipelined(ctx context.Context, fn func(Pipeliner) error) ([]Cmder, error)
+	TxPipeline() Pipeliner
+	TxPipelined(ctx context.Context, fn func(Pipeliner) error) ([]Cmder, error)
+	Subscribe(ctx context.Context, channels ...string) *PubSub
+	PoolStats() *PoolStats
+	Close() error
+}
+
+var (
+	_ UniversalClient = (*Client)(nil)
+	_ UniversalClient = (*ClusterClient)(nil)
+)
+
+// UniversalOptions configure whichever client NewUniversalClient picks.
+type UniversalOptions struct {
+	// Addrs is the address of a single server, the sentinels when
+	// MasterName is set, or the seed nodes of a cluster.
+	Addrs []string
+
+	// MasterName selects a failover client for the master monitored under
+	// that name.
+	MasterName string
+
+	// IsClusterMode selects a cluster client even for a single seed node.
+	IsClusterMode bool
+
+	DB               int
+	Username         string
+	Password         string
+	SentinelUsername string
+	SentinelPassword string
+	TLSConfig        *tls.Config
+	Dialer           func(ctx context.Context, network, addr string) (net.Conn, error)
+	Protocol         int
+	MaxRedirects     int
+
+	DialTimeout     time.Duration
+	ReadTimeout     time.Duration
+	WriteTimeout    time.Duration
+	PoolSize        int
+	PoolTimeout     time.Duration
+	MinIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+}
+
+func (o *UniversalOptions) Cluster() *ClusterOptions {
+	return &ClusterOptions{
+		Addrs:           o.Addrs,
+		MaxRedirects:    o.MaxRedirects,
+		Username:        o.Username,
+		Password:        o.Password,
+		TLSConfig:       o.TLSConfig,
+		Dialer:          o.Dialer,
+		Protocol:        o.Protocol,
+		DialTimeout:     o.DialTimeout,
+		ReadTimeout:     o.ReadTimeout,
+		WriteTimeout:    o.WriteTimeout,
+		PoolSize:        o.PoolSize,
+		PoolTimeout:     o.PoolTimeout,
+		MinIdleConns:    o.MinIdleConns,
+		ConnMaxLifetime: o.ConnMaxLifetime,
+		ConnMaxIdleTime: o.ConnMaxIdleTime,
+	}
+}
+
+func (o *UniversalOptions) Failover() *FailoverOptions {
+	return &FailoverOptions{
+		MasterName:       o.MasterName,
+		SentinelAddrs:    o.Addrs,
+		SentinelUsername: o.SentinelUsername,
+		SentinelPassword: o.SentinelPassword,
+		DB:               o.DB,
+		Username:         o.Username,
+		Password:         o.Password,
+		TLSConfig:        o.TLSConfig,
+		Dialer:           o.Dialer,
+		Protocol:         o.Protocol,
+		DialTimeout:      o.DialTimeout,
+		ReadTimeout:      o.ReadTimeout,
+		WriteTimeout:     o.WriteTimeout,
+		PoolSize:         o.PoolSize,
+		PoolTimeout:      o.PoolTimeout,
+		MinIdleConns:     o.MinIdleConns,
+		ConnMaxLifetime:  o.ConnMaxLifetime,
+		ConnMaxIdleTime:  o.ConnMaxIdleTime,
+	}
+}
+
+func (o *UniversalOptions) Simple() *Options {
+	var addr string
+	if len(o.Addrs) > 0 {
+		addr = o.Addrs[0]
+	}
+	return &Options{
+		Addr:            addr,
+		DB:              o.DB,
+		Username:        o.Username,
+		Password:        o.Password,
+		TLSConfig:       o.TLSConfig,
+		Dialer:          o.Dialer,
+		Protocol:        o.Protocol,
+		DialTimeout:     o.DialTimeout,
+		ReadTimeout:     o.ReadTimeout,
+		WriteTimeout:    o.WriteTimeout,
+		PoolSize:        o.PoolSize,
+		PoolTimeout:     o.PoolTimeout,
+		MinIdleConns:    o.MinIdleConns,
+		ConnMaxLifetime: o.ConnMaxLifetime,
+		ConnMaxIdleTime: o.ConnMaxIdleTime,
+	}
+}
+
+// NewUniversalClient returns a failover client when MasterName is set, a
+// cluster client when IsClusterMode is set or several addresses are given,
+// and a client of a single server otherwise.
+func NewUniversalClient(opts *UniversalOptions) UniversalClient {
+	switch {
+	case opts.MasterName != "":
+		return NewFailoverClient(opts.Failover())
+	case opts.IsClusterMode || len(opts.Addrs) > 1:
+		return NewClusterClient(opts.Cluster())
+	}
+	return NewClient(opts.Simple())
+}